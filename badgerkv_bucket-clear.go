@@ -0,0 +1,148 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// ClearBucket deletes all keys of the bucket but keeps it registered, so concurrent
+// consumers calling Tx.Bucket never see BucketNotFoundError. The cached handle stays valid.
+func (t *tx) ClearBucket(ctx context.Context, name libkv.BucketName) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	info, err := t.BucketInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+	err = forEachBucketKey(ctx, t.badgerTx, name, func(item *badger.Item) error {
+		return t.badgerTx.Delete(item.KeyCopy(nil))
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "clear bucket %s failed", name)
+	}
+	if err := deleteBucketIndexEntries(ctx, t.badgerTx, name); err != nil {
+		return errors.Wrapf(ctx, err, "delete index entries of bucket %s failed", name)
+	}
+	if err := deleteBucketChunks(ctx, t.badgerTx, name); err != nil {
+		return errors.Wrapf(ctx, err, "delete chunks of bucket %s failed", name)
+	}
+	if info.TrackStats {
+		return writeBucketCounters(ctx, t.badgerTx, name, bucketCounters{})
+	}
+	return nil
+}
+
+// TruncateBucket deletes all keys of the bucket like Tx.ClearBucket, but outside of a
+// transaction with Badger's DropPrefix, so it works for buckets of any size. If other
+// buckets share the key prefix (users_v2 for users), keys are deleted in batches instead,
+// because DropPrefix would remove their keys too. Update transactions wait while the
+// prefixes are checked and dropped, so no bucket sharing them is created meanwhile.
+// Counters of tracked buckets are rebuilt afterwards.
+func (b *badgerdb) TruncateBucket(ctx context.Context, name libkv.BucketName) error {
+	info, nested, zeroExtended, err := b.dropBucketPrefixes(ctx, name)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "truncate bucket %s failed", name)
+	}
+	if nested || zeroExtended {
+		if err := b.deleteSharedPrefixes(ctx, name, nested, zeroExtended); err != nil {
+			return errors.Wrapf(ctx, err, "truncate bucket %s failed", name)
+		}
+		glog.V(2).Infof("truncate bucket %s in batches completed", name)
+	} else {
+		glog.V(2).Infof("truncate bucket %s completed", name)
+	}
+	if info.TrackStats {
+		return b.EnableBucketStats(ctx, name)
+	}
+	return nil
+}
+
+// dropBucketPrefixes drops the keys, index entries and stream chunks of the bucket with
+// DropPrefix unless other buckets share their prefix, and holds off update transactions
+// meanwhile. Returns whether buckets share the key prefix (nested) or continue the bucket
+// name with 0x00, so the prefix of index entries and chunks is shared (zeroExtended).
+func (b *badgerdb) dropBucketPrefixes(
+	ctx context.Context,
+	name libkv.BucketName,
+) (*BucketInfo, bool, bool, error) {
+	b.dropMux.Lock()
+	defer b.dropMux.Unlock()
+
+	var info *BucketInfo
+	var nestedPrefixes [][]byte
+	var zeroExtended bool
+	err := b.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+		badgerTx, ok := tx.(Tx)
+		if !ok {
+			return errors.Errorf(ctx, "unexpected tx type %T", tx)
+		}
+		var err error
+		if info, err = badgerTx.BucketInfo(ctx, name); err != nil {
+			return errors.Wrapf(ctx, err, "get bucket failed")
+		}
+		nestedPrefixes, err = nestedBucketPrefixes(ctx, badgerTx.Tx(), name)
+		zeroExtended = hasZeroExtendedBuckets(badgerTx.Tx(), name)
+		return err
+	})
+	if err != nil {
+		return nil, false, false, err
+	}
+	nested := len(nestedPrefixes) > 0
+	var prefixes [][]byte
+	if !nested {
+		prefixes = append(prefixes, BucketToPrefix(name))
+	}
+	if !zeroExtended {
+		for _, prefixFn := range []func(context.Context, Tuple) ([]byte, error){
+			chunkPrefix,
+			indexPrefix,
+		} {
+			prefix, err := prefixFn(ctx, Tuple{name.Bytes()})
+			if err != nil {
+				return nil, false, false, err
+			}
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) > 0 {
+		if err := b.db.DropPrefix(prefixes...); err != nil {
+			return nil, false, false, errors.Wrapf(ctx, err, "drop prefix failed")
+		}
+	}
+	return info, nested, zeroExtended, nil
+}
+
+// deleteSharedPrefixes deletes the keys of the bucket if nested, and its index entries
+// and chunks if zeroExtended, in batches that keep the keys of the other buckets.
+func (b *badgerdb) deleteSharedPrefixes(
+	ctx context.Context,
+	name libkv.BucketName,
+	nested bool,
+	zeroExtended bool,
+) error {
+	snapshot, err := b.Snapshot(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "create snapshot failed")
+	}
+	defer snapshot.Release()
+	if nested {
+		if err := b.deleteSnapshotKeys(ctx, snapshot, name); err != nil {
+			return errors.Wrapf(ctx, err, "delete keys failed")
+		}
+	}
+	if zeroExtended {
+		if err := b.deleteSnapshotTuples(ctx, snapshot, name); err != nil {
+			return errors.Wrapf(ctx, err, "delete chunks and index entries failed")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Bucket clear and truncate", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var events libkv.BucketName
+	var err error
+
+	fill := func(name libkv.BucketName, count int) {
+		err := db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, name)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < count; i++ {
+				key := []byte(fmt.Sprintf("key%04d", i))
+				if err := bucket.Put(ctx, key, []byte("value")); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		Expect(err).To(BeNil())
+	}
+
+	count := func(name libkv.BucketName) int {
+		var result int
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, name)
+			if err != nil {
+				return err
+			}
+			return libkv.ForEach(ctx, bucket, func(item libkv.Item) error {
+				result++
+				return nil
+			})
+		})
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		events = libkv.NewBucketName("events")
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		fill(events, 100)
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	Context("ClearBucket", func() {
+		It("deletes all keys but keeps the bucket and its handle", func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, events)
+				Expect(err).To(BeNil())
+				badgerTx, ok := tx.(libbadgerkv.Tx)
+				Expect(ok).To(BeTrue())
+				Expect(badgerTx.ClearBucket(ctx, events)).To(Succeed())
+				Expect(bucket.Put(ctx, []byte("new"), []byte("value"))).To(Succeed())
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(count(events)).To(Equal(1))
+		})
+
+		It("returns BucketNotFoundError for missing bucket", func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				badgerTx, ok := tx.(libbadgerkv.Tx)
+				Expect(ok).To(BeTrue())
+				return badgerTx.ClearBucket(ctx, libkv.NewBucketName("missing"))
+			})
+			Expect(errors.Is(err, libkv.BucketNotFoundError)).To(BeTrue())
+		})
+	})
+
+	Context("TruncateBucket", func() {
+		It("deletes all keys but keeps the bucket", func() {
+			other := libkv.NewBucketName("audit")
+			fill(other, 10)
+
+			Expect(db.TruncateBucket(ctx, events)).To(Succeed())
+			Expect(count(events)).To(Equal(0))
+			Expect(count(other)).To(Equal(10))
+		})
+
+		It("keeps keys of buckets sharing the prefix", func() {
+			eventsV2 := libkv.NewBucketName("events_v2")
+			fill(eventsV2, 10)
+
+			Expect(db.TruncateBucket(ctx, events)).To(Succeed())
+			err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, events)
+				Expect(err).To(BeNil())
+				item, err := bucket.Get(ctx, []byte("key0001"))
+				Expect(err).To(BeNil())
+				Expect(item.Exists()).To(BeFalse())
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(count(eventsV2)).To(Equal(10))
+		})
+
+		It("waits for open update transactions creating buckets sharing the prefix", func() {
+			eventsV2 := libkv.NewBucketName("events_v2")
+			started := make(chan struct{})
+			proceed := make(chan struct{})
+			updated := make(chan error, 1)
+			go func() {
+				updated <- db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+					bucket, err := tx.CreateBucket(ctx, eventsV2)
+					if err != nil {
+						return err
+					}
+					close(started)
+					<-proceed
+					return bucket.Put(ctx, []byte("key"), []byte("value"))
+				})
+			}()
+			<-started
+			truncated := make(chan error, 1)
+			go func() {
+				truncated <- db.TruncateBucket(ctx, events)
+			}()
+			Consistently(truncated, 50*time.Millisecond).ShouldNot(Receive())
+			close(proceed)
+
+			Expect(<-updated).To(Succeed())
+			Expect(<-truncated).To(Succeed())
+			// iterating events includes the key of events_v2
+			Expect(count(events)).To(Equal(1))
+			Expect(count(eventsV2)).To(Equal(1))
+		})
+
+		It("returns BucketNotFoundError for missing bucket", func() {
+			err = db.TruncateBucket(ctx, libkv.NewBucketName("missing"))
+			Expect(errors.Is(err, libkv.BucketNotFoundError)).To(BeTrue())
+		})
+	})
+})
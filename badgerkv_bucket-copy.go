@@ -0,0 +1,652 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// DefaultBatchSize is the max number of keys DB level bucket operations write per transaction.
+const DefaultBatchSize = 1000
+
+// DefaultBatchBytes is the max number of key and value bytes DB level bucket operations
+// write per transaction.
+const DefaultBatchBytes = 4 << 20
+
+// CopyBucket copies all keys of src into the new bucket dst.
+// Returns BucketNotFoundError if src is missing and BucketAlreadyExistsError if dst exists.
+func (t *tx) CopyBucket(ctx context.Context, src libkv.BucketName, dst libkv.BucketName) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	info, err := t.copyBucket(ctx, src, dst)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "copy bucket %s to %s failed", src, dst)
+	}
+	t.cache[dst.String()] = t.newBucket(info)
+	return nil
+}
+
+// RenameBucket moves all keys of oldName into the new bucket newName and removes oldName.
+// Returns BucketNotFoundError if oldName is missing and BucketAlreadyExistsError if
+// newName exists. Buckets with sub-buckets can not be renamed.
+func (t *tx) RenameBucket(
+	ctx context.Context,
+	oldName libkv.BucketName,
+	newName libkv.BucketName,
+) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if err := checkNoSubBuckets(ctx, t.badgerTx, oldName); err != nil {
+		return err
+	}
+	if _, err := t.copyBucket(ctx, oldName, newName); err != nil {
+		return errors.Wrapf(ctx, err, "copy bucket %s to %s failed", oldName, newName)
+	}
+	info, err := t.moveBucketInfo(ctx, oldName, newName)
+	if err != nil {
+		return err
+	}
+	err = forEachBucketKey(ctx, t.badgerTx, oldName, func(item *badger.Item) error {
+		return t.badgerTx.Delete(item.KeyCopy(nil))
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "delete keys of bucket %s failed", oldName)
+	}
+	if err := t.deleteBucket(ctx, oldName); err != nil {
+		return errors.Wrapf(ctx, err, "delete bucket %s failed", oldName)
+	}
+	delete(t.cache, oldName.String())
+	t.cache[newName.String()] = t.newBucket(info)
+	return nil
+}
+
+// copyBucket registers dst with the metadata of src and copies the keys, counters, stream
+// chunks and index entries.
+func (t *tx) copyBucket(
+	ctx context.Context,
+	src libkv.BucketName,
+	dst libkv.BucketName,
+) (*BucketInfo, error) {
+	if err := t.checkCopyBucket(ctx, src, dst); err != nil {
+		return nil, err
+	}
+	info, err := t.BucketInfo(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	counters, err := readBucketCounters(ctx, t.badgerTx, src)
+	if err != nil {
+		return nil, err
+	}
+	dstInfo, err := t.registerCopy(ctx, info, dst, counters)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "create bucket failed")
+	}
+	err = forEachBucketKey(ctx, t.badgerTx, src, func(item *badger.Item) error {
+		entry, err := newEntryFromItem(
+			BucketAddKey(dst, BucketRemoveKey(src, item.Key())),
+			item,
+		)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "copy entry failed")
+		}
+		return t.badgerTx.SetEntry(entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := copyBucketChunks(ctx, t.badgerTx, src, dst, t.badgerTx.SetEntry); err != nil {
+		return nil, errors.Wrapf(ctx, err, "copy chunks failed")
+	}
+	err = copyBucketTuples(ctx, t.badgerTx, indexBucketName, src, dst, t.badgerTx.SetEntry)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "copy index entries failed")
+	}
+	return dstInfo, nil
+}
+
+// registerCopy registers dst with the metadata of the copied bucket src and, if src is
+// tracked, the counters of the copied keys.
+func (t *tx) registerCopy(
+	ctx context.Context,
+	src *BucketInfo,
+	dst libkv.BucketName,
+	counters bucketCounters,
+) (*BucketInfo, error) {
+	info := &BucketInfo{
+		Name:       dst,
+		CreatedAt:  time.Now().UTC(),
+		TrackStats: src.TrackStats,
+		Streams:    src.Streams,
+		BucketMeta: src.BucketMeta,
+	}
+	if err := t.putBucketInfo(ctx, *info); err != nil {
+		return nil, err
+	}
+	if !info.TrackStats {
+		return info, nil
+	}
+	if err := writeBucketCounters(ctx, t.badgerTx, dst, counters); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// moveBucketInfo replaces the metadata of newName with the metadata of oldName,
+// including its creation time.
+func (t *tx) moveBucketInfo(
+	ctx context.Context,
+	oldName libkv.BucketName,
+	newName libkv.BucketName,
+) (*BucketInfo, error) {
+	info, err := t.BucketInfo(ctx, oldName)
+	if err != nil {
+		return nil, err
+	}
+	info.Name = newName
+	if err := t.putBucketInfo(ctx, *info); err != nil {
+		return nil, errors.Wrapf(
+			ctx,
+			err,
+			"move info of bucket %s to %s failed",
+			oldName,
+			newName,
+		)
+	}
+	return info, nil
+}
+
+func (t *tx) checkCopyBucket(
+	ctx context.Context,
+	src libkv.BucketName,
+	dst libkv.BucketName,
+) error {
+	exists, err := t.existsBucket(ctx, src)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "check exists failed")
+	}
+	if !exists {
+		return errors.Wrapf(ctx, libkv.BucketNotFoundError, "bucket %s not found", src)
+	}
+	exists, err = t.existsBucket(ctx, dst)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "check exists failed")
+	}
+	if exists {
+		return errors.Wrapf(
+			ctx,
+			libkv.BucketAlreadyExistsError,
+			"bucket %s already exists",
+			dst,
+		)
+	}
+	return t.checkReserved(ctx, dst)
+}
+
+// CopyBucket copies src into the new bucket dst like Tx.CopyBucket, but in batches of
+// DefaultBatchSize keys read from one snapshot, so buckets too large for a single
+// transaction can be copied. dst is registered after the last batch and is not
+// visible before, transactions of the DB can not create it meanwhile. Writes to src
+// after the copy started are not copied. The copied keys are deleted if the copy fails.
+func (b *badgerdb) CopyBucket(
+	ctx context.Context,
+	src libkv.BucketName,
+	dst libkv.BucketName,
+) error {
+	snapshot, err := b.Snapshot(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "create snapshot failed")
+	}
+	defer snapshot.Release()
+
+	err = b.copyBucketBatches(ctx, snapshot, src, dst, func(ctx context.Context) error {
+		info, counters, err := snapshotBucketInfo(ctx, snapshot, src)
+		if err != nil {
+			return err
+		}
+		err = b.updateTxn(ctx, func(ctx context.Context, txn *badger.Txn) error {
+			tx := newTx(txn)
+			if err := tx.checkCopyBucket(ctx, src, dst); err != nil {
+				return err
+			}
+			_, err := tx.registerCopy(ctx, info, dst, counters)
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(ctx, err, "register bucket %s failed", dst)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "copy bucket %s to %s failed", src, dst)
+	}
+	glog.V(2).Infof("copy bucket %s to %s completed", src, dst)
+	return nil
+}
+
+// RenameBucket renames oldName to newName like Tx.RenameBucket, but copies in batches
+// like DB.CopyBucket. newName replaces the registry entry, metadata and counters of
+// oldName in one transaction, afterwards the keys, stream chunks and index entries of
+// oldName are deleted in batches. Keys of oldName written after the rename started are
+// neither copied nor deleted. The copied keys are deleted if the rename fails before
+// the swap.
+func (b *badgerdb) RenameBucket(
+	ctx context.Context,
+	oldName libkv.BucketName,
+	newName libkv.BucketName,
+) error {
+	snapshot, err := b.Snapshot(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "create snapshot failed")
+	}
+	defer snapshot.Release()
+
+	if err := checkNoSubBuckets(ctx, snapshot.Tx(), oldName); err != nil {
+		return err
+	}
+	err = b.copyBucketBatches(ctx, snapshot, oldName, newName, func(ctx context.Context) error {
+		_, counters, err := snapshotBucketInfo(ctx, snapshot, oldName)
+		if err != nil {
+			return err
+		}
+		err = b.updateTxn(ctx, func(ctx context.Context, txn *badger.Txn) error {
+			tx := newTx(txn)
+			if _, err := tx.Bucket(ctx, oldName); err != nil {
+				return errors.Wrapf(ctx, err, "get bucket %s failed", oldName)
+			}
+			if err := checkNoSubBuckets(ctx, txn, oldName); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(ctx, newName); err != nil {
+				return errors.Wrapf(ctx, err, "create bucket %s failed", newName)
+			}
+			info, err := tx.moveBucketInfo(ctx, oldName, newName)
+			if err != nil {
+				return err
+			}
+			if info.TrackStats {
+				// the copied keys are the keys of the snapshot
+				if err := writeBucketCounters(ctx, txn, newName, counters); err != nil {
+					return err
+				}
+			}
+			// keys, chunks and index entries of oldName are deleted in batches after the swap
+			if err := tx.deleteBucketInfo(ctx, oldName); err != nil {
+				return errors.Wrapf(ctx, err, "delete info of bucket %s failed", oldName)
+			}
+			return deleteBucketCounters(ctx, txn, oldName)
+		})
+		if err != nil {
+			return errors.Wrapf(ctx, err, "swap bucket %s to %s failed", oldName, newName)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "rename bucket %s to %s failed", oldName, newName)
+	}
+	if err := b.deleteSnapshotKeys(ctx, snapshot, oldName); err != nil {
+		return errors.Wrapf(ctx, err, "delete keys of bucket %s failed", oldName)
+	}
+	if err := b.deleteSnapshotTuples(ctx, snapshot, oldName); err != nil {
+		return errors.Wrapf(ctx, err, "delete chunks and index entries of %s failed", oldName)
+	}
+	glog.V(2).Infof("rename bucket %s to %s completed", oldName, newName)
+	return nil
+}
+
+// snapshotBucketInfo returns the metadata and counters of the bucket in the snapshot.
+// It reads through the raw transaction, which pins the snapshot like the batches do, so
+// copies running longer than DefaultSnapshotMaxAge do not fail with ErrSnapshotExpired.
+func snapshotBucketInfo(
+	ctx context.Context,
+	snapshot Snapshot,
+	name libkv.BucketName,
+) (*BucketInfo, bucketCounters, error) {
+	info, err := newTx(snapshot.Tx()).BucketInfo(ctx, name)
+	if err != nil {
+		return nil, bucketCounters{}, errors.Wrapf(ctx, err, "get info of bucket %s failed", name)
+	}
+	counters, err := readBucketCounters(ctx, snapshot.Tx(), name)
+	if err != nil {
+		return nil, bucketCounters{}, err
+	}
+	return info, counters, nil
+}
+
+// copyBucketBatches reserves dst, copies src into it in batches and calls register to
+// make dst visible. The copied keys, stream chunks and index entries are deleted if the
+// copy or register fails.
+func (b *badgerdb) copyBucketBatches(
+	ctx context.Context,
+	snapshot Snapshot,
+	src libkv.BucketName,
+	dst libkv.BucketName,
+	register func(ctx context.Context) error,
+) error {
+	release, err := b.copies.Reserve(ctx, dst)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := newTx(snapshot.Tx()).checkCopyBucket(ctx, src, dst); err != nil {
+		return err
+	}
+	err = b.copyBucketEntries(ctx, snapshot, src, dst)
+	if err == nil {
+		err = register(ctx)
+	}
+	if err != nil {
+		if cleanupErr := b.deleteCopiedKeys(context.WithoutCancel(ctx), dst); cleanupErr != nil {
+			glog.Warningf("delete copied keys of bucket %s failed: %v", dst, cleanupErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// copyBucketEntries copies the keys, stream chunks and index entries of src to dst.
+func (b *badgerdb) copyBucketEntries(
+	ctx context.Context,
+	snapshot Snapshot,
+	src libkv.BucketName,
+	dst libkv.BucketName,
+) error {
+	var batch []*badger.Entry
+	var batchBytes int
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := b.updateTxn(ctx, func(ctx context.Context, txn *badger.Txn) error {
+			for _, entry := range batch {
+				if err := txn.SetEntry(entry); err != nil {
+					return errors.Wrapf(ctx, err, "set entry failed")
+				}
+			}
+			return nil
+		})
+		batch = nil
+		batchBytes = 0
+		return err
+	}
+	add := func(entry *badger.Entry) error {
+		batch = append(batch, entry)
+		batchBytes += len(entry.Key) + len(entry.Value)
+		if len(batch) >= DefaultBatchSize || batchBytes >= DefaultBatchBytes {
+			return flush()
+		}
+		return nil
+	}
+	err := forEachBucketKey(ctx, snapshot.Tx(), src, func(item *badger.Item) error {
+		entry, err := newEntryFromItem(BucketAddKey(dst, BucketRemoveKey(src, item.Key())), item)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "copy entry failed")
+		}
+		return add(entry)
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "copy keys failed")
+	}
+	if err := copyBucketChunks(ctx, snapshot.Tx(), src, dst, add); err != nil {
+		return errors.Wrapf(ctx, err, "copy chunks failed")
+	}
+	if err := copyBucketTuples(ctx, snapshot.Tx(), indexBucketName, src, dst, add); err != nil {
+		return errors.Wrapf(ctx, err, "copy index entries failed")
+	}
+	return flush()
+}
+
+// deleteCopiedKeys deletes the keys, stream chunks and index entries of a failed copy to
+// the unregistered bucket name.
+func (b *badgerdb) deleteCopiedKeys(ctx context.Context, name libkv.BucketName) error {
+	snapshot, err := b.Snapshot(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "create snapshot failed")
+	}
+	defer snapshot.Release()
+	exists, err := newTx(snapshot.Tx()).existsBucket(ctx, name)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "check exists failed")
+	}
+	if exists {
+		return errors.Wrapf(ctx, libkv.BucketAlreadyExistsError, "bucket %s registered", name)
+	}
+	if err := b.deleteSnapshotKeys(ctx, snapshot, name); err != nil {
+		return err
+	}
+	return b.deleteSnapshotTuples(ctx, snapshot, name)
+}
+
+// deleteSnapshotTuples deletes the stream chunks and index entries of the bucket as seen
+// by the snapshot in batches. Entries changed after the snapshot was taken are kept.
+func (b *badgerdb) deleteSnapshotTuples(
+	ctx context.Context,
+	snapshot Snapshot,
+	name libkv.BucketName,
+) error {
+	for _, prefixFn := range []func(context.Context, Tuple) ([]byte, error){
+		chunkPrefix,
+		indexPrefix,
+	} {
+		prefix, err := prefixFn(ctx, Tuple{name.Bytes()})
+		if err != nil {
+			return err
+		}
+		if err := b.deleteTupleKeys(ctx, snapshot, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSnapshotKeys deletes the keys of the bucket as seen by the snapshot in batches.
+// Keys changed after the snapshot was taken are kept.
+func (b *badgerdb) deleteSnapshotKeys(
+	ctx context.Context,
+	snapshot Snapshot,
+	name libkv.BucketName,
+) error {
+	deleter := b.newKeyDeleter()
+	err := forEachBucketKey(ctx, snapshot.Tx(), name, func(item *badger.Item) error {
+		return deleter.Add(ctx, item)
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "delete keys failed")
+	}
+	return deleter.Flush(ctx)
+}
+
+func (b *badgerdb) newKeyDeleter() *keyDeleter {
+	return &keyDeleter{db: b}
+}
+
+// keyDeleter deletes keys read from a snapshot in batches of DefaultBatchSize.
+// Keys changed after the snapshot was taken are kept.
+type keyDeleter struct {
+	db    *badgerdb
+	batch []versionedKey
+}
+
+type versionedKey struct {
+	key     []byte
+	version uint64
+}
+
+// Add schedules the key of item for deletion and flushes full batches.
+func (d *keyDeleter) Add(ctx context.Context, item *badger.Item) error {
+	d.batch = append(d.batch, versionedKey{key: item.KeyCopy(nil), version: item.Version()})
+	if len(d.batch) >= DefaultBatchSize {
+		return d.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush deletes the scheduled keys.
+func (d *keyDeleter) Flush(ctx context.Context) error {
+	if len(d.batch) == 0 {
+		return nil
+	}
+	err := d.db.updateTxn(ctx, func(ctx context.Context, txn *badger.Txn) error {
+		for _, k := range d.batch {
+			item, err := txn.Get(k.key)
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return errors.Wrapf(ctx, err, "get failed")
+			}
+			if item.Version() != k.version {
+				continue
+			}
+			if err := txn.Delete(k.key); err != nil {
+				return errors.Wrapf(ctx, err, "delete failed")
+			}
+		}
+		return nil
+	})
+	d.batch = nil
+	return err
+}
+
+// copyBucketTuples calls fn with copies of the entries of the internal bucket whose
+// packed tuple starts with the name src, with src replaced by dst.
+func copyBucketTuples(
+	ctx context.Context,
+	txn *badger.Txn,
+	internal libkv.BucketName,
+	src libkv.BucketName,
+	dst libkv.BucketName,
+	fn func(entry *badger.Entry) error,
+) error {
+	packed, err := Tuple{src.Bytes()}.Pack(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "pack prefix failed")
+	}
+	prefix := BucketAddKey(internal, packed)
+	return forEachTupleKey(ctx, txn, prefix, func(item *badger.Item) error {
+		tuple, err := UnpackTuple(ctx, BucketRemoveKey(internal, item.Key()))
+		if err != nil {
+			return errors.Wrapf(ctx, err, "unpack key failed")
+		}
+		tuple[0] = dst.Bytes()
+		packed, err := tuple.Pack(ctx)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "pack key failed")
+		}
+		entry, err := newEntryFromItem(BucketAddKey(internal, packed), item)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "copy entry failed")
+		}
+		return fn(entry)
+	})
+}
+
+// newEntryFromItem copies value, user meta and expiry of item into a new entry for key.
+func newEntryFromItem(key []byte, item *badger.Item) (*badger.Entry, error) {
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	entry := badger.NewEntry(key, value).WithMeta(item.UserMeta())
+	entry.ExpiresAt = item.ExpiresAt()
+	return entry, nil
+}
+
+func newBucketReservations() *bucketReservations {
+	return &bucketReservations{
+		names: make(map[string]struct{}),
+	}
+}
+
+// bucketReservations are the buckets DB.CopyBucket and DB.RenameBucket write to before
+// registering them. Transactions of the DB can not create them meanwhile.
+type bucketReservations struct {
+	mux   sync.Mutex
+	names map[string]struct{}
+
+	// held are the names reserved since the first open Hold, kept after their release
+	holds int
+	held  map[string]struct{}
+}
+
+// Reserve reserves name and returns the func releasing it.
+// Returns BucketAlreadyExistsError if name is reserved.
+func (r *bucketReservations) Reserve(ctx context.Context, name libkv.BucketName) (func(), error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.names[name.String()]; ok {
+		return nil, errors.Wrapf(ctx, libkv.BucketAlreadyExistsError, "bucket %s reserved", name)
+	}
+	r.names[name.String()] = struct{}{}
+	if r.held != nil {
+		r.held[name.String()] = struct{}{}
+	}
+	return func() {
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		delete(r.names, name.String())
+	}, nil
+}
+
+// Hold records the reserved names until the returned func is called, including the names
+// reserved and released meanwhile. Held returns whether a name is recorded.
+func (r *bucketReservations) Hold() func() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.holds == 0 {
+		r.held = make(map[string]struct{}, len(r.names))
+		for name := range r.names {
+			r.held[name] = struct{}{}
+		}
+	}
+	r.holds++
+	return func() {
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		r.holds--
+		if r.holds == 0 {
+			r.held = nil
+		}
+	}
+}
+
+// Held returns true if name was reserved since the first open Hold, or, if prefix is set,
+// if the key prefix of such a name is a prefix of name.
+func (r *bucketReservations) Held(name []byte, prefix bool) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.held[string(name)]; ok {
+		return true
+	}
+	if !prefix {
+		return false
+	}
+	for held := range r.held {
+		if bytes.HasPrefix(name, BucketToPrefix(libkv.BucketName(held))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns BucketAlreadyExistsError if name is reserved.
+func (r *bucketReservations) Check(ctx context.Context, name libkv.BucketName) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.names[name.String()]; ok {
+		return errors.Wrapf(ctx, libkv.BucketAlreadyExistsError, "bucket %s reserved", name)
+	}
+	return nil
+}
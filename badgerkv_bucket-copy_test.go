@@ -0,0 +1,327 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Bucket copy and rename", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var users libkv.BucketName
+	var usersV2 libkv.BucketName
+	var usersArchive libkv.BucketName
+	var usersCopy libkv.BucketName
+	var err error
+
+	keys := func(name libkv.BucketName) []string {
+		var result []string
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, name)
+			if err != nil {
+				return err
+			}
+			return libkv.ForEach(ctx, bucket, func(item libkv.Item) error {
+				result = append(result, string(item.Key()))
+				return nil
+			})
+		})
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	exists := func(name libkv.BucketName, key string) bool {
+		var result bool
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, name)
+			if err != nil {
+				return err
+			}
+			item, err := bucket.Get(ctx, []byte(key))
+			result = item.Exists()
+			return err
+		})
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	bucketNames := func() []string {
+		var result []string
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			names, err := tx.ListBucketNames(ctx)
+			for _, name := range names {
+				result = append(result, name.String())
+			}
+			return err
+		})
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		users = libkv.NewBucketName("users")
+		usersV2 = libkv.NewBucketName("users_v2")
+		usersArchive = libkv.NewBucketName("users_archive")
+		usersCopy = libkv.NewBucketName("users-copy")
+
+		err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucket(ctx, users)
+			Expect(err).To(BeNil())
+			Expect(bucket.Put(ctx, []byte("1"), []byte("alice"))).To(Succeed())
+			Expect(bucket.Put(ctx, []byte("2"), []byte("bob"))).To(Succeed())
+			archive, err := tx.CreateBucket(ctx, usersArchive)
+			Expect(err).To(BeNil())
+			return archive.Put(ctx, []byte("3"), []byte("carol"))
+		})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	Context("Tx.CopyBucket", func() {
+		It("copies keys", func() {
+			Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				return tx.CopyBucket(ctx, users, usersCopy)
+			})).To(Succeed())
+			Expect(keys(users)).To(Equal([]string{"1", "2", "archive_3"}))
+			Expect(keys(usersCopy)).To(Equal([]string{"1", "2"}))
+		})
+
+		It("skips keys of buckets sharing the prefix", func() {
+			Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				return tx.CopyBucket(ctx, users, usersV2)
+			})).To(Succeed())
+			Expect(exists(usersV2, "1")).To(BeTrue())
+			Expect(exists(usersV2, "archive_3")).To(BeFalse())
+			Expect(exists(usersArchive, "3")).To(BeTrue())
+		})
+
+		It("returns copied bucket from cache", func() {
+			Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				Expect(tx.CopyBucket(ctx, users, usersV2)).To(Succeed())
+				bucket, err := tx.Bucket(ctx, usersV2)
+				Expect(err).To(BeNil())
+				item, err := bucket.Get(ctx, []byte("1"))
+				Expect(err).To(BeNil())
+				Expect(item.Exists()).To(BeTrue())
+				return nil
+			})).To(Succeed())
+		})
+
+		It("fails if destination exists", func() {
+			err = updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				return tx.CopyBucket(ctx, users, usersArchive)
+			})
+			Expect(errors.Is(err, libkv.BucketAlreadyExistsError)).To(BeTrue())
+		})
+
+		It("fails if source is missing", func() {
+			err = updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				return tx.CopyBucket(ctx, libkv.NewBucketName("missing"), usersV2)
+			})
+			Expect(errors.Is(err, libkv.BucketNotFoundError)).To(BeTrue())
+		})
+	})
+
+	Context("Tx.RenameBucket", func() {
+		It("moves keys and registry entry", func() {
+			Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				return tx.RenameBucket(ctx, users, usersV2)
+			})).To(Succeed())
+			Expect(bucketNames()).To(ConsistOf("users_v2", "users_archive"))
+			Expect(exists(usersV2, "1")).To(BeTrue())
+			Expect(exists(usersV2, "2")).To(BeTrue())
+			Expect(exists(usersArchive, "3")).To(BeTrue())
+		})
+
+		It("swaps buckets in one transaction", func() {
+			Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				Expect(tx.CopyBucket(ctx, users, usersV2)).To(Succeed())
+				bucket, err := tx.Bucket(ctx, usersV2)
+				Expect(err).To(BeNil())
+				return bucket.Put(ctx, []byte("4"), []byte("dave"))
+			})).To(Succeed())
+
+			Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				Expect(tx.DeleteBucket(ctx, users)).To(Succeed())
+				return tx.RenameBucket(ctx, usersV2, users)
+			})).To(Succeed())
+			Expect(bucketNames()).To(ConsistOf("users", "users_archive"))
+			Expect(keys(users)).To(Equal([]string{"1", "2", "4", "archive_3"}))
+			Expect(exists(usersArchive, "3")).To(BeTrue())
+		})
+	})
+
+	Context("DB level", func() {
+		var large libkv.BucketName
+		var count int
+
+		BeforeEach(func() {
+			large = libkv.NewBucketName("large")
+			count = libbadgerkv.DefaultBatchSize*2 + 17
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.CreateBucket(ctx, large)
+				Expect(err).To(BeNil())
+				for i := 0; i < count; i++ {
+					key := []byte(fmt.Sprintf("%05d", i))
+					Expect(bucket.Put(ctx, key, []byte("value"))).To(Succeed())
+				}
+				return nil
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("copies bucket in batches", func() {
+			Expect(db.CopyBucket(ctx, large, libkv.NewBucketName("large-v2"))).To(Succeed())
+			Expect(keys(large)).To(HaveLen(count))
+			Expect(keys(libkv.NewBucketName("large-v2"))).To(HaveLen(count))
+		})
+
+		It("renames bucket in batches", func() {
+			Expect(db.RenameBucket(ctx, large, libkv.NewBucketName("huge"))).To(Succeed())
+			Expect(bucketNames()).To(ConsistOf("users", "users_archive", "huge"))
+			Expect(keys(libkv.NewBucketName("huge"))).To(HaveLen(count))
+
+			err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				badgerTx, ok := tx.(libbadgerkv.Tx)
+				Expect(ok).To(BeTrue())
+				it := badgerTx.Tx().NewIterator(badger.DefaultIteratorOptions)
+				defer it.Close()
+				prefix := libbadgerkv.BucketToPrefix(large)
+				for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+					Fail(fmt.Sprintf("key %s left", it.Item().Key()))
+				}
+				return nil
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("keeps other transactions from creating the destination", func() {
+			dst := libkv.NewBucketName("large-v2")
+			var createErr error
+			hookCtx := &hookContext{
+				Context: ctx,
+				n:       libbadgerkv.DefaultBatchSize + 5,
+				hook: func() {
+					createErr = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+						_, err := tx.CreateBucketIfNotExists(ctx, dst)
+						return err
+					})
+				},
+			}
+			Expect(db.CopyBucket(hookCtx, large, dst)).To(Succeed())
+			Expect(errors.Is(createErr, libkv.BucketAlreadyExistsError)).To(BeTrue())
+			Expect(keys(dst)).To(HaveLen(count))
+		})
+
+		It("deletes the copied keys of a failed copy", func() {
+			dst := libkv.NewBucketName("large-v2")
+			cancelCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			hookCtx := &hookContext{
+				Context: cancelCtx,
+				n:       libbadgerkv.DefaultBatchSize + 5,
+				hook:    cancel,
+			}
+			Expect(db.CopyBucket(hookCtx, large, dst)).NotTo(Succeed())
+
+			report, err := db.Verify(ctx)
+			Expect(err).To(BeNil())
+			Expect(report.OrphanedKeys).To(BeZero())
+			Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				_, err := tx.CreateBucket(ctx, dst)
+				return err
+			})).To(Succeed())
+			Expect(keys(dst)).To(BeEmpty())
+		})
+
+		It("fails if destination exists", func() {
+			err = db.CopyBucket(ctx, large, users)
+			Expect(errors.Is(err, libkv.BucketAlreadyExistsError)).To(BeTrue())
+		})
+
+		It("fails if source is missing", func() {
+			err = db.RenameBucket(ctx, libkv.NewBucketName("missing"), usersV2)
+			Expect(errors.Is(err, libkv.BucketNotFoundError)).To(BeTrue())
+		})
+
+		It("deletes the chunks and index entries of the renamed bucket", func() {
+			index := libbadgerkv.Index{
+				Name: "value",
+				Func: func(ctx context.Context, value []byte) ([][]byte, error) {
+					return [][]byte{value}, nil
+				},
+			}
+			Expect(db.RegisterIndex(ctx, users, index)).To(Succeed())
+			Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, users)
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put(ctx, []byte("4"), []byte("dave")); err != nil {
+					return err
+				}
+				content := bytes.NewReader(make([]byte, 2*libbadgerkv.DefaultStreamChunkSize))
+				return bucket.(libbadgerkv.Bucket).PutStream(ctx, []byte("file"), content)
+			})).To(Succeed())
+			countRaw := func(prefix string) int {
+				var result int
+				err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+					badgerTx, ok := tx.(libbadgerkv.Tx)
+					Expect(ok).To(BeTrue())
+					opts := badger.DefaultIteratorOptions
+					opts.Prefix = []byte(prefix)
+					it := badgerTx.Tx().NewIterator(opts)
+					defer it.Close()
+					for it.Rewind(); it.Valid(); it.Next() {
+						result++
+					}
+					return nil
+				})
+				Expect(err).To(BeNil())
+				return result
+			}
+			indexEntries := countRaw("__index_")
+			chunks := countRaw("__chunk_")
+			Expect(indexEntries).To(BeNumerically(">", 0))
+			Expect(chunks).To(BeNumerically(">", 0))
+
+			Expect(db.RenameBucket(ctx, users, usersV2)).To(Succeed())
+			Expect(countRaw("__index_")).To(Equal(indexEntries))
+			Expect(countRaw("__chunk_")).To(Equal(chunks))
+		})
+	})
+})
+
+// hookContext calls hook on the nth call of Done.
+type hookContext struct {
+	context.Context
+	n    int
+	hook func()
+}
+
+func (c *hookContext) Done() <-chan struct{} {
+	c.n--
+	if c.n == 0 {
+		c.hook()
+	}
+	return c.Context.Done()
+}
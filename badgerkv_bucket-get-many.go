@@ -0,0 +1,99 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// GetMany returns the items for keys in input order. Missing keys are returned
+// like Get does, as libkv.NewByteItem(key, nil). Keys are looked up in sorted order
+// with a single iterator, which moves forward instead of seeking when keys are adjacent.
+func (b *bucket) GetMany(ctx context.Context, keys [][]byte) ([]libkv.Item, error) {
+	done, err := b.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return bytes.Compare(keys[order[i]], keys[order[j]]) < 0
+	})
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = BucketToPrefix(b.bucketName)
+	it := b.badgerTx.NewIterator(opts)
+	defer it.Close()
+
+	result := make([]libkv.Item, len(keys))
+	var previous libkv.Item
+	for n, i := range order {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx, ctx.Err(), "context cancelled")
+		default:
+		}
+
+		key := keys[i]
+		if n > 0 && bytes.Equal(key, keys[order[n-1]]) {
+			result[i] = previous
+			continue
+		}
+		fullKey := BucketAddKey(b.bucketName, key)
+		if it.Valid() && bytes.Compare(it.Item().Key(), fullKey) < 0 {
+			it.Next()
+		}
+		if !it.Valid() || bytes.Compare(it.Item().Key(), fullKey) < 0 {
+			it.Seek(fullKey)
+		}
+		if !it.Valid() || !bytes.Equal(it.Item().Key(), fullKey) {
+			previous = libkv.NewByteItem(key, nil)
+			result[i] = previous
+			continue
+		}
+		value, err := b.values().ItemValueCopy(ctx, b.bucketName, it.Item())
+		if err != nil {
+			return nil, errors.Wrapf(ctx, err, "copy value of key %s failed", key)
+		}
+		previous = newValueItem(key, value)
+		result[i] = previous
+	}
+	return result, nil
+}
+
+func newValueItem(key []byte, value []byte) libkv.Item {
+	return &valueItem{
+		key:   key,
+		value: value,
+	}
+}
+
+// valueItem is an existing item whose value was copied out of an iterator.
+type valueItem struct {
+	key   []byte
+	value []byte
+}
+
+func (v *valueItem) Exists() bool {
+	return true
+}
+
+func (v *valueItem) Key() []byte {
+	return v.key
+}
+
+func (v *valueItem) Value(fn func(val []byte) error) error {
+	return fn(v.value)
+}
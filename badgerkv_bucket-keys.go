@@ -0,0 +1,97 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// forEachBucketKey calls fn for every key stored in the bucket. Keys of buckets whose
+// name starts with name and the separator (users_v2 for users) share the prefix of
+// name and are skipped. The item is only valid until fn returns.
+func forEachBucketKey(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	name libkv.BucketName,
+	fn func(item *badger.Item) error,
+) error {
+	nestedPrefixes, err := nestedBucketPrefixes(ctx, badgerTx, name)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "get nested bucket prefixes of %s failed", name)
+	}
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = 10
+	opts.Prefix = BucketToPrefix(name)
+	it := badgerTx.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx, ctx.Err(), "context cancelled")
+		default:
+		}
+
+		if hasAnyPrefix(it.Item().Key(), nestedPrefixes) {
+			continue
+		}
+		if err := fn(it.Item()); err != nil {
+			return errors.Wrapf(ctx, err, "fn failed")
+		}
+	}
+	return nil
+}
+
+// nestedBucketPrefixes returns the key prefixes of registered buckets
+// whose name starts with name and the separator.
+func nestedBucketPrefixes(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	name libkv.BucketName,
+) ([][]byte, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = BucketAddKey(bucketRegistryName, BucketToPrefix(name))
+	it := badgerTx.NewIterator(opts)
+	defer it.Close()
+	var result [][]byte
+	for it.Rewind(); it.Valid(); it.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx, ctx.Err(), "context cancelled")
+		default:
+		}
+
+		other := BucketRemoveKey(bucketRegistryName, it.Item().KeyCopy(nil))
+		result = append(result, BucketToPrefix(other))
+	}
+	return result, nil
+}
+
+// hasZeroExtendedBuckets returns true if a registered bucket name starts with name and
+// 0x00. The packed tuples of their index entries and chunks start with the packed tuple
+// of name, so they can not be dropped by prefix.
+func hasZeroExtendedBuckets(badgerTx *badger.Txn, name libkv.BucketName) bool {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = BucketAddKey(bucketRegistryName, append(bytes.Clone(name), 0x00))
+	it := badgerTx.NewIterator(opts)
+	defer it.Close()
+	it.Rewind()
+	return it.Valid()
+}
+
+func hasAnyPrefix(key []byte, prefixes [][]byte) bool {
+	for _, prefix := range prefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+
+	"github.com/bborbe/errors"
+)
+
+// validateLimits returns an error for negative limits and for a quota of a bucket
+// without maintained counters.
+func validateLimits(ctx context.Context, info BucketInfo, meta BucketMeta) error {
+	if meta.MaxKeySize < 0 || meta.MaxValueSize < 0 || meta.MaxBytes < 0 {
+		return errors.Errorf(ctx, "limits of bucket %s must not be negative", info.Name)
+	}
+	if meta.MaxBytes > 0 && !info.TrackStats {
+		return errors.Errorf(
+			ctx,
+			"quota of bucket %s requires stats, call EnableBucketStats first",
+			info.Name,
+		)
+	}
+	return nil
+}
+
+// checkKeySize returns ErrKeyTooLarge if key exceeds BucketMeta.MaxKeySize.
+func (b *bucket) checkKeySize(ctx context.Context, key []byte) error {
+	if b.meta.MaxKeySize > 0 && len(key) > b.meta.MaxKeySize {
+		return errors.Wrapf(
+			ctx,
+			ErrKeyTooLarge,
+			"key of %d bytes exceeds max %d of bucket %s",
+			len(key),
+			b.meta.MaxKeySize,
+			b.bucketName,
+		)
+	}
+	return nil
+}
+
+// checkValueSize returns ErrValueTooLarge if value exceeds BucketMeta.MaxValueSize.
+func (b *bucket) checkValueSize(ctx context.Context, key []byte, value []byte) error {
+	if b.meta.MaxValueSize > 0 && len(value) > b.meta.MaxValueSize {
+		return errors.Wrapf(
+			ctx,
+			ErrValueTooLarge,
+			"value of %d bytes for key %s exceeds max %d of bucket %s",
+			len(value),
+			key,
+			b.meta.MaxValueSize,
+			b.bucketName,
+		)
+	}
+	return nil
+}
+
+// streamQuota returns a check failing with ErrQuotaExceeded once a stream of written
+// bytes at key would grow the bucket beyond BucketMeta.MaxBytes, so PutStream stops
+// before writing all chunks. The counters are read once, putManifest checks again.
+func (b *bucket) streamQuota(ctx context.Context, key []byte) (func(written int64) error, error) {
+	if b.meta.MaxBytes <= 0 {
+		return func(int64) error { return nil }, nil
+	}
+	oldSize, exists, err := b.valueSize(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	counters, err := readBucketCounters(ctx, b.badgerTx, b.bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return func(written int64) error {
+		delta := bucketCounters{Bytes: written - oldSize}
+		if !exists {
+			delta.Bytes = written + int64(len(key))
+		}
+		return b.checkQuota(ctx, key, counters, delta)
+	}, nil
+}
+
+// checkQuota returns ErrQuotaExceeded if adding delta to counters grows the bytes of
+// the bucket beyond BucketMeta.MaxBytes. Writes shrinking the bucket are always allowed.
+func (b *bucket) checkQuota(
+	ctx context.Context,
+	key []byte,
+	counters bucketCounters,
+	delta bucketCounters,
+) error {
+	if b.meta.MaxBytes <= 0 || delta.Bytes <= 0 || counters.Bytes+delta.Bytes <= b.meta.MaxBytes {
+		return nil
+	}
+	return errors.Wrapf(
+		ctx,
+		ErrQuotaExceeded,
+		"write of key %s grows bucket %s to %d bytes, max %d",
+		key,
+		b.bucketName,
+		counters.Bytes+delta.Bytes,
+		b.meta.MaxBytes,
+	)
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Bucket limits", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var tenant libkv.BucketName
+	var err error
+
+	del := func(key string) error {
+		return updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, tenant)
+			if err != nil {
+				return err
+			}
+			return bucket.Delete(ctx, []byte(key))
+		})
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		tenant = libkv.NewBucketName("tenant")
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			_, err := tx.CreateBucket(ctx, tenant)
+			return err
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("rejects keys larger than MaxKeySize", func() {
+		Expect(setBucketMeta(ctx, db, tenant, libbadgerkv.BucketMeta{MaxKeySize: 4})).To(Succeed())
+		Expect(putValue(ctx, db, tenant, "1234", []byte("value"))).To(Succeed())
+		err = putValue(ctx, db, tenant, "12345", []byte("value"))
+		Expect(errors.Is(err, libbadgerkv.ErrKeyTooLarge)).To(BeTrue())
+	})
+
+	It("rejects values larger than MaxValueSize", func() {
+		meta := libbadgerkv.BucketMeta{MaxValueSize: 5}
+		Expect(setBucketMeta(ctx, db, tenant, meta)).To(Succeed())
+		Expect(putValue(ctx, db, tenant, "1", []byte("12345"))).To(Succeed())
+		err = putValue(ctx, db, tenant, "1", []byte("123456"))
+		Expect(errors.Is(err, libbadgerkv.ErrValueTooLarge)).To(BeTrue())
+	})
+
+	It("rejects streams with keys larger than MaxKeySize", func() {
+		Expect(setBucketMeta(ctx, db, tenant, libbadgerkv.BucketMeta{MaxKeySize: 4})).To(Succeed())
+		err = db.PutStream(ctx, tenant, []byte("12345"), bytes.NewReader([]byte("content")))
+		Expect(errors.Is(err, libbadgerkv.ErrKeyTooLarge)).To(BeTrue())
+	})
+
+	It("requires stats for MaxBytes", func() {
+		err = setBucketMeta(ctx, db, tenant, libbadgerkv.BucketMeta{MaxBytes: 100})
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("rejects negative limits", func() {
+		err = setBucketMeta(ctx, db, tenant, libbadgerkv.BucketMeta{MaxKeySize: -1})
+		Expect(err).NotTo(BeNil())
+	})
+
+	Context("with quota", func() {
+		BeforeEach(func() {
+			Expect(db.EnableBucketStats(ctx, tenant)).To(Succeed())
+			meta := libbadgerkv.BucketMeta{MaxBytes: 20}
+			Expect(setBucketMeta(ctx, db, tenant, meta)).To(Succeed())
+		})
+
+		It("rejects writes beyond MaxBytes", func() {
+			Expect(putValue(ctx, db, tenant, "1", []byte("123456789"))).To(Succeed())
+			Expect(putValue(ctx, db, tenant, "2", []byte("123456789"))).To(Succeed())
+			err = putValue(ctx, db, tenant, "3", []byte("1"))
+			Expect(errors.Is(err, libbadgerkv.ErrQuotaExceeded)).To(BeTrue())
+		})
+
+		It("allows writes shrinking the bucket", func() {
+			Expect(putValue(ctx, db, tenant, "1", []byte("123456789"))).To(Succeed())
+			Expect(putValue(ctx, db, tenant, "2", []byte("123456789"))).To(Succeed())
+			Expect(putValue(ctx, db, tenant, "2", []byte("1"))).To(Succeed())
+			Expect(putValue(ctx, db, tenant, "3", []byte("1234567"))).To(Succeed())
+		})
+
+		It("allows writes after deletes", func() {
+			Expect(putValue(ctx, db, tenant, "1", []byte("123456789"))).To(Succeed())
+			Expect(putValue(ctx, db, tenant, "2", []byte("123456789"))).To(Succeed())
+			Expect(del("1")).To(Succeed())
+			Expect(putValue(ctx, db, tenant, "3", []byte("123456789"))).To(Succeed())
+		})
+
+		It("counts the content of streams", func() {
+			content := bytes.NewReader(make([]byte, 100))
+			err = db.PutStream(ctx, tenant, []byte("1"), content)
+			Expect(errors.Is(err, libbadgerkv.ErrQuotaExceeded)).To(BeTrue())
+		})
+
+		It("stops streams at MaxBytes before reading all content", func() {
+			content := &countingReader{
+				r: bytes.NewReader(make([]byte, 3*libbadgerkv.DefaultStreamChunkSize)),
+			}
+			err = db.PutStream(ctx, tenant, []byte("1"), content)
+			Expect(errors.Is(err, libbadgerkv.ErrQuotaExceeded)).To(BeTrue())
+			Expect(content.n).To(BeNumerically("<=", libbadgerkv.DefaultStreamChunkSize))
+		})
+
+		It("stops bucket streams at MaxBytes", func() {
+			content := &countingReader{
+				r: bytes.NewReader(make([]byte, 3*libbadgerkv.DefaultStreamChunkSize)),
+			}
+			err = updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, tenant)
+				if err != nil {
+					return err
+				}
+				return bucket.(libbadgerkv.Bucket).PutStream(ctx, []byte("1"), content)
+			})
+			Expect(errors.Is(err, libbadgerkv.ErrQuotaExceeded)).To(BeTrue())
+			Expect(content.n).To(BeNumerically("<=", libbadgerkv.DefaultStreamChunkSize))
+		})
+
+		It("keeps stats enabled", func() {
+			err = db.DisableBucketStats(ctx, tenant)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// legacyBucketValue is the registry value of all buckets. Older versions only accept it,
+// so the metadata is stored in bucketMetaName.
+var legacyBucketValue = []byte("true")
+
+// bucketMetaName is the internal bucket holding the metadata of buckets as JSON. Its
+// keys must not start with the registry prefix __bucket_, so it is not __bucket_meta.
+var bucketMetaName = libkv.NewBucketName("__meta")
+
+// BucketMeta is the metadata of a bucket that can be changed with Tx.SetBucketMeta.
+type BucketMeta struct {
+	// SchemaVersion is the version of the schema the values of the bucket are written with.
+	// DB.UpgradeBucket sets it after all values are upgraded.
+	SchemaVersion uint32 `json:"schema_version,omitempty"`
+	// Owner labels the service or team owning the bucket.
+	Owner string `json:"owner,omitempty"`
+	// TTL is the intended time to live of the keys in the bucket, zero if unlimited.
+	TTL time.Duration `json:"ttl,omitempty"`
+	// Compression is the compression of values written with Bucket.Put,
+	// CompressionSnappy or CompressionZstd. Values stay readable if it is changed.
+	Compression string `json:"compression,omitempty"`
+	// CompressionDict is the id of the zstd dictionary registered with
+	// DB.RegisterCompressionDict, zero for none.
+	CompressionDict uint32 `json:"compression_dict,omitempty"`
+	// Checksum is the checksum stored with values written with Bucket.Put, ChecksumCRC32C
+	// or none. Values are verified on read if they were written with a checksum.
+	Checksum string `json:"checksum,omitempty"`
+	// MaxKeySize is the max length of keys written to the bucket, zero if unlimited.
+	MaxKeySize int `json:"max_key_size,omitempty"`
+	// MaxValueSize is the max length of values written with Bucket.Put before
+	// compression, zero if unlimited. Streams are only limited by MaxBytes.
+	MaxValueSize int `json:"max_value_size,omitempty"`
+	// MaxBytes is the max sum of key and stored value lengths of the bucket, zero if
+	// unlimited. It is checked against the maintained counters and requires
+	// DB.EnableBucketStats.
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+}
+
+// BucketInfo is the metadata of a bucket stored next to the bucket registry.
+// Buckets without metadata, created before it was added or by older versions, have
+// zero values and CreatedAt is zero.
+type BucketInfo struct {
+	Name      libkv.BucketName `json:"-"`
+	CreatedAt time.Time        `json:"created_at,omitzero"`
+	// TrackStats is set by DB.EnableBucketStats and makes Put and Delete maintain the
+	// key and byte counters of the bucket.
+	TrackStats bool `json:"track_stats,omitempty"`
+	// Streams is set by the first PutStream and makes Put and Delete delete the chunks
+	// of the streams they replace.
+	Streams bool `json:"streams,omitempty"`
+	BucketMeta
+}
+
+// BucketInfo returns the metadata of the bucket.
+// Returns BucketNotFoundError if the bucket does not exist.
+func (t *tx) BucketInfo(ctx context.Context, name libkv.BucketName) (*BucketInfo, error) {
+	done, err := t.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	info, err := t.bucketInfo(ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "get info of bucket %s failed", name)
+	}
+	if info == nil {
+		return nil, errors.Wrapf(ctx, libkv.BucketNotFoundError, "bucket %s not found", name)
+	}
+	return info, nil
+}
+
+// SetBucketMeta replaces the metadata of the bucket. CreatedAt, TrackStats and Streams
+// are kept.
+// Returns BucketNotFoundError if the bucket does not exist. Bucket handles returned
+// before keep the old metadata.
+func (t *tx) SetBucketMeta(ctx context.Context, name libkv.BucketName, meta BucketMeta) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if err := validateCompression(ctx, meta); err != nil {
+		return err
+	}
+	if err := validateChecksum(ctx, meta); err != nil {
+		return err
+	}
+	info, err := t.BucketInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := validateLimits(ctx, *info, meta); err != nil {
+		return err
+	}
+	info.BucketMeta = meta
+	if err := t.putBucketInfo(ctx, *info); err != nil {
+		return errors.Wrapf(ctx, err, "set meta of bucket %s failed", name)
+	}
+	if _, ok := t.cache[name.String()]; ok {
+		t.cache[name.String()] = t.newBucket(info)
+	}
+	return nil
+}
+
+// bucketInfo returns the metadata of the bucket or nil if it does not exist.
+func (t *tx) bucketInfo(ctx context.Context, name libkv.BucketName) (*BucketInfo, error) {
+	item, err := t.badgerTx.Get(BucketAddKey(t.bucketName, name))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(ctx, err, "get failed")
+	}
+	var info *BucketInfo
+	err = item.Value(func(val []byte) error {
+		info, err = parseBucketInfo(ctx, name, val)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "value failed")
+	}
+	item, err = t.badgerTx.Get(BucketAddKey(bucketMetaName, name))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return info, nil
+		}
+		return nil, errors.Wrapf(ctx, err, "get meta failed")
+	}
+	err = item.Value(func(val []byte) error {
+		info, err = parseBucketInfo(ctx, name, val)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "meta value failed")
+	}
+	return info, nil
+}
+
+// putBucketInfo registers the bucket with the legacy value and stores its metadata.
+func (t *tx) putBucketInfo(ctx context.Context, info BucketInfo) error {
+	value, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "marshal info failed")
+	}
+	if err := t.badgerTx.Set(BucketAddKey(t.bucketName, info.Name), legacyBucketValue); err != nil {
+		return errors.Wrapf(ctx, err, "put failed")
+	}
+	if err := t.badgerTx.Set(BucketAddKey(bucketMetaName, info.Name), value); err != nil {
+		return errors.Wrapf(ctx, err, "put meta failed")
+	}
+	return nil
+}
+
+// deleteBucketInfo removes the bucket from the registry and deletes its metadata.
+func (t *tx) deleteBucketInfo(ctx context.Context, name libkv.BucketName) error {
+	if err := t.badgerTx.Delete(BucketAddKey(t.bucketName, name)); err != nil {
+		return errors.Wrapf(ctx, err, "delete failed")
+	}
+	if err := t.badgerTx.Delete(BucketAddKey(bucketMetaName, name)); err != nil {
+		return errors.Wrapf(ctx, err, "delete meta failed")
+	}
+	return nil
+}
+
+// parseBucketInfo parses a registry value, which is the legacy "true" or JSON written
+// by earlier versions, or a metadata value.
+func parseBucketInfo(
+	ctx context.Context,
+	name libkv.BucketName,
+	value []byte,
+) (*BucketInfo, error) {
+	if bytes.Equal(value, legacyBucketValue) {
+		return &BucketInfo{Name: name}, nil
+	}
+	var info BucketInfo
+	if err := json.Unmarshal(value, &info); err != nil {
+		return nil, errors.Wrapf(ctx, err, "unmarshal info of bucket %s failed", name)
+	}
+	info.Name = name
+	return &info, nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Bucket meta", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var orders libkv.BucketName
+	var meta libbadgerkv.BucketMeta
+	var err error
+
+	bucketInfo := func(name libkv.BucketName) (*libbadgerkv.BucketInfo, error) {
+		var result *libbadgerkv.BucketInfo
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			badgerTx, ok := tx.(libbadgerkv.Tx)
+			Expect(ok).To(BeTrue())
+			var err error
+			result, err = badgerTx.BucketInfo(ctx, name)
+			return err
+		})
+		return result, err
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		orders = libkv.NewBucketName("orders")
+		meta = libbadgerkv.BucketMeta{
+			SchemaVersion: 3,
+			Owner:         "billing",
+			TTL:           time.Hour,
+			Compression:   "zstd",
+		}
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			_, err := tx.CreateBucket(ctx, orders)
+			return err
+		})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("records the creation time", func() {
+		info, err := bucketInfo(orders)
+		Expect(err).To(BeNil())
+		Expect(info.Name).To(Equal(orders))
+		Expect(info.CreatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+		Expect(info.BucketMeta).To(Equal(libbadgerkv.BucketMeta{}))
+	})
+
+	It("sets meta and keeps the creation time", func() {
+		before, err := bucketInfo(orders)
+		Expect(err).To(BeNil())
+
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			return tx.SetBucketMeta(ctx, orders, meta)
+		})).To(Succeed())
+
+		info, err := bucketInfo(orders)
+		Expect(err).To(BeNil())
+		Expect(info.BucketMeta).To(Equal(meta))
+		Expect(info.CreatedAt).To(BeTemporally("==", before.CreatedAt))
+	})
+
+	It("reads buckets registered without meta", func() {
+		legacy := libkv.NewBucketName("legacy")
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			key := libbadgerkv.BucketAddKey(libkv.NewBucketName("__bucket"), legacy)
+			return tx.Tx().Set(key, []byte("true"))
+		})).To(Succeed())
+
+		info, err := bucketInfo(legacy)
+		Expect(err).To(BeNil())
+		Expect(info.CreatedAt.IsZero()).To(BeTrue())
+
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			return tx.SetBucketMeta(ctx, legacy, meta)
+		})).To(Succeed())
+		info, err = bucketInfo(legacy)
+		Expect(err).To(BeNil())
+		Expect(info.BucketMeta).To(Equal(meta))
+	})
+
+	It("keeps the registry value readable by older versions", func() {
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			return tx.SetBucketMeta(ctx, orders, meta)
+		})).To(Succeed())
+
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			key := libbadgerkv.BucketAddKey(libkv.NewBucketName("__bucket"), orders)
+			item, err := tx.Tx().Get(key)
+			Expect(err).To(BeNil())
+			return item.Value(func(val []byte) error {
+				Expect(string(val)).To(Equal("true"))
+				return nil
+			})
+		})).To(Succeed())
+	})
+
+	It("returns BucketNotFoundError for missing bucket", func() {
+		_, err := bucketInfo(libkv.NewBucketName("missing"))
+		Expect(errors.Is(err, libkv.BucketNotFoundError)).To(BeTrue())
+
+		err = updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			return tx.SetBucketMeta(ctx, libkv.NewBucketName("missing"), meta)
+		})
+		Expect(errors.Is(err, libkv.BucketNotFoundError)).To(BeTrue())
+	})
+
+	It("keeps meta on rename and copy", func() {
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			return tx.SetBucketMeta(ctx, orders, meta)
+		})).To(Succeed())
+		before, err := bucketInfo(orders)
+		Expect(err).To(BeNil())
+
+		renamed := libkv.NewBucketName("orders-renamed")
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			return tx.RenameBucket(ctx, orders, renamed)
+		})).To(Succeed())
+		info, err := bucketInfo(renamed)
+		Expect(err).To(BeNil())
+		Expect(info.BucketMeta).To(Equal(meta))
+		Expect(info.CreatedAt).To(BeTemporally("==", before.CreatedAt))
+
+		copied := libkv.NewBucketName("orders-copy")
+		Expect(db.CopyBucket(ctx, renamed, copied)).To(Succeed())
+		info, err = bucketInfo(copied)
+		Expect(err).To(BeNil())
+		Expect(info.BucketMeta).To(Equal(meta))
+
+		moved := libkv.NewBucketName("orders-moved")
+		Expect(db.RenameBucket(ctx, copied, moved)).To(Succeed())
+		info, err = bucketInfo(moved)
+		Expect(err).To(BeNil())
+		Expect(info.BucketMeta).To(Equal(meta))
+	})
+})
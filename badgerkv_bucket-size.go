@@ -0,0 +1,125 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// BucketSize is the estimated on-disk size of a bucket.
+type BucketSize struct {
+	Name libkv.BucketName `json:"name"`
+	// LSMBytes are the bytes of the SST tables whose key range lies within the prefix of
+	// the bucket, without tables holding only keys of buckets nested in the prefix.
+	LSMBytes int64 `json:"lsm_bytes"`
+	// VlogBytes is a guess of the value log bytes of the bucket: the value log size
+	// split in proportion to LSMBytes of the total table bytes.
+	VlogBytes int64 `json:"vlog_bytes"`
+}
+
+// Total returns LSMBytes plus VlogBytes.
+func (s BucketSize) Total() int64 {
+	return s.LSMBytes + s.VlogBytes
+}
+
+// EstimateBucketSize estimates the on-disk size of the bucket from the key ranges of
+// Badger's tables, without reading any key. Only tables whose first and last key belong to
+// the bucket prefix are counted, so writes still in the memtable and tables shared with
+// other buckets are not included. Buckets whose name starts with name and the key
+// separator (users_v2 for users) share the prefix: tables holding only their keys are
+// left out, tables mixing their keys with keys of the bucket are counted in full. The
+// value log bytes are not attributed by key but split in proportion to the table bytes,
+// so the result is meant for capacity planning of large buckets, not for accounting.
+func (b *badgerdb) EstimateBucketSize(
+	ctx context.Context,
+	name libkv.BucketName,
+) (*BucketSize, error) {
+	var result BucketSize
+	err := b.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+		badgerTx, ok := tx.(Tx)
+		if !ok {
+			return errors.Errorf(ctx, "unexpected tx type %T", tx)
+		}
+		if _, err := badgerTx.BucketInfo(ctx, name); err != nil {
+			return err
+		}
+		var err error
+		result, err = b.readTableSizes().estimate(ctx, badgerTx.Tx(), name)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "estimate size of bucket %s failed", name)
+	}
+	return &result, nil
+}
+
+// tableSizes are the key ranges and sizes of Badger's tables read once, so the sizes of
+// several buckets are estimated without reading the table list again.
+type tableSizes struct {
+	tables   []badger.TableInfo
+	lsmTotal int64
+	vlog     int64
+}
+
+func (b *badgerdb) readTableSizes() tableSizes {
+	result := tableSizes{tables: b.db.Tables()}
+	for _, table := range result.tables {
+		result.lsmTotal += int64(table.OnDiskSize)
+	}
+	_, result.vlog = b.db.Size()
+	return result
+}
+
+// estimate returns the size of the bucket from the tables within its prefix, without the
+// tables within the prefix of a nested bucket.
+func (s tableSizes) estimate(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	name libkv.BucketName,
+) (BucketSize, error) {
+	nested, err := nestedBucketPrefixes(ctx, badgerTx, name)
+	if err != nil {
+		return BucketSize{}, errors.Wrapf(ctx, err, "list nested buckets failed")
+	}
+	prefix := BucketToPrefix(name)
+	result := BucketSize{Name: name}
+	for _, table := range s.tables {
+		left, right := tableUserKey(table.Left), tableUserKey(table.Right)
+		if !bytes.HasPrefix(left, prefix) || !bytes.HasPrefix(right, prefix) {
+			continue
+		}
+		if withinNestedPrefix(nested, left, right) {
+			continue
+		}
+		result.LSMBytes += int64(table.OnDiskSize)
+	}
+	if s.lsmTotal > 0 {
+		result.VlogBytes = int64(float64(s.vlog) * float64(result.LSMBytes) / float64(s.lsmTotal))
+	}
+	return result, nil
+}
+
+// withinNestedPrefix returns true if left and right share one of the nested prefixes.
+func withinNestedPrefix(nested [][]byte, left []byte, right []byte) bool {
+	for _, prefix := range nested {
+		if bytes.HasPrefix(left, prefix) && bytes.HasPrefix(right, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// tableUserKey strips the 8 byte version Badger appends to keys in tables.
+func tableUserKey(key []byte) []byte {
+	if len(key) < 8 {
+		return key
+	}
+	return key[:len(key)-8]
+}
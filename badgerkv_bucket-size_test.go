@@ -0,0 +1,150 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Bucket size", func() {
+	var ctx context.Context
+	var dir string
+	var db libbadgerkv.DB
+	var small libkv.BucketName
+	var large libkv.BucketName
+	var err error
+
+	fill := func(name libkv.BucketName, count int, valueSize int) {
+		err := db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, name)
+			if err != nil {
+				return err
+			}
+			value := make([]byte, valueSize)
+			for i := 0; i < count; i++ {
+				if err := bucket.Put(ctx, []byte(fmt.Sprintf("key%06d", i)), value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		Expect(err).To(BeNil())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		small = libkv.NewBucketName("small")
+		large = libkv.NewBucketName("large")
+		dir, err = os.MkdirTemp("", "badgerkv-size")
+		Expect(err).To(BeNil())
+		db, err = libbadgerkv.OpenPath(ctx, dir)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	})
+
+	reopen := func() {
+		// closing flushes the memtable into a table
+		Expect(db.Close()).To(Succeed())
+		db, err = libbadgerkv.OpenPath(ctx, dir)
+		Expect(err).To(BeNil())
+	}
+
+	It("attributes table bytes to buckets", func() {
+		// tables holding the registry are not attributed to the buckets
+		fill(large, 0, 0)
+		fill(small, 0, 0)
+		reopen()
+		fill(large, 5000, 1000)
+		reopen()
+		fill(small, 10, 10)
+		reopen()
+
+		smallSize, err := db.EstimateBucketSize(ctx, small)
+		Expect(err).To(BeNil())
+		largeSize, err := db.EstimateBucketSize(ctx, large)
+		Expect(err).To(BeNil())
+		Expect(largeSize.Name).To(Equal(large))
+		Expect(largeSize.Total()).To(BeNumerically(">", 0))
+		Expect(largeSize.Total()).To(BeNumerically(">=", smallSize.Total()))
+
+		stats, err := db.Stats(ctx)
+		Expect(err).To(BeNil())
+		for _, bs := range stats.Buckets {
+			if bs.Name.Equal(large) {
+				Expect(bs.SizeB).To(Equal(largeSize.Total()))
+			}
+		}
+	})
+
+	It("leaves out tables of buckets sharing the prefix", func() {
+		nested := libkv.NewBucketName("large_v2")
+		fill(large, 0, 0)
+		fill(nested, 0, 0)
+		reopen()
+		fill(nested, 5000, 1000)
+		reopen()
+		fill(large, 10, 10)
+		reopen()
+
+		largeSize, err := db.EstimateBucketSize(ctx, large)
+		Expect(err).To(BeNil())
+		nestedSize, err := db.EstimateBucketSize(ctx, nested)
+		Expect(err).To(BeNil())
+		Expect(nestedSize.LSMBytes).To(BeNumerically(">", 0))
+		Expect(largeSize.LSMBytes).To(BeNumerically("<", nestedSize.LSMBytes))
+	})
+
+	It("reports the counters of tracked buckets", func() {
+		fill(large, 1, 10)
+		Expect(db.EnableBucketStats(ctx, large)).To(Succeed())
+		reopen()
+		// bypass the counters, so they stay below the table bytes
+		err = db.DB().Update(func(txn *badger.Txn) error {
+			for i := 0; i < 5000; i++ {
+				key := []byte(fmt.Sprintf("large_raw%06d", i))
+				if err := txn.Set(key, make([]byte, 1000)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		Expect(err).To(BeNil())
+		reopen()
+
+		size, err := db.EstimateBucketSize(ctx, large)
+		Expect(err).To(BeNil())
+		Expect(size.Total()).To(BeNumerically(">", 5000))
+		stats, err := db.Stats(ctx)
+		Expect(err).To(BeNil())
+		Expect(stats.Buckets).To(HaveLen(1))
+		Expect(stats.Buckets[0].SizeB).To(BeNumerically("<", 100))
+	})
+
+	It("returns zero for data in the memtable", func() {
+		fill(small, 10, 10)
+		size, err := db.EstimateBucketSize(ctx, small)
+		Expect(err).To(BeNil())
+		Expect(size.Total()).To(BeZero())
+	})
+
+	It("returns BucketNotFoundError for missing bucket", func() {
+		_, err := db.EstimateBucketSize(ctx, libkv.NewBucketName("missing"))
+		Expect(errors.Is(err, libkv.BucketNotFoundError)).To(BeTrue())
+	})
+})
@@ -0,0 +1,384 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// DefaultStatsShards is the number of counter shards of a tracked bucket. Put and Delete
+// add their change to the shard of the key, so writers of different keys rarely conflict.
+const DefaultStatsShards = 16
+
+// bucketStatsName is the internal bucket holding the base counters per bucket.
+var bucketStatsName = libkv.NewBucketName("__stats")
+
+// bucketStatShardsName is the internal bucket holding the counter shards per bucket,
+// keyed by the packed tuple of bucket name and shard.
+var bucketStatShardsName = libkv.NewBucketName("__statshard")
+
+// bucketCounters are the maintained counters of a bucket. Bytes is the sum of key and
+// value lengths without the bucket prefix.
+type bucketCounters struct {
+	Keys  int64
+	Bytes int64
+}
+
+// BucketStatsCheck compares the maintained counters of a bucket with a full scan.
+type BucketStatsCheck struct {
+	Name    libkv.BucketName  `json:"name"`
+	Tracked libkv.BucketStats `json:"tracked"`
+	Actual  libkv.BucketStats `json:"actual"`
+}
+
+// OK returns true if the maintained counters match the scan.
+func (c BucketStatsCheck) OK() bool {
+	return c.Tracked.KeyCount == c.Actual.KeyCount && c.Tracked.SizeB == c.Actual.SizeB
+}
+
+// EnableBucketStats counts the keys and bytes of the bucket and maintains the counters
+// on every Put and Delete from then on, so Stats returns them without a scan. Streams
+// count with the size of their content. Calling it
+// for a bucket already tracked rebuilds the counters. The bucket is counted in a single
+// transaction, which is retried on conflicts up to DefaultConflictRetries times.
+//
+// Tracked buckets pay for one extra read and write per Put and Delete. The change is
+// added to one of DefaultStatsShards counter shards chosen by the key, so concurrent
+// writers only conflict if their keys share a shard. Writers of buckets with
+// BucketMeta.MaxBytes read all shards to check the quota and conflict with each other:
+// DB.Update returns badger.ErrConflict for all but the first of them to commit, so
+// callers retry. Keys expiring by TTL are not subtracted. Buckets of NewBucket do not
+// maintain the counters.
+func (b *badgerdb) EnableBucketStats(ctx context.Context, name libkv.BucketName) error {
+	err := b.retryOnConflict(ctx, func(ctx context.Context, txn *badger.Txn) error {
+		t := newTx(txn)
+		info, err := t.BucketInfo(ctx, name)
+		if err != nil {
+			return err
+		}
+		counters, err := countBucket(ctx, txn, name)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "count bucket failed")
+		}
+		if err := writeBucketCounters(ctx, txn, name, counters); err != nil {
+			return err
+		}
+		info.TrackStats = true
+		return t.putBucketInfo(ctx, *info)
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "enable stats of bucket %s failed", name)
+	}
+	glog.V(2).Infof("enable stats of bucket %s completed", name)
+	return nil
+}
+
+// DisableBucketStats stops maintaining the counters of the bucket and deletes them.
+// Fails for buckets with BucketMeta.MaxBytes, whose quota needs the counters.
+func (b *badgerdb) DisableBucketStats(ctx context.Context, name libkv.BucketName) error {
+	err := b.updateTxn(ctx, func(ctx context.Context, txn *badger.Txn) error {
+		t := newTx(txn)
+		info, err := t.BucketInfo(ctx, name)
+		if err != nil {
+			return err
+		}
+		if info.MaxBytes > 0 {
+			return errors.Errorf(ctx, "quota of bucket %s requires stats", name)
+		}
+		info.TrackStats = false
+		if err := t.putBucketInfo(ctx, *info); err != nil {
+			return err
+		}
+		return deleteBucketCounters(ctx, txn, name)
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "disable stats of bucket %s failed", name)
+	}
+	return nil
+}
+
+// VerifyBucketStats scans the bucket and compares the result with its maintained
+// counters. Returns an error if stats are not enabled for the bucket.
+func (b *badgerdb) VerifyBucketStats(
+	ctx context.Context,
+	name libkv.BucketName,
+) (*BucketStatsCheck, error) {
+	var result *BucketStatsCheck
+	err := b.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+		badgerTx, ok := tx.(Tx)
+		if !ok {
+			return errors.Errorf(ctx, "unexpected tx type %T", tx)
+		}
+		info, err := badgerTx.BucketInfo(ctx, name)
+		if err != nil {
+			return err
+		}
+		if !info.TrackStats {
+			return errors.Errorf(ctx, "stats of bucket %s not enabled", name)
+		}
+		tracked, err := readBucketCounters(ctx, badgerTx.Tx(), name)
+		if err != nil {
+			return err
+		}
+		actual, err := countBucket(ctx, badgerTx.Tx(), name)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "count bucket failed")
+		}
+		result = &BucketStatsCheck{
+			Name:    name,
+			Tracked: tracked.BucketStats(name),
+			Actual:  actual.BucketStats(name),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "verify stats of bucket %s failed", name)
+	}
+	return result, nil
+}
+
+func (c bucketCounters) BucketStats(name libkv.BucketName) libkv.BucketStats {
+	return libkv.BucketStats{Name: name, KeyCount: c.Keys, SizeB: c.Bytes}
+}
+
+// trackPut updates the counter shard of key for storing a value of size bytes at key,
+// which may overwrite a value. Returns ErrQuotaExceeded if the bytes of the bucket would
+// grow beyond BucketMeta.MaxBytes, which reads all shards.
+func (b *bucket) trackPut(ctx context.Context, key []byte, size int64) error {
+	oldSize, exists, err := b.valueSize(ctx, key)
+	if err != nil {
+		return err
+	}
+	delta := bucketCounters{Bytes: size}
+	if exists {
+		delta.Bytes -= oldSize
+	} else {
+		delta.Keys = 1
+		delta.Bytes += int64(len(key))
+	}
+	if b.meta.MaxBytes > 0 {
+		counters, err := readBucketCounters(ctx, b.badgerTx, b.bucketName)
+		if err != nil {
+			return err
+		}
+		if err := b.checkQuota(ctx, key, counters, delta); err != nil {
+			return err
+		}
+	}
+	return addBucketCounters(ctx, b.badgerTx, b.bucketName, counterShard(key), delta)
+}
+
+// trackDelete updates the counter shard of key for deleting key.
+func (b *bucket) trackDelete(ctx context.Context, key []byte) error {
+	oldSize, exists, err := b.valueSize(ctx, key)
+	if err != nil || !exists {
+		return err
+	}
+	delta := bucketCounters{Keys: -1, Bytes: -int64(len(key)) - oldSize}
+	return addBucketCounters(ctx, b.badgerTx, b.bucketName, counterShard(key), delta)
+}
+
+func (b *bucket) valueSize(ctx context.Context, key []byte) (int64, bool, error) {
+	item, err := b.badgerTx.Get(BucketAddKey(b.bucketName, key))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return 0, false, nil
+		}
+		return 0, false, errors.Wrapf(ctx, err, "get failed")
+	}
+	size, err := itemValueSize(item)
+	if err != nil {
+		return 0, false, errors.Wrapf(ctx, err, "get value size failed")
+	}
+	return size, true, nil
+}
+
+// itemValueSize returns the exact value length, or the content size for streams.
+// Item.ValueSize is only an estimate for values in the value log and zero for writes
+// pending in the transaction.
+func itemValueSize(item *badger.Item) (int64, error) {
+	if item.UserMeta()&streamManifestMeta != 0 {
+		return streamSize(item)
+	}
+	var size int64
+	err := item.Value(func(val []byte) error {
+		size = int64(len(val))
+		return nil
+	})
+	return size, err
+}
+
+// countBucket scans the bucket and returns its counters.
+func countBucket(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	name libkv.BucketName,
+) (bucketCounters, error) {
+	var result bucketCounters
+	err := forEachBucketKey(ctx, badgerTx, name, func(item *badger.Item) error {
+		size, err := itemValueSize(item)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "get value size failed")
+		}
+		result.Keys++
+		result.Bytes += int64(len(BucketRemoveKey(name, item.Key()))) + size
+		return nil
+	})
+	return result, err
+}
+
+// counterShard returns the counter shard of key.
+func counterShard(key []byte) int {
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return int(h.Sum32() % DefaultStatsShards)
+}
+
+// addBucketCounters adds delta to the counter shard of the bucket.
+func addBucketCounters(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	name libkv.BucketName,
+	shard int,
+	delta bucketCounters,
+) error {
+	key, err := statShardKey(ctx, name, shard)
+	if err != nil {
+		return err
+	}
+	counters, err := readCounters(ctx, badgerTx, name, key)
+	if err != nil {
+		return err
+	}
+	counters.Keys += delta.Keys
+	counters.Bytes += delta.Bytes
+	return writeCounters(ctx, badgerTx, name, key, counters)
+}
+
+// readBucketCounters returns the sum of the base counters and all shards of the bucket.
+// The shards are read by key, so update transactions conflict with writers of any shard.
+func readBucketCounters(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	name libkv.BucketName,
+) (bucketCounters, error) {
+	result, err := readCounters(ctx, badgerTx, name, BucketAddKey(bucketStatsName, name))
+	if err != nil {
+		return bucketCounters{}, err
+	}
+	for shard := 0; shard < DefaultStatsShards; shard++ {
+		key, err := statShardKey(ctx, name, shard)
+		if err != nil {
+			return bucketCounters{}, err
+		}
+		counters, err := readCounters(ctx, badgerTx, name, key)
+		if err != nil {
+			return bucketCounters{}, err
+		}
+		result.Keys += counters.Keys
+		result.Bytes += counters.Bytes
+	}
+	return result, nil
+}
+
+// writeBucketCounters replaces the counters of the bucket with counters.
+func writeBucketCounters(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	name libkv.BucketName,
+	counters bucketCounters,
+) error {
+	if err := deleteStatShards(ctx, badgerTx, name); err != nil {
+		return err
+	}
+	return writeCounters(ctx, badgerTx, name, BucketAddKey(bucketStatsName, name), counters)
+}
+
+// deleteBucketCounters deletes the base counters and all shards of the bucket.
+func deleteBucketCounters(ctx context.Context, badgerTx *badger.Txn, name libkv.BucketName) error {
+	if err := badgerTx.Delete(BucketAddKey(bucketStatsName, name)); err != nil {
+		return errors.Wrapf(ctx, err, "delete counters of %s failed", name)
+	}
+	return deleteStatShards(ctx, badgerTx, name)
+}
+
+func deleteStatShards(ctx context.Context, badgerTx *badger.Txn, name libkv.BucketName) error {
+	for shard := 0; shard < DefaultStatsShards; shard++ {
+		key, err := statShardKey(ctx, name, shard)
+		if err != nil {
+			return err
+		}
+		if err := badgerTx.Delete(key); err != nil {
+			return errors.Wrapf(ctx, err, "delete counter shard %d of %s failed", shard, name)
+		}
+	}
+	return nil
+}
+
+// statShardKey returns the key of the counter shard of the bucket.
+func statShardKey(ctx context.Context, name libkv.BucketName, shard int) ([]byte, error) {
+	packed, err := Tuple{name.Bytes(), shard}.Pack(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "pack counter shard key failed")
+	}
+	return BucketAddKey(bucketStatShardsName, packed), nil
+}
+
+func readCounters(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	name libkv.BucketName,
+	key []byte,
+) (bucketCounters, error) {
+	item, err := badgerTx.Get(key)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return bucketCounters{}, nil
+		}
+		return bucketCounters{}, errors.Wrapf(ctx, err, "get counters of %s failed", name)
+	}
+	var result bucketCounters
+	err = item.Value(func(val []byte) error {
+		if len(val) != 16 {
+			return errors.Wrapf(
+				ctx,
+				ErrInvalidCounter,
+				"counters of %s have %d bytes",
+				name,
+				len(val),
+			)
+		}
+		result.Keys = int64(binary.BigEndian.Uint64(val[:8]))  // #nosec G115 -- two's complement
+		result.Bytes = int64(binary.BigEndian.Uint64(val[8:])) // #nosec G115 -- two's complement
+		return nil
+	})
+	if err != nil {
+		return bucketCounters{}, errors.Wrapf(ctx, err, "read counters of %s failed", name)
+	}
+	return result, nil
+}
+
+func writeCounters(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	name libkv.BucketName,
+	key []byte,
+	counters bucketCounters,
+) error {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf[:8], uint64(counters.Keys))  // #nosec G115 -- two's complement
+	binary.BigEndian.PutUint64(buf[8:], uint64(counters.Bytes)) // #nosec G115 -- two's complement
+	if err := badgerTx.Set(key, buf); err != nil {
+		return errors.Wrapf(ctx, err, "write counters of %s failed", name)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Bucket stats", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var orders libkv.BucketName
+	var err error
+
+	update := func(fn func(bucket libbadgerkv.Bucket) error) {
+		err := db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, orders)
+			Expect(err).To(BeNil())
+			badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			return fn(badgerBucket)
+		})
+		Expect(err).To(BeNil())
+	}
+
+	tracked := func() libkv.BucketStats {
+		stats, err := db.Stats(ctx)
+		Expect(err).To(BeNil())
+		for _, bs := range stats.Buckets {
+			if bs.Name.Equal(orders) {
+				return bs
+			}
+		}
+		Fail("bucket not found in stats")
+		return libkv.BucketStats{}
+	}
+
+	verify := func() {
+		check, err := db.VerifyBucketStats(ctx, orders)
+		Expect(err).To(BeNil())
+		Expect(check.Tracked).To(Equal(check.Actual))
+		Expect(check.OK()).To(BeTrue())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		orders = libkv.NewBucketName("orders")
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		update(func(bucket libbadgerkv.Bucket) error {
+			Expect(bucket.Put(ctx, []byte("a"), []byte("12345"))).To(Succeed())
+			return bucket.Put(ctx, []byte("b"), []byte("123"))
+		})
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("counts existing keys on enable", func() {
+		Expect(tracked().KeyCount).To(BeZero())
+
+		Expect(db.EnableBucketStats(ctx, orders)).To(Succeed())
+		bs := tracked()
+		Expect(bs.KeyCount).To(Equal(int64(2)))
+		Expect(bs.SizeB).To(Equal(int64(10)))
+		verify()
+	})
+
+	Context("enabled", func() {
+		BeforeEach(func() {
+			Expect(db.EnableBucketStats(ctx, orders)).To(Succeed())
+		})
+
+		It("tracks inserts, overwrites and deletes", func() {
+			update(func(bucket libbadgerkv.Bucket) error {
+				Expect(bucket.Put(ctx, []byte("c"), []byte("1"))).To(Succeed())
+				Expect(bucket.Put(ctx, []byte("c"), []byte("1234"))).To(Succeed())
+				Expect(bucket.Put(ctx, []byte("a"), []byte("1"))).To(Succeed())
+				Expect(bucket.Delete(ctx, []byte("b"))).To(Succeed())
+				return bucket.Delete(ctx, []byte("missing"))
+			})
+			bs := tracked()
+			Expect(bs.KeyCount).To(Equal(int64(2)))
+			Expect(bs.SizeB).To(Equal(int64(7)))
+			verify()
+		})
+
+		It("tracks conditional writes and counters", func() {
+			update(func(bucket libbadgerkv.Bucket) error {
+				Expect(bucket.PutIfAbsent(ctx, []byte("c"), []byte("1"))).To(Succeed())
+				Expect(bucket.DeleteIfEquals(ctx, []byte("a"), []byte("12345"))).To(Succeed())
+				_, err := bucket.Increment(ctx, []byte("count"), 1)
+				return err
+			})
+			Expect(tracked().KeyCount).To(Equal(int64(3)))
+			verify()
+		})
+
+		It("resets on clear and truncate", func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				badgerTx, ok := tx.(libbadgerkv.Tx)
+				Expect(ok).To(BeTrue())
+				return badgerTx.ClearBucket(ctx, orders)
+			})
+			Expect(err).To(BeNil())
+			Expect(tracked().KeyCount).To(BeZero())
+
+			update(func(bucket libbadgerkv.Bucket) error {
+				return bucket.Put(ctx, []byte("c"), []byte("1"))
+			})
+			Expect(db.TruncateBucket(ctx, orders)).To(Succeed())
+			Expect(tracked().KeyCount).To(BeZero())
+			verify()
+		})
+
+		It("carries counters over on copy and rename", func() {
+			copied := libkv.NewBucketName("orders-copy")
+			Expect(db.CopyBucket(ctx, orders, copied)).To(Succeed())
+			Expect(db.RenameBucket(ctx, orders, libkv.NewBucketName("orders-old"))).To(Succeed())
+			Expect(db.RenameBucket(ctx, copied, orders)).To(Succeed())
+			Expect(tracked().KeyCount).To(Equal(int64(2)))
+			verify()
+		})
+
+		It("does not conflict writers of keys in different counter shards", func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, orders)
+				Expect(err).To(BeNil())
+				Expect(bucket.Put(ctx, []byte("c"), []byte("1"))).To(Succeed())
+				update(func(bucket libbadgerkv.Bucket) error {
+					return bucket.Put(ctx, []byte("d"), []byte("12"))
+				})
+				return nil
+			})
+			Expect(err).To(BeNil())
+			bs := tracked()
+			Expect(bs.KeyCount).To(Equal(int64(4)))
+			Expect(bs.SizeB).To(Equal(int64(15)))
+			verify()
+
+			report, err := db.Verify(ctx)
+			Expect(err).To(BeNil())
+			Expect(report.OrphanedCounters).To(BeEmpty())
+		})
+
+		It("stops tracking on disable", func() {
+			Expect(db.DisableBucketStats(ctx, orders)).To(Succeed())
+			update(func(bucket libbadgerkv.Bucket) error {
+				return bucket.Put(ctx, []byte("c"), []byte("1"))
+			})
+			Expect(tracked().KeyCount).To(BeZero())
+			_, err := db.VerifyBucketStats(ctx, orders)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
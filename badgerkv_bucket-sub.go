@@ -0,0 +1,193 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// subBucketSeparator separates parent and sub-bucket in the full name of a sub-bucket.
+// It sorts before the bucket key separator, so keys of a parent and its sub-buckets never
+// share a prefix.
+const subBucketSeparator = byte(0x1F)
+
+// SubBucketName returns the full name of the sub-bucket name of parent, as used in the
+// bucket registry and the key prefix.
+func SubBucketName(parent libkv.BucketName, name libkv.BucketName) libkv.BucketName {
+	buf := &bytes.Buffer{}
+	buf.Write(parent.Bytes())
+	buf.WriteByte(subBucketSeparator)
+	buf.Write(name.Bytes())
+	return buf.Bytes()
+}
+
+func subBucketPrefix(parent libkv.BucketName) []byte {
+	return SubBucketName(parent, nil)
+}
+
+func validateSubBucketName(ctx context.Context, name libkv.BucketName) error {
+	if len(name) == 0 || bytes.IndexByte(name, subBucketSeparator) >= 0 {
+		return errors.Wrapf(ctx, ErrInvalidBucketName, "invalid sub-bucket name %q", name)
+	}
+	return nil
+}
+
+func (b *bucket) CreateSubBucket(ctx context.Context, name libkv.BucketName) (Bucket, error) {
+	if err := validateSubBucketName(ctx, name); err != nil {
+		return nil, err
+	}
+	result, err := b.transaction().CreateBucket(ctx, SubBucketName(b.bucketName, name))
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "create sub-bucket %s failed", name)
+	}
+	return toBucket(ctx, result)
+}
+
+func (b *bucket) CreateSubBucketIfNotExists(
+	ctx context.Context,
+	name libkv.BucketName,
+) (Bucket, error) {
+	if err := validateSubBucketName(ctx, name); err != nil {
+		return nil, err
+	}
+	result, err := b.transaction().
+		CreateBucketIfNotExists(ctx, SubBucketName(b.bucketName, name))
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "create sub-bucket %s failed", name)
+	}
+	return toBucket(ctx, result)
+}
+
+func (b *bucket) SubBucket(ctx context.Context, name libkv.BucketName) (Bucket, error) {
+	if err := validateSubBucketName(ctx, name); err != nil {
+		return nil, err
+	}
+	result, err := b.transaction().Bucket(ctx, SubBucketName(b.bucketName, name))
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "get sub-bucket %s failed", name)
+	}
+	return toBucket(ctx, result)
+}
+
+func (b *bucket) DeleteSubBucket(ctx context.Context, name libkv.BucketName) error {
+	if err := validateSubBucketName(ctx, name); err != nil {
+		return err
+	}
+	if err := b.transaction().DeleteBucket(ctx, SubBucketName(b.bucketName, name)); err != nil {
+		return errors.Wrapf(ctx, err, "delete sub-bucket %s failed", name)
+	}
+	return nil
+}
+
+func (b *bucket) ListBucketNames(ctx context.Context) (libkv.BucketNames, error) {
+	done, err := b.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	names, err := listSubBuckets(ctx, b.badgerTx, b.bucketName)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "list sub-buckets of %s failed", b.bucketName)
+	}
+	prefix := subBucketPrefix(b.bucketName)
+	result := libkv.BucketNames{}
+	for _, name := range names {
+		child := name[len(prefix):]
+		if bytes.IndexByte(child, subBucketSeparator) >= 0 {
+			continue
+		}
+		result = append(result, child)
+	}
+	return result, nil
+}
+
+// transaction returns the tx that created the bucket, so sub-buckets share its cache.
+func (b *bucket) transaction() *tx {
+	if b.tx != nil {
+		return b.tx
+	}
+	return newTx(b.badgerTx)
+}
+
+// deleteSubBuckets deletes the keys and registry entries of all sub-buckets of parent
+// at any depth. The caller must hold t.mux.
+func (t *tx) deleteSubBuckets(ctx context.Context, parent libkv.BucketName) error {
+	names, err := listSubBuckets(ctx, t.badgerTx, parent)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "list sub-buckets failed")
+	}
+	for _, name := range names {
+		err := forEachBucketKey(ctx, t.badgerTx, name, func(item *badger.Item) error {
+			return t.badgerTx.Delete(item.KeyCopy(nil))
+		})
+		if err != nil {
+			return errors.Wrapf(ctx, err, "delete keys of bucket %s failed", name)
+		}
+		if err := t.deleteBucket(ctx, name); err != nil {
+			return errors.Wrapf(ctx, err, "delete bucket %s failed", name)
+		}
+		delete(t.cache, name.String())
+	}
+	return nil
+}
+
+// checkNoSubBuckets returns an error if parent has sub-buckets.
+func checkNoSubBuckets(ctx context.Context, badgerTx *badger.Txn, parent libkv.BucketName) error {
+	names, err := listSubBuckets(ctx, badgerTx, parent)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "list sub-buckets failed")
+	}
+	if len(names) > 0 {
+		return errors.Errorf(ctx, "bucket %s has %d sub-buckets", parent, len(names))
+	}
+	return nil
+}
+
+// listSubBuckets returns the full names of all sub-buckets of parent at any depth.
+func listSubBuckets(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	parent libkv.BucketName,
+) ([]libkv.BucketName, error) {
+	return listRegisteredBuckets(ctx, badgerTx, subBucketPrefix(parent))
+}
+
+// listRegisteredBuckets returns the names of all registered buckets starting with prefix,
+// reading only the registry entries of the prefix.
+func listRegisteredBuckets(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	prefix []byte,
+) ([]libkv.BucketName, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = BucketAddKey(bucketRegistryName, prefix)
+	it := badgerTx.NewIterator(opts)
+	defer it.Close()
+	var result []libkv.BucketName
+	for it.Rewind(); it.Valid(); it.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx, ctx.Err(), "context cancelled")
+		default:
+		}
+
+		result = append(result, BucketRemoveKey(bucketRegistryName, it.Item().KeyCopy(nil)))
+	}
+	return result, nil
+}
+
+func toBucket(ctx context.Context, b libkv.Bucket) (Bucket, error) {
+	result, ok := b.(Bucket)
+	if !ok {
+		return nil, errors.Errorf(ctx, "unexpected bucket type %T", b)
+	}
+	return result, nil
+}
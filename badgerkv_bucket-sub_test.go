@@ -0,0 +1,170 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Sub-buckets", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var tenants libkv.BucketName
+	var err error
+
+	update := func(fn func(ctx context.Context, tenant libbadgerkv.Bucket) error) error {
+		return db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, tenants)
+			Expect(err).To(BeNil())
+			tenant, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			return fn(ctx, tenant)
+		})
+	}
+
+	names := func(bucketNames libkv.BucketNames) []string {
+		result := []string{}
+		for _, name := range bucketNames {
+			result = append(result, name.String())
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		tenants = libkv.NewBucketName("tenant")
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+
+		err = update(func(ctx context.Context, tenant libbadgerkv.Bucket) error {
+			Expect(tenant.Put(ctx, []byte("name"), []byte("acme"))).To(Succeed())
+			users, err := tenant.CreateSubBucket(ctx, libkv.NewBucketName("users"))
+			Expect(err).To(BeNil())
+			Expect(users.Put(ctx, []byte("alice"), []byte("admin"))).To(Succeed())
+			archive, err := users.CreateSubBucket(ctx, libkv.NewBucketName("archive"))
+			Expect(err).To(BeNil())
+			Expect(archive.Put(ctx, []byte("bob"), []byte("user"))).To(Succeed())
+			_, err = tenant.CreateSubBucket(ctx, libkv.NewBucketName("orders"))
+			return err
+		})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("keeps keys of parent and sub-buckets apart", func() {
+		err = update(func(ctx context.Context, tenant libbadgerkv.Bucket) error {
+			var keys []string
+			Expect(libkv.ForEach(ctx, tenant, func(item libkv.Item) error {
+				keys = append(keys, string(item.Key()))
+				return nil
+			})).To(Succeed())
+			Expect(keys).To(Equal([]string{"name"}))
+
+			users, err := tenant.SubBucket(ctx, libkv.NewBucketName("users"))
+			Expect(err).To(BeNil())
+			item, err := users.Get(ctx, []byte("alice"))
+			Expect(err).To(BeNil())
+			Expect(item.Exists()).To(BeTrue())
+			item, err = users.Get(ctx, []byte("bob"))
+			Expect(err).To(BeNil())
+			Expect(item.Exists()).To(BeFalse())
+			return nil
+		})
+		Expect(err).To(BeNil())
+	})
+
+	It("lists direct sub-buckets only", func() {
+		err = update(func(ctx context.Context, tenant libbadgerkv.Bucket) error {
+			bucketNames, err := tenant.ListBucketNames(ctx)
+			Expect(err).To(BeNil())
+			Expect(names(bucketNames)).To(Equal([]string{"orders", "users"}))
+			return nil
+		})
+		Expect(err).To(BeNil())
+
+		err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucketNames, err := tx.ListBucketNames(ctx)
+			Expect(err).To(BeNil())
+			Expect(names(bucketNames)).To(Equal([]string{"tenant"}))
+			return nil
+		})
+		Expect(err).To(BeNil())
+	})
+
+	It("returns BucketNotFoundError for missing sub-bucket", func() {
+		err = update(func(ctx context.Context, tenant libbadgerkv.Bucket) error {
+			_, err := tenant.SubBucket(ctx, libkv.NewBucketName("missing"))
+			return err
+		})
+		Expect(errors.Is(err, libkv.BucketNotFoundError)).To(BeTrue())
+	})
+
+	It("rejects names containing the separator", func() {
+		err = update(func(ctx context.Context, tenant libbadgerkv.Bucket) error {
+			_, err := tenant.CreateSubBucket(ctx, libkv.BucketName("users\x1farchive"))
+			return err
+		})
+		Expect(errors.Is(err, libbadgerkv.ErrInvalidBucketName)).To(BeTrue())
+	})
+
+	It("deletes sub-buckets with their parent", func() {
+		err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			return tx.DeleteBucket(ctx, tenants)
+		})
+		Expect(err).To(BeNil())
+
+		err = update(func(ctx context.Context, tenant libbadgerkv.Bucket) error {
+			bucketNames, err := tenant.ListBucketNames(ctx)
+			Expect(err).To(BeNil())
+			Expect(bucketNames).To(BeEmpty())
+
+			users, err := tenant.CreateSubBucket(ctx, libkv.NewBucketName("users"))
+			Expect(err).To(BeNil())
+			item, err := users.Get(ctx, []byte("alice"))
+			Expect(err).To(BeNil())
+			Expect(item.Exists()).To(BeFalse())
+			return nil
+		})
+		Expect(err).To(BeNil())
+	})
+
+	It("deletes nested sub-buckets with DeleteSubBucket", func() {
+		err = update(func(ctx context.Context, tenant libbadgerkv.Bucket) error {
+			return tenant.DeleteSubBucket(ctx, libkv.NewBucketName("users"))
+		})
+		Expect(err).To(BeNil())
+
+		err = update(func(ctx context.Context, tenant libbadgerkv.Bucket) error {
+			bucketNames, err := tenant.ListBucketNames(ctx)
+			Expect(err).To(BeNil())
+			Expect(names(bucketNames)).To(Equal([]string{"orders"}))
+			item, err := tenant.Get(ctx, []byte("name"))
+			Expect(err).To(BeNil())
+			Expect(item.Exists()).To(BeTrue())
+			return nil
+		})
+		Expect(err).To(BeNil())
+	})
+
+	It("rejects renaming a bucket with sub-buckets", func() {
+		err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			badgerTx, ok := tx.(libbadgerkv.Tx)
+			Expect(ok).To(BeTrue())
+			return badgerTx.RenameBucket(ctx, tenants, libkv.NewBucketName("customer"))
+		})
+		Expect(err).NotTo(BeNil())
+		Expect(db.RenameBucket(ctx, tenants, libkv.NewBucketName("customer"))).NotTo(Succeed())
+	})
+})
@@ -5,7 +5,9 @@
 package badgerkv
 
 import (
+	"bytes"
 	"context"
+	"io"
 
 	"github.com/bborbe/errors"
 	libkv "github.com/bborbe/kv"
@@ -16,8 +18,37 @@ type Bucket interface {
 	libkv.Bucket
 	Tx() *badger.Txn
 	BucketName() libkv.BucketName
+	// Increment adds delta to the int64 counter at key and returns the new value.
+	Increment(ctx context.Context, key []byte, delta int64) (int64, error)
+	// PutIfAbsent stores value only if key does not exist.
+	PutIfAbsent(ctx context.Context, key []byte, value []byte) error
+	// CompareAndSwap replaces the value of key only if it equals oldValue.
+	CompareAndSwap(ctx context.Context, key []byte, oldValue []byte, newValue []byte) error
+	// DeleteIfEquals deletes key only if its value equals value.
+	DeleteIfEquals(ctx context.Context, key []byte, value []byte) error
+	// GetMany returns the items of keys in input order.
+	GetMany(ctx context.Context, keys [][]byte) ([]libkv.Item, error)
+	// CreateSubBucket creates the sub-bucket name.
+	CreateSubBucket(ctx context.Context, name libkv.BucketName) (Bucket, error)
+	// CreateSubBucketIfNotExists returns the sub-bucket name and creates it if missing.
+	CreateSubBucketIfNotExists(ctx context.Context, name libkv.BucketName) (Bucket, error)
+	// SubBucket returns the sub-bucket name.
+	SubBucket(ctx context.Context, name libkv.BucketName) (Bucket, error)
+	// DeleteSubBucket deletes the sub-bucket name and all of its sub-buckets.
+	DeleteSubBucket(ctx context.Context, name libkv.BucketName) error
+	// ListBucketNames returns the names of the direct sub-buckets.
+	ListBucketNames(ctx context.Context) (libkv.BucketNames, error)
+	// LookupIndex returns the items whose value has indexKey in the index.
+	LookupIndex(ctx context.Context, indexName string, indexKey []byte) ([]libkv.Item, error)
+	// PutStream stores the content of r at key in chunks.
+	PutStream(ctx context.Context, key []byte, r io.Reader) error
+	// GetStream returns the content stored at key with PutStream.
+	GetStream(ctx context.Context, key []byte) (io.ReadCloser, error)
 }
 
+// NewBucket returns a raw handle of the bucket on badgerTx. It does not read the bucket
+// metadata, so its writes skip the counters of EnableBucketStats, the indexes, the limits
+// and the compression of the bucket. Use Tx.Bucket for registered buckets.
 func NewBucket(
 	badgerTx *badger.Txn,
 	bucketName libkv.BucketName,
@@ -31,6 +62,15 @@ func NewBucket(
 type bucket struct {
 	badgerTx   *badger.Txn
 	bucketName libkv.BucketName
+
+	// tx is the transaction that created the bucket, nil if created with NewBucket.
+	tx *tx
+	// trackStats enables the maintained key and byte counters of the bucket.
+	trackStats bool
+	// streams deletes the chunks of streams replaced by Put and Delete.
+	streams bool
+	// meta is the metadata of the bucket, zero if created with NewBucket.
+	meta BucketMeta
 }
 
 func (b *bucket) Tx() *badger.Txn {
@@ -42,14 +82,44 @@ func (b *bucket) BucketName() libkv.BucketName {
 }
 
 func (b *bucket) Iterator() libkv.Iterator {
-	return NewIterator(b.badgerTx, b.bucketName)
+	return b.iterator(newIterator)
 }
 
 func (b *bucket) IteratorReverse() libkv.Iterator {
-	return NewIteratorReverse(b.badgerTx, b.bucketName)
+	return b.iterator(newIteratorReverse)
+}
+
+// iterator creates an iterator with create. Iterators of snapshot buckets keep the
+// snapshot until closed. Once it expired they yield a single item without key whose
+// Value fails with ErrSnapshotExpired, so libkv.ForEach returns the error.
+func (b *bucket) iterator(
+	create func(*badger.Txn, libkv.BucketName, *valueCodec) Iterator,
+) libkv.Iterator {
+	if b.tx == nil || b.tx.guard == nil {
+		return create(b.badgerTx, b.bucketName, b.values())
+	}
+	done, err := b.tx.guard(context.Background())
+	if err != nil {
+		return &expiredIterator{bucketName: b.bucketName, err: err}
+	}
+	return guardedIterator{Iterator: create(b.badgerTx, b.bucketName, b.values()), done: done}
+}
+
+// acquire guards a read of a snapshot bucket against the release of the expired
+// snapshot. The returned func ends the read.
+func (b *bucket) acquire(ctx context.Context) (func(), error) {
+	if b.tx == nil {
+		return func() {}, nil
+	}
+	return b.tx.acquire(ctx)
 }
 
 func (b *bucket) Get(ctx context.Context, key []byte) (libkv.Item, error) {
+	done, err := b.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 	item, err := b.badgerTx.Get(BucketAddKey(b.bucketName, key))
 	if err != nil {
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -57,13 +127,156 @@ func (b *bucket) Get(ctx context.Context, key []byte) (libkv.Item, error) {
 		}
 		return nil, errors.Wrapf(ctx, err, "get failed")
 	}
-	return NewItem(b.bucketName, item), nil
+	return newItem(b.bucketName, item, b.values()), nil
 }
 
+// Put stores value at key, compressed and with checksum if set in the bucket metadata.
+// Returns ErrKeyTooLarge, ErrValueTooLarge or ErrQuotaExceeded if the write exceeds the
+// limits of the bucket and ErrUniqueViolation if a unique index key belongs to another
+// key. All checks run before the first write, so a failed Put changes nothing.
 func (b *bucket) Put(ctx context.Context, key []byte, value []byte) error {
-	return b.badgerTx.Set(BucketAddKey(b.bucketName, key), value)
+	if err := b.checkKeySize(ctx, key); err != nil {
+		return err
+	}
+	if err := b.checkValueSize(ctx, key, value); err != nil {
+		return err
+	}
+	stored, compressed, err := b.values().Compress(ctx, b.meta, value)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "compress failed")
+	}
+	var userMeta byte
+	if compressed {
+		userMeta |= compressedValueMeta
+	}
+	if b.meta.Checksum != ChecksumNone {
+		stored = appendChecksum(stored)
+		userMeta |= checksumValueMeta
+	}
+	indexUpdates, err := b.planIndexUpdates(ctx, key, value, false)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "check indexes failed")
+	}
+	// trackPut checks the quota before it updates the counters
+	if b.trackStats {
+		if err := b.trackPut(ctx, key, int64(len(stored))); err != nil {
+			return errors.Wrapf(ctx, err, "track put failed")
+		}
+	}
+	if b.streams {
+		if err := b.deleteStream(ctx, key); err != nil {
+			return errors.Wrapf(ctx, err, "delete stream failed")
+		}
+	}
+	if err := b.applyIndexUpdates(ctx, key, indexUpdates); err != nil {
+		return errors.Wrapf(ctx, err, "update indexes failed")
+	}
+	entry := badger.NewEntry(BucketAddKey(b.bucketName, key), stored)
+	return b.badgerTx.SetEntry(entry.WithMeta(userMeta))
 }
 
 func (b *bucket) Delete(ctx context.Context, key []byte) error {
+	if b.streams {
+		if err := b.deleteStream(ctx, key); err != nil {
+			return errors.Wrapf(ctx, err, "delete stream failed")
+		}
+	}
+	if b.trackStats {
+		if err := b.trackDelete(ctx, key); err != nil {
+			return errors.Wrapf(ctx, err, "track delete failed")
+		}
+	}
+	if err := b.updateIndexes(ctx, key, nil, true); err != nil {
+		return errors.Wrapf(ctx, err, "update indexes failed")
+	}
 	return b.badgerTx.Delete(BucketAddKey(b.bucketName, key))
 }
+
+// PutIfAbsent stores value at key and returns ErrPreconditionFailed if key already exists.
+// Reading key inside the transaction lets Badger detect a concurrent writer on commit.
+func (b *bucket) PutIfAbsent(ctx context.Context, key []byte, value []byte) error {
+	item, err := b.Get(ctx, key)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "get failed")
+	}
+	if item.Exists() {
+		return errors.Wrapf(ctx, ErrPreconditionFailed, "key %s already exists", key)
+	}
+	return b.Put(ctx, key, value)
+}
+
+// CompareAndSwap stores newValue at key and returns ErrPreconditionFailed if the current
+// value differs from oldValue. A nil oldValue matches a missing key.
+func (b *bucket) CompareAndSwap(
+	ctx context.Context,
+	key []byte,
+	oldValue []byte,
+	newValue []byte,
+) error {
+	if err := b.checkValue(ctx, key, oldValue); err != nil {
+		return errors.Wrapf(ctx, err, "compare and swap failed")
+	}
+	return b.Put(ctx, key, newValue)
+}
+
+// DeleteIfEquals deletes key and returns ErrPreconditionFailed if the current
+// value differs from value.
+func (b *bucket) DeleteIfEquals(ctx context.Context, key []byte, value []byte) error {
+	if err := b.checkValue(ctx, key, value); err != nil {
+		return errors.Wrapf(ctx, err, "delete if equals failed")
+	}
+	return b.Delete(ctx, key)
+}
+
+func (b *bucket) checkValue(ctx context.Context, key []byte, expected []byte) error {
+	item, err := b.Get(ctx, key)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "get failed")
+	}
+	if !item.Exists() {
+		if expected == nil {
+			return nil
+		}
+		return errors.Wrapf(ctx, ErrPreconditionFailed, "key %s not found", key)
+	}
+	var equal bool
+	err = item.Value(func(val []byte) error {
+		equal = expected != nil && bytes.Equal(val, expected)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "value failed")
+	}
+	if !equal {
+		return errors.Wrapf(ctx, ErrPreconditionFailed, "value of key %s changed", key)
+	}
+	return nil
+}
+
+// values returns the codec compressing and verifying values of the bucket.
+func (b *bucket) values() *valueCodec {
+	if b.tx == nil || b.tx.values == nil {
+		return defaultValueCodec
+	}
+	return b.tx.values
+}
+
+// value returns a copy of the decompressed value of key and whether it exists.
+// Streams are reported as missing, their manifest is not a value.
+func (b *bucket) value(ctx context.Context, key []byte) ([]byte, bool, error) {
+	item, err := b.badgerTx.Get(BucketAddKey(b.bucketName, key))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, errors.Wrapf(ctx, err, "get failed")
+	}
+	if item.UserMeta()&streamManifestMeta != 0 {
+		return nil, false, nil
+	}
+	value, err := b.values().ItemValueCopy(ctx, b.bucketName, item)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
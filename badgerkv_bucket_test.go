@@ -7,7 +7,9 @@ package badgerkv_test
 import (
 	"context"
 
+	"github.com/bborbe/errors"
 	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -126,6 +128,178 @@ var _ = Describe("Bucket", func() {
 		})
 	})
 
+	Context("Conditional writes", func() {
+		var value func(bucket libkv.Bucket, key string) []byte
+
+		BeforeEach(func() {
+			value = func(bucket libkv.Bucket, key string) []byte {
+				item, err := bucket.Get(ctx, []byte(key))
+				Expect(err).To(BeNil())
+				var result []byte
+				Expect(item.Value(func(val []byte) error {
+					result = append([]byte{}, val...)
+					return nil
+				})).To(Succeed())
+				return result
+			}
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.CreateBucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+				return bucket.Put(ctx, []byte("key1"), []byte("value1"))
+			})
+			Expect(err).To(BeNil())
+		})
+
+		update := func(fn func(bucket libbadgerkv.Bucket) error) error {
+			return db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+				badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+				Expect(ok).To(BeTrue())
+				return fn(badgerBucket)
+			})
+		}
+
+		It("puts if absent", func() {
+			Expect(update(func(bucket libbadgerkv.Bucket) error {
+				Expect(bucket.PutIfAbsent(ctx, []byte("key2"), []byte("value2"))).To(Succeed())
+				Expect(value(bucket, "key2")).To(Equal([]byte("value2")))
+				return nil
+			})).To(Succeed())
+		})
+
+		It("does not overwrite with put if absent", func() {
+			err = update(func(bucket libbadgerkv.Bucket) error {
+				return bucket.PutIfAbsent(ctx, []byte("key1"), []byte("other"))
+			})
+			Expect(errors.Is(err, libbadgerkv.ErrPreconditionFailed)).To(BeTrue())
+		})
+
+		It("swaps matching value", func() {
+			Expect(update(func(bucket libbadgerkv.Bucket) error {
+				Expect(bucket.CompareAndSwap(
+					ctx,
+					[]byte("key1"),
+					[]byte("value1"),
+					[]byte("value2"),
+				)).To(Succeed())
+				Expect(value(bucket, "key1")).To(Equal([]byte("value2")))
+				return nil
+			})).To(Succeed())
+		})
+
+		It("does not swap changed value", func() {
+			err = update(func(bucket libbadgerkv.Bucket) error {
+				return bucket.CompareAndSwap(ctx, []byte("key1"), []byte("old"), []byte("new"))
+			})
+			Expect(errors.Is(err, libbadgerkv.ErrPreconditionFailed)).To(BeTrue())
+		})
+
+		It("swaps missing key with nil old value", func() {
+			Expect(update(func(bucket libbadgerkv.Bucket) error {
+				return bucket.CompareAndSwap(ctx, []byte("key2"), nil, []byte("value2"))
+			})).To(Succeed())
+			err = update(func(bucket libbadgerkv.Bucket) error {
+				return bucket.CompareAndSwap(ctx, []byte("key3"), []byte("x"), []byte("value3"))
+			})
+			Expect(errors.Is(err, libbadgerkv.ErrPreconditionFailed)).To(BeTrue())
+		})
+
+		It("deletes if equals", func() {
+			Expect(update(func(bucket libbadgerkv.Bucket) error {
+				Expect(bucket.DeleteIfEquals(ctx, []byte("key1"), []byte("value1"))).To(Succeed())
+				Expect(value(bucket, "key1")).To(BeEmpty())
+				return nil
+			})).To(Succeed())
+		})
+
+		It("does not delete changed value", func() {
+			err = update(func(bucket libbadgerkv.Bucket) error {
+				return bucket.DeleteIfEquals(ctx, []byte("key1"), []byte("other"))
+			})
+			Expect(errors.Is(err, libbadgerkv.ErrPreconditionFailed)).To(BeTrue())
+		})
+
+		It("conflicts with concurrent writer", func() {
+			txn1 := db.DB().NewTransaction(true)
+			defer txn1.Discard()
+			txn2 := db.DB().NewTransaction(true)
+			defer txn2.Discard()
+
+			Expect(libbadgerkv.NewBucket(txn1, bucketName).
+				PutIfAbsent(ctx, []byte("key2"), []byte("a"))).To(Succeed())
+			Expect(libbadgerkv.NewBucket(txn2, bucketName).
+				PutIfAbsent(ctx, []byte("key2"), []byte("b"))).To(Succeed())
+
+			Expect(txn1.Commit()).To(Succeed())
+			Expect(errors.Is(txn2.Commit(), badger.ErrConflict)).To(BeTrue())
+		})
+	})
+
+	Context("GetMany", func() {
+		BeforeEach(func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.CreateBucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+				for _, key := range []string{"a", "b", "c", "e", "g"} {
+					Expect(bucket.Put(ctx, []byte(key), []byte("value-"+key))).To(Succeed())
+				}
+				other, err := tx.CreateBucket(ctx, libkv.NewBucketName("other"))
+				Expect(err).To(BeNil())
+				return other.Put(ctx, []byte("d"), []byte("other-d"))
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("returns items in input order", func() {
+			err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+				badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+				Expect(ok).To(BeTrue())
+
+				keys := [][]byte{
+					[]byte("g"), []byte("d"), []byte("a"), []byte("b"),
+					[]byte("z"), []byte("a"), []byte("c"),
+				}
+				items, err := badgerBucket.GetMany(ctx, keys)
+				Expect(err).To(BeNil())
+				Expect(items).To(HaveLen(len(keys)))
+
+				values := make([]string, len(items))
+				for i, item := range items {
+					Expect(item.Key()).To(Equal(keys[i]))
+					Expect(item.Value(func(val []byte) error {
+						values[i] = string(val)
+						return nil
+					})).To(Succeed())
+				}
+				Expect(values).To(Equal([]string{
+					"value-g", "", "value-a", "value-b", "", "value-a", "value-c",
+				}))
+				Expect(items[1].Exists()).To(BeFalse())
+				Expect(items[4].Exists()).To(BeFalse())
+				Expect(items[0].Exists()).To(BeTrue())
+				return nil
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("returns empty result for no keys", func() {
+			err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+				badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+				Expect(ok).To(BeTrue())
+				items, err := badgerBucket.GetMany(ctx, nil)
+				Expect(err).To(BeNil())
+				Expect(items).To(BeEmpty())
+				return nil
+			})
+			Expect(err).To(BeNil())
+		})
+	})
+
 	Context("Iterators", func() {
 		BeforeEach(func() {
 			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
@@ -0,0 +1,74 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+)
+
+// Checksums of bucket values, set with BucketMeta.Checksum.
+const (
+	ChecksumNone   = ""
+	ChecksumCRC32C = "crc32c"
+)
+
+// checksumValueMeta is set in Badger's UserMeta for values ending with the big endian
+// CRC32C of the stored value. Values without it are not verified.
+const checksumValueMeta byte = 0x04
+
+// checksumSize is the size of the checksum appended to values.
+const checksumSize = 4
+
+// validateChecksum returns ErrChecksumUnsupported for unknown checksums.
+func validateChecksum(ctx context.Context, meta BucketMeta) error {
+	switch meta.Checksum {
+	case ChecksumNone, ChecksumCRC32C:
+		return nil
+	default:
+		return errors.Wrapf(ctx, ErrChecksumUnsupported, "unknown checksum %s", meta.Checksum)
+	}
+}
+
+// appendChecksum returns a copy of value followed by its CRC32C.
+func appendChecksum(value []byte) []byte {
+	result := make([]byte, len(value), len(value)+checksumSize)
+	copy(result, value)
+	return binary.BigEndian.AppendUint32(result, crc32.Checksum(value, castagnoli))
+}
+
+// verifyChecksum returns value without its checksum, or an error wrapping
+// ErrChecksumMismatch if the checksum does not match.
+func verifyChecksum(
+	ctx context.Context,
+	name libkv.BucketName,
+	key []byte,
+	value []byte,
+) ([]byte, error) {
+	if len(value) < checksumSize {
+		return nil, errors.Wrapf(
+			ctx,
+			ErrChecksumMismatch,
+			"checksum of key %s in bucket %s missing",
+			key,
+			name,
+		)
+	}
+	data := value[:len(value)-checksumSize]
+	if crc32.Checksum(data, castagnoli) != binary.BigEndian.Uint32(value[len(data):]) {
+		return nil, errors.Wrapf(
+			ctx,
+			ErrChecksumMismatch,
+			"value of key %s in bucket %s",
+			key,
+			name,
+		)
+	}
+	return data, nil
+}
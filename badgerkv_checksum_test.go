@@ -0,0 +1,148 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Checksum", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var orders libkv.BucketName
+	var err error
+
+	get := func(key string) ([]byte, error) {
+		var result []byte
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, orders)
+			if err != nil {
+				return err
+			}
+			item, err := bucket.Get(ctx, []byte(key))
+			if err != nil {
+				return err
+			}
+			return item.Value(func(val []byte) error {
+				result = append([]byte{}, val...)
+				return nil
+			})
+		})
+		return result, err
+	}
+
+	// corrupt flips the last byte of the stored value and keeps its user meta
+	corrupt := func(key string) {
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			fullKey := libbadgerkv.BucketAddKey(orders, []byte(key))
+			item, err := tx.Tx().Get(fullKey)
+			if err != nil {
+				return err
+			}
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			value[len(value)-1] ^= 0xFF
+			return tx.Tx().SetEntry(badger.NewEntry(fullKey, value).WithMeta(item.UserMeta()))
+		})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		orders = libkv.NewBucketName("orders")
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			_, err := tx.CreateBucket(ctx, orders)
+			return err
+		})).To(Succeed())
+		meta := libbadgerkv.BucketMeta{Checksum: libbadgerkv.ChecksumCRC32C}
+		Expect(setBucketMeta(ctx, db, orders, meta)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("returns the value written", func() {
+		Expect(putValue(ctx, db, orders, "1", []byte("open"))).To(Succeed())
+		value, err := get("1")
+		Expect(err).To(BeNil())
+		Expect(value).To(Equal([]byte("open")))
+	})
+
+	It("returns compressed values", func() {
+		meta := libbadgerkv.BucketMeta{
+			Checksum:    libbadgerkv.ChecksumCRC32C,
+			Compression: libbadgerkv.CompressionSnappy,
+		}
+		Expect(setBucketMeta(ctx, db, orders, meta)).To(Succeed())
+		large := []byte(strings.Repeat("open,", 100))
+		Expect(putValue(ctx, db, orders, "1", large)).To(Succeed())
+		value, err := get("1")
+		Expect(err).To(BeNil())
+		Expect(value).To(Equal(large))
+	})
+
+	It("reads values written without checksum", func() {
+		Expect(setBucketMeta(ctx, db, orders, libbadgerkv.BucketMeta{})).To(Succeed())
+		Expect(putValue(ctx, db, orders, "1", []byte("open"))).To(Succeed())
+		meta := libbadgerkv.BucketMeta{Checksum: libbadgerkv.ChecksumCRC32C}
+		Expect(setBucketMeta(ctx, db, orders, meta)).To(Succeed())
+		value, err := get("1")
+		Expect(err).To(BeNil())
+		Expect(value).To(Equal([]byte("open")))
+	})
+
+	It("fails with ErrChecksumMismatch for corrupted values", func() {
+		Expect(putValue(ctx, db, orders, "1", []byte("open"))).To(Succeed())
+		corrupt("1")
+		_, err = get("1")
+		Expect(errors.Is(err, libbadgerkv.ErrChecksumMismatch)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("key 1 in bucket orders"))
+	})
+
+	It("fails with ErrChecksumMismatch in GetMany", func() {
+		Expect(putValue(ctx, db, orders, "1", []byte("open"))).To(Succeed())
+		corrupt("1")
+		err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, orders)
+			if err != nil {
+				return err
+			}
+			badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			_, err = badgerBucket.GetMany(ctx, [][]byte{[]byte("1")})
+			return err
+		})
+		Expect(errors.Is(err, libbadgerkv.ErrChecksumMismatch)).To(BeTrue())
+	})
+
+	It("reports corrupted values in Verify", func() {
+		Expect(putValue(ctx, db, orders, "1", []byte("open"))).To(Succeed())
+		Expect(putValue(ctx, db, orders, "2", []byte("closed"))).To(Succeed())
+		corrupt("2")
+		report, err := db.Verify(ctx)
+		Expect(err).To(BeNil())
+		Expect(report.ChecksumMismatches).To(Equal(int64(1)))
+		Expect(report.Samples).To(HaveLen(1))
+		Expect(errors.Is(report.Err(ctx), libbadgerkv.ErrVerifyFailed)).To(BeTrue())
+	})
+
+	It("fails for unknown checksum", func() {
+		err = setBucketMeta(ctx, db, orders, libbadgerkv.BucketMeta{Checksum: "md5"})
+		Expect(errors.Is(err, libbadgerkv.ErrChecksumUnsupported)).To(BeTrue())
+	})
+})
@@ -0,0 +1,113 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/bborbe/errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// NewJSONCodec returns a value codec using encoding/json.
+func NewJSONCodec[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Encode(ctx context.Context, value T) ([]byte, error) {
+	result, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "marshal json failed")
+	}
+	return result, nil
+}
+
+func (jsonCodec[T]) Decode(ctx context.Context, data []byte) (T, error) {
+	var result T
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, errors.Wrapf(ctx, err, "unmarshal json failed")
+	}
+	return result, nil
+}
+
+// NewGobCodec returns a value codec using encoding/gob.
+// Each value is encoded with its type information.
+func NewGobCodec[T any]() Codec[T] {
+	return gobCodec[T]{}
+}
+
+type gobCodec[T any] struct{}
+
+func (gobCodec[T]) Encode(ctx context.Context, value T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, errors.Wrapf(ctx, err, "encode gob failed")
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec[T]) Decode(ctx context.Context, data []byte) (T, error) {
+	var result T
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&result); err != nil {
+		return result, errors.Wrapf(ctx, err, "decode gob failed")
+	}
+	return result, nil
+}
+
+// NewProtoCodec returns a value codec for protobuf messages, T is the message pointer type.
+func NewProtoCodec[T proto.Message]() Codec[T] {
+	return protoCodec[T]{}
+}
+
+type protoCodec[T proto.Message] struct{}
+
+func (protoCodec[T]) Encode(ctx context.Context, value T) ([]byte, error) {
+	result, err := proto.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "marshal proto failed")
+	}
+	return result, nil
+}
+
+func (protoCodec[T]) Decode(ctx context.Context, data []byte) (T, error) {
+	var zero T
+	result, ok := zero.ProtoReflect().Type().New().Interface().(T)
+	if !ok {
+		return zero, errors.Errorf(ctx, "unexpected message type %T", zero)
+	}
+	if err := proto.Unmarshal(data, result); err != nil {
+		return zero, errors.Wrapf(ctx, err, "unmarshal proto failed")
+	}
+	return result, nil
+}
+
+// NewMsgpackCodec returns a value codec using MessagePack.
+func NewMsgpackCodec[T any]() Codec[T] {
+	return msgpackCodec[T]{}
+}
+
+type msgpackCodec[T any] struct{}
+
+func (msgpackCodec[T]) Encode(ctx context.Context, value T) ([]byte, error) {
+	result, err := msgpack.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "marshal msgpack failed")
+	}
+	return result, nil
+}
+
+func (msgpackCodec[T]) Decode(ctx context.Context, data []byte) (T, error) {
+	var result T
+	if err := msgpack.Unmarshal(data, &result); err != nil {
+		return result, errors.Wrapf(ctx, err, "unmarshal msgpack failed")
+	}
+	return result, nil
+}
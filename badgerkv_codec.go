@@ -0,0 +1,157 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	"github.com/bborbe/errors"
+)
+
+// Codec converts values of type T to bytes and back.
+// Decode must not retain data, it is only valid until Decode returns.
+type Codec[T any] interface {
+	Encode(ctx context.Context, value T) ([]byte, error)
+	Decode(ctx context.Context, data []byte) (T, error)
+}
+
+// NewStringCodec returns a key codec storing strings as their bytes.
+func NewStringCodec() Codec[string] {
+	return stringCodec{}
+}
+
+type stringCodec struct{}
+
+func (stringCodec) Encode(ctx context.Context, value string) ([]byte, error) {
+	return []byte(value), nil
+}
+
+func (stringCodec) Decode(ctx context.Context, data []byte) (string, error) {
+	return string(data), nil
+}
+
+// NewBytesCodec returns a codec storing byte slices as they are.
+func NewBytesCodec() Codec[[]byte] {
+	return bytesCodec{}
+}
+
+type bytesCodec struct{}
+
+func (bytesCodec) Encode(ctx context.Context, value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func (bytesCodec) Decode(ctx context.Context, data []byte) ([]byte, error) {
+	return bytes.Clone(data), nil
+}
+
+// NewUint64Codec returns a key codec storing uint64 as 8 bytes big-endian,
+// so the byte order of keys matches the numeric order.
+func NewUint64Codec() Codec[uint64] {
+	return uint64Codec{}
+}
+
+type uint64Codec struct{}
+
+func (uint64Codec) Encode(ctx context.Context, value uint64) ([]byte, error) {
+	return binary.BigEndian.AppendUint64(nil, value), nil
+}
+
+func (uint64Codec) Decode(ctx context.Context, data []byte) (uint64, error) {
+	if len(data) != 8 {
+		return 0, errors.Wrapf(ctx, ErrInvalidEncoding, "uint64 needs 8 bytes, got %d", len(data))
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// pairSecondCode follows the terminator of the first value of a pair. It differs from
+// the 0xFF of an escaped 0x00, so a second value starting with 0xFF is not read as part
+// of the first.
+const pairSecondCode = 0x01
+
+// Pair is a composite key of two values.
+type Pair[A any, B any] struct {
+	First  A
+	Second B
+}
+
+// NewPairCodec returns a key codec for pairs. The encoded first value is escaped and
+// terminated with 0x00 followed by 0x01, so pairs sort by First and then by Second.
+func NewPairCodec[A any, B any](first Codec[A], second Codec[B]) Codec[Pair[A, B]] {
+	return pairCodec[A, B]{
+		first:  first,
+		second: second,
+	}
+}
+
+type pairCodec[A any, B any] struct {
+	first  Codec[A]
+	second Codec[B]
+}
+
+func (p pairCodec[A, B]) Encode(ctx context.Context, value Pair[A, B]) ([]byte, error) {
+	first, err := p.first.Encode(ctx, value.First)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "encode first failed")
+	}
+	second, err := p.second.Encode(ctx, value.Second)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "encode second failed")
+	}
+	result := make([]byte, 0, len(first)+len(second)+3)
+	result = appendEscaped(result, first)
+	result = append(result, pairSecondCode)
+	return append(result, second...), nil
+}
+
+func (p pairCodec[A, B]) Decode(ctx context.Context, data []byte) (Pair[A, B], error) {
+	var result Pair[A, B]
+	first, n, err := readEscaped(ctx, data)
+	if err != nil {
+		return result, errors.Wrapf(ctx, err, "read first failed")
+	}
+	if n >= len(data) || data[n] != pairSecondCode {
+		return result, errors.Wrapf(ctx, ErrInvalidEncoding, "missing second")
+	}
+	n++
+	if result.First, err = p.first.Decode(ctx, first); err != nil {
+		return result, errors.Wrapf(ctx, err, "decode first failed")
+	}
+	if result.Second, err = p.second.Decode(ctx, data[n:]); err != nil {
+		return result, errors.Wrapf(ctx, err, "decode second failed")
+	}
+	return result, nil
+}
+
+// appendEscaped appends value with 0x00 escaped as 0x00 0xFF and a terminating 0x00.
+func appendEscaped(buf []byte, value []byte) []byte {
+	for _, b := range value {
+		buf = append(buf, b)
+		if b == 0x00 {
+			buf = append(buf, 0xFF)
+		}
+	}
+	return append(buf, 0x00)
+}
+
+// readEscaped reverses appendEscaped and returns the value and the bytes consumed.
+func readEscaped(ctx context.Context, data []byte) ([]byte, int, error) {
+	var result []byte
+	for i := 0; i < len(data); i++ {
+		if data[i] != 0x00 {
+			result = append(result, data[i])
+			continue
+		}
+		if i+1 < len(data) && data[i+1] == 0xFF {
+			result = append(result, 0x00)
+			i++
+			continue
+		}
+		return result, i + 1, nil
+	}
+	return nil, 0, errors.Wrapf(ctx, ErrInvalidEncoding, "missing terminator")
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"sort"
+
+	"github.com/bborbe/errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+type codecUser struct {
+	Name string
+	Age  int
+	Tags []string
+}
+
+var _ = Describe("Codec", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	Context("Uint64Codec", func() {
+		var codec libbadgerkv.Codec[uint64]
+
+		BeforeEach(func() {
+			codec = libbadgerkv.NewUint64Codec()
+		})
+
+		It("keeps numeric order", func() {
+			small, err := codec.Encode(ctx, 255)
+			Expect(err).To(BeNil())
+			large, err := codec.Encode(ctx, 256)
+			Expect(err).To(BeNil())
+			Expect(bytes.Compare(small, large)).To(Equal(-1))
+		})
+
+		It("decodes encoded value", func() {
+			data, err := codec.Encode(ctx, 1<<40+7)
+			Expect(err).To(BeNil())
+			value, err := codec.Decode(ctx, data)
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal(uint64(1<<40 + 7)))
+		})
+
+		It("fails for wrong length", func() {
+			_, err := codec.Decode(ctx, []byte{1, 2, 3})
+			Expect(errors.Is(err, libbadgerkv.ErrInvalidEncoding)).To(BeTrue())
+		})
+	})
+
+	Context("BytesCodec", func() {
+		It("does not retain input", func() {
+			data := []byte("abc")
+			value, err := libbadgerkv.NewBytesCodec().Decode(ctx, data)
+			Expect(err).To(BeNil())
+			data[0] = 'x'
+			Expect(string(value)).To(Equal("abc"))
+		})
+	})
+
+	Context("PairCodec", func() {
+		var codec libbadgerkv.Codec[libbadgerkv.Pair[string, uint64]]
+
+		BeforeEach(func() {
+			codec = libbadgerkv.NewPairCodec(
+				libbadgerkv.NewStringCodec(),
+				libbadgerkv.NewUint64Codec(),
+			)
+		})
+
+		It("decodes encoded value with zero bytes", func() {
+			pair := libbadgerkv.Pair[string, uint64]{First: "a\x00b", Second: 42}
+			data, err := codec.Encode(ctx, pair)
+			Expect(err).To(BeNil())
+			value, err := codec.Decode(ctx, data)
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal(pair))
+		})
+
+		DescribeTable("decodes encoded values with second starting with 0xFF",
+			func(second uint64) {
+				pair := libbadgerkv.Pair[string, uint64]{First: "a", Second: second}
+				data, err := codec.Encode(ctx, pair)
+				Expect(err).To(BeNil())
+				value, err := codec.Decode(ctx, data)
+				Expect(err).To(BeNil())
+				Expect(value).To(Equal(pair))
+			},
+			Entry("max", uint64(math.MaxUint64)),
+			Entry("0xFF00000000000001", uint64(0xFF00000000000001)),
+		)
+
+		It("sorts first values before their extensions with 0x00", func() {
+			short, err := codec.Encode(ctx, libbadgerkv.Pair[string, uint64]{
+				First:  "a",
+				Second: math.MaxUint64,
+			})
+			Expect(err).To(BeNil())
+			long, err := codec.Encode(ctx, libbadgerkv.Pair[string, uint64]{
+				First:  "a\x00",
+				Second: 1,
+			})
+			Expect(err).To(BeNil())
+			Expect(bytes.Compare(short, long)).To(Equal(-1))
+		})
+
+		It("sorts by first and then by second", func() {
+			pairs := []libbadgerkv.Pair[string, uint64]{
+				{First: "b", Second: 1},
+				{First: "a", Second: 256},
+				{First: "a\x00", Second: 0},
+				{First: "a", Second: 2},
+				{First: "ab", Second: 0},
+			}
+			var encoded [][]byte
+			for _, pair := range pairs {
+				data, err := codec.Encode(ctx, pair)
+				Expect(err).To(BeNil())
+				encoded = append(encoded, data)
+			}
+			sort.Slice(encoded, func(i, j int) bool {
+				return bytes.Compare(encoded[i], encoded[j]) < 0
+			})
+			var firsts []string
+			for _, data := range encoded {
+				value, err := codec.Decode(ctx, data)
+				Expect(err).To(BeNil())
+				firsts = append(firsts, value.First)
+			}
+			Expect(firsts).To(Equal([]string{"a", "a", "a\x00", "ab", "b"}))
+		})
+
+		It("fails without terminator", func() {
+			_, err := codec.Decode(ctx, []byte("abc"))
+			Expect(errors.Is(err, libbadgerkv.ErrInvalidEncoding)).To(BeTrue())
+		})
+	})
+
+	DescribeTable("value codecs decode encoded values",
+		func(codec libbadgerkv.Codec[codecUser]) {
+			user := codecUser{Name: "alice", Age: 42, Tags: []string{"admin"}}
+			data, err := codec.Encode(ctx, user)
+			Expect(err).To(BeNil())
+			value, err := codec.Decode(ctx, data)
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal(user))
+		},
+		Entry("json", libbadgerkv.NewJSONCodec[codecUser]()),
+		Entry("gob", libbadgerkv.NewGobCodec[codecUser]()),
+		Entry("msgpack", libbadgerkv.NewMsgpackCodec[codecUser]()),
+	)
+
+	Context("ProtoCodec", func() {
+		It("decodes encoded message", func() {
+			codec := libbadgerkv.NewProtoCodec[*wrapperspb.StringValue]()
+			data, err := codec.Encode(ctx, wrapperspb.String("alice"))
+			Expect(err).To(BeNil())
+			value, err := codec.Decode(ctx, data)
+			Expect(err).To(BeNil())
+			Expect(proto.Equal(value, wrapperspb.String("alice"))).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,127 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+
+	"github.com/bborbe/errors"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// DefaultCompactWorkers is the number of Flatten workers if CompactOptions.Workers is zero.
+const DefaultCompactWorkers = 2
+
+// DefaultDiscardRatio is the value log GC discard ratio if CompactOptions.DiscardRatio is
+// zero. A value log file is rewritten if at least this share of it can be discarded.
+const DefaultDiscardRatio = 0.5
+
+// CompactStage is the step Compact is working on.
+type CompactStage string
+
+// Stages reported by Compact.
+const (
+	CompactStageFlatten    CompactStage = "flatten"
+	CompactStageValueLogGC CompactStage = "value-log-gc"
+	CompactStageDone       CompactStage = "done"
+)
+
+// CompactProgress is reported before each step of Compact and once when it is done.
+type CompactProgress struct {
+	Stage CompactStage `json:"stage"`
+	// Rewrites is the number of value log files rewritten so far.
+	Rewrites  int   `json:"rewrites"`
+	LSMBytes  int64 `json:"lsm_bytes"`
+	VlogBytes int64 `json:"vlog_bytes"`
+}
+
+// CompactOptions configures Compact.
+type CompactOptions struct {
+	// Workers is the number of goroutines used by Flatten.
+	Workers int
+	// Flatten compacts all tables into one level, which drops deleted and overwritten
+	// versions from the LSM tree.
+	Flatten bool
+	// DiscardRatio is passed to Badger's value log GC, in (0, 1).
+	DiscardRatio float64
+	// Progress is called before each step, may be nil.
+	Progress func(progress CompactProgress)
+}
+
+// Compact reclaims disk space after large deletes, for example of DeleteBucket. It
+// optionally flattens the LSM tree and then runs value log GC until no file can be
+// rewritten anymore. ctx is checked between steps; a running Flatten can not be
+// interrupted. Live compactions are stopped during Flatten, so Compact should run in a
+// maintenance window without heavy writes.
+func (b *badgerdb) Compact(ctx context.Context, opts CompactOptions) error {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultCompactWorkers
+	}
+	if opts.DiscardRatio == 0 {
+		opts.DiscardRatio = DefaultDiscardRatio
+	}
+	if opts.DiscardRatio <= 0 || opts.DiscardRatio >= 1 {
+		return errors.Errorf(ctx, "discard ratio must be in (0, 1), got %v", opts.DiscardRatio)
+	}
+	if b.db.Opts().ReadOnly {
+		return errors.Wrapf(ctx, badger.ErrReadOnlyTxn, "compact read only db failed")
+	}
+	report := func(stage CompactStage, rewrites int) {
+		if opts.Progress == nil {
+			return
+		}
+		lsm, vlog := b.db.Size()
+		opts.Progress(CompactProgress{
+			Stage:     stage,
+			Rewrites:  rewrites,
+			LSMBytes:  lsm,
+			VlogBytes: vlog,
+		})
+	}
+
+	if opts.Flatten {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(ctx, err, "context cancelled")
+		}
+		report(CompactStageFlatten, 0)
+		if err := b.db.Flatten(opts.Workers); err != nil {
+			return errors.Wrapf(ctx, err, "flatten failed")
+		}
+		glog.V(2).Infof("flatten with %d workers completed", opts.Workers)
+	}
+
+	rewrites, err := b.runValueLogGC(ctx, opts.DiscardRatio, report)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "value log gc failed")
+	}
+	report(CompactStageDone, rewrites)
+	glog.V(2).Infof("compact completed with %d value log rewrites", rewrites)
+	return nil
+}
+
+// runValueLogGC runs value log GC until a run rewrites nothing and returns the number of
+// rewritten files.
+func (b *badgerdb) runValueLogGC(
+	ctx context.Context,
+	discardRatio float64,
+	report func(stage CompactStage, rewrites int),
+) (int, error) {
+	rewrites := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return rewrites, errors.Wrap(ctx, err, "context cancelled")
+		}
+		report(CompactStageValueLogGC, rewrites)
+		err := b.db.RunValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrGCInMemoryMode) {
+			return rewrites, nil
+		}
+		if err != nil {
+			return rewrites, errors.Wrapf(ctx, err, "run value log gc failed")
+		}
+		rewrites++
+	}
+}
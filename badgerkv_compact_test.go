@@ -0,0 +1,86 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Compact", func() {
+	var ctx context.Context
+	var dir string
+	var db libbadgerkv.DB
+	var bucketName libkv.BucketName
+	var err error
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		bucketName = libkv.NewBucketName("events")
+		dir, err = os.MkdirTemp("", "badgerkv-compact")
+		Expect(err).To(BeNil())
+		db, err = libbadgerkv.OpenPath(ctx, dir)
+		Expect(err).To(BeNil())
+
+		err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucket(ctx, bucketName)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 100; i++ {
+				key := []byte(fmt.Sprintf("key%04d", i))
+				if err := bucket.Put(ctx, key, []byte("value")); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	})
+
+	It("flattens and reports progress", func() {
+		var stages []libbadgerkv.CompactStage
+		err = db.Compact(ctx, libbadgerkv.CompactOptions{
+			Flatten: true,
+			Progress: func(progress libbadgerkv.CompactProgress) {
+				stages = append(stages, progress.Stage)
+			},
+		})
+		Expect(err).To(BeNil())
+		Expect(stages).To(HaveLen(3))
+		Expect(stages[0]).To(Equal(libbadgerkv.CompactStageFlatten))
+		Expect(stages[1]).To(Equal(libbadgerkv.CompactStageValueLogGC))
+		Expect(stages[2]).To(Equal(libbadgerkv.CompactStageDone))
+	})
+
+	It("works on in-memory db", func() {
+		memoryDB, err := libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		defer memoryDB.Close()
+		Expect(memoryDB.Compact(ctx, libbadgerkv.CompactOptions{Flatten: true})).To(Succeed())
+	})
+
+	It("stops on cancelled context", func() {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		Expect(db.Compact(cancelled, libbadgerkv.CompactOptions{})).NotTo(Succeed())
+	})
+
+	It("rejects invalid discard ratio", func() {
+		Expect(db.Compact(ctx, libbadgerkv.CompactOptions{DiscardRatio: 1})).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,328 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressions of bucket values, set with BucketMeta.Compression.
+const (
+	CompressionNone   = ""
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+)
+
+// DefaultCompressionMinSize is the min size of values compressed, smaller values
+// and values that do not get smaller are stored uncompressed.
+const DefaultCompressionMinSize = 64
+
+// compressedValueMeta is set in Badger's UserMeta for values starting with a
+// compression header byte. Values without it are stored as they are.
+const compressedValueMeta byte = 0x01
+
+// Header bytes of compressed values.
+const (
+	snappyHeader byte = 0x01
+	zstdHeader   byte = 0x02
+)
+
+// defaultValueCodec decodes values of buckets created outside of a DB.
+var defaultValueCodec = newValueCodec()
+
+// validateCompression returns ErrCompressionUnsupported for unknown compressions
+// and dictionaries without zstd.
+func validateCompression(ctx context.Context, meta BucketMeta) error {
+	switch meta.Compression {
+	case CompressionNone, CompressionSnappy:
+		if meta.CompressionDict != 0 {
+			return errors.Wrapf(
+				ctx,
+				ErrCompressionUnsupported,
+				"dictionary requires %s",
+				CompressionZstd,
+			)
+		}
+		return nil
+	case CompressionZstd:
+		return nil
+	default:
+		return errors.Wrapf(
+			ctx,
+			ErrCompressionUnsupported,
+			"unknown compression %s",
+			meta.Compression,
+		)
+	}
+}
+
+// RegisterCompressionDict registers a zstd dictionary used by buckets with
+// BucketMeta.CompressionDict set to id. The content can be any sample data,
+// for example typical values of the bucket. Dictionaries are not persisted and must
+// be registered after each open, before values compressed with them are read.
+func (b *badgerdb) RegisterCompressionDict(ctx context.Context, id uint32, dict []byte) error {
+	if err := b.values.AddDict(ctx, id, dict); err != nil {
+		return errors.Wrapf(ctx, err, "register compression dictionary %d failed", id)
+	}
+	glog.V(2).Infof("compression dictionary %d registered", id)
+	return nil
+}
+
+func newValueCodec() *valueCodec {
+	return &valueCodec{
+		dicts:    make(map[uint32][]byte),
+		encoders: make(map[uint32]*zstd.Encoder),
+	}
+}
+
+// valueCodec compresses stored values and decodes them, verifying their checksums.
+// It holds the zstd dictionaries and the encoders and decoder using them.
+// Encoders and decoder are safe for concurrent use. The decoder is replaced when a
+// dictionary is added, decodes hold decoderMux so it is not closed meanwhile.
+type valueCodec struct {
+	mux      sync.Mutex
+	dicts    map[uint32][]byte
+	encoders map[uint32]*zstd.Encoder
+
+	decoderMux sync.RWMutex
+	decoder    *zstd.Decoder
+	closed     bool
+}
+
+func (r *valueCodec) AddDict(ctx context.Context, id uint32, dict []byte) error {
+	if id == 0 || len(dict) == 0 {
+		return errors.Errorf(ctx, "dictionary needs id and content")
+	}
+	r.mux.Lock()
+	if other, ok := r.dicts[id]; ok && !bytes.Equal(other, dict) {
+		r.mux.Unlock()
+		return errors.Errorf(ctx, "other dictionary with id %d registered", id)
+	}
+	r.dicts[id] = bytes.Clone(dict)
+	r.mux.Unlock()
+
+	r.decoderMux.Lock()
+	defer r.decoderMux.Unlock()
+	if r.decoder != nil {
+		r.decoder.Close()
+		r.decoder = nil
+	}
+	return nil
+}
+
+// Close closes the encoders and the decoder. Decodes fail afterwards.
+func (r *valueCodec) Close() {
+	r.decoderMux.Lock()
+	if r.decoder != nil {
+		r.decoder.Close()
+		r.decoder = nil
+	}
+	r.closed = true
+	r.decoderMux.Unlock()
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for id, encoder := range r.encoders {
+		if err := encoder.Close(); err != nil {
+			glog.Warningf("close zstd encoder of dictionary %d failed: %v", id, err)
+		}
+		delete(r.encoders, id)
+	}
+}
+
+// Compress returns value prefixed with the header byte of the compression and whether it
+// was compressed. Values smaller than DefaultCompressionMinSize or not getting smaller
+// are returned unchanged.
+func (r *valueCodec) Compress(
+	ctx context.Context,
+	meta BucketMeta,
+	value []byte,
+) ([]byte, bool, error) {
+	if meta.Compression == CompressionNone || len(value) < DefaultCompressionMinSize {
+		return value, false, nil
+	}
+	var result []byte
+	switch meta.Compression {
+	case CompressionSnappy:
+		result = append([]byte{snappyHeader}, snappy.Encode(nil, value)...)
+	case CompressionZstd:
+		encoder, err := r.encoder(ctx, meta.CompressionDict)
+		if err != nil {
+			return nil, false, err
+		}
+		result = encoder.EncodeAll(value, []byte{zstdHeader})
+	default:
+		return nil, false, errors.Wrapf(
+			ctx,
+			ErrCompressionUnsupported,
+			"unknown compression %s",
+			meta.Compression,
+		)
+	}
+	if len(result) >= len(value) {
+		return value, false, nil
+	}
+	return result, true, nil
+}
+
+// Decompress returns the value of a compressed value with header byte.
+func (r *valueCodec) Decompress(ctx context.Context, value []byte) ([]byte, error) {
+	if len(value) == 0 {
+		return nil, errors.Wrapf(ctx, ErrInvalidEncoding, "compression header missing")
+	}
+	switch value[0] {
+	case snappyHeader:
+		result, err := snappy.Decode(nil, value[1:])
+		if err != nil {
+			return nil, errors.Wrapf(ctx, err, "decode snappy failed")
+		}
+		return result, nil
+	case zstdHeader:
+		result, err := r.decodeZstd(ctx, value[1:])
+		if err != nil {
+			return nil, errors.Wrapf(ctx, err, "decode zstd failed")
+		}
+		return result, nil
+	default:
+		return nil, errors.Wrapf(ctx, ErrInvalidEncoding, "unknown compression 0x%02x", value[0])
+	}
+}
+
+// ItemValue calls fn with the decompressed value of item of the bucket name after
+// verifying its checksum.
+func (r *valueCodec) ItemValue(
+	ctx context.Context,
+	name libkv.BucketName,
+	item *badger.Item,
+	fn func(val []byte) error,
+) error {
+	if item.UserMeta()&(compressedValueMeta|checksumValueMeta) == 0 {
+		return item.Value(fn)
+	}
+	return item.Value(func(val []byte) error {
+		value, err := r.decode(ctx, name, item, val)
+		if err != nil {
+			return err
+		}
+		return fn(value)
+	})
+}
+
+// ItemValueCopy returns a copy of the decompressed value of item of the bucket name
+// after verifying its checksum.
+func (r *valueCodec) ItemValueCopy(
+	ctx context.Context,
+	name libkv.BucketName,
+	item *badger.Item,
+) ([]byte, error) {
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "copy value failed")
+	}
+	if item.UserMeta()&(compressedValueMeta|checksumValueMeta) == 0 {
+		return value, nil
+	}
+	return r.decode(ctx, name, item, value)
+}
+
+// decode verifies the checksum of the stored value of item and decompresses it.
+func (r *valueCodec) decode(
+	ctx context.Context,
+	name libkv.BucketName,
+	item *badger.Item,
+	value []byte,
+) ([]byte, error) {
+	key := BucketRemoveKey(name, item.Key())
+	if item.UserMeta()&checksumValueMeta != 0 {
+		var err error
+		if value, err = verifyChecksum(ctx, name, key, value); err != nil {
+			return nil, err
+		}
+	}
+	if item.UserMeta()&compressedValueMeta == 0 {
+		return value, nil
+	}
+	result, err := r.Decompress(ctx, value)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "decompress value of key %s failed", key)
+	}
+	return result, nil
+}
+
+func (r *valueCodec) encoder(ctx context.Context, dictID uint32) (*zstd.Encoder, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if encoder, ok := r.encoders[dictID]; ok {
+		return encoder, nil
+	}
+	var opts []zstd.EOption
+	if dictID != 0 {
+		dict, ok := r.dicts[dictID]
+		if !ok {
+			return nil, errors.Wrapf(
+				ctx,
+				ErrCompressionUnsupported,
+				"dictionary %d not registered",
+				dictID,
+			)
+		}
+		opts = append(opts, zstd.WithEncoderDictRaw(dictID, dict))
+	}
+	encoder, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "create zstd encoder failed")
+	}
+	r.encoders[dictID] = encoder
+	return encoder, nil
+}
+
+// decodeZstd decodes value holding the read lock of the decoder, so AddDict and Close do
+// not close it meanwhile.
+func (r *valueCodec) decodeZstd(ctx context.Context, value []byte) ([]byte, error) {
+	for {
+		r.decoderMux.RLock()
+		if r.decoder != nil {
+			defer r.decoderMux.RUnlock()
+			return r.decoder.DecodeAll(value, nil)
+		}
+		r.decoderMux.RUnlock()
+		if err := r.createDecoder(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// createDecoder creates the decoder with all dictionaries, if missing.
+func (r *valueCodec) createDecoder(ctx context.Context) error {
+	r.decoderMux.Lock()
+	defer r.decoderMux.Unlock()
+	if r.closed {
+		return errors.Errorf(ctx, "compression closed")
+	}
+	if r.decoder != nil {
+		return nil
+	}
+	opts := []zstd.DOption{zstd.WithDecoderConcurrency(0)}
+	r.mux.Lock()
+	for id, dict := range r.dicts {
+		opts = append(opts, zstd.WithDecoderDictRaw(id, dict))
+	}
+	r.mux.Unlock()
+	decoder, err := zstd.NewReader(nil, opts...)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "create zstd decoder failed")
+	}
+	r.decoder = decoder
+	return nil
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Compression", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var events libkv.BucketName
+	var large []byte
+	var err error
+
+	get := func(key string) []byte {
+		var result []byte
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, events)
+			if err != nil {
+				return err
+			}
+			item, err := bucket.Get(ctx, []byte(key))
+			if err != nil {
+				return err
+			}
+			return item.Value(func(val []byte) error {
+				result = append([]byte{}, val...)
+				return nil
+			})
+		})
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	// stored returns the size of the value as stored in Badger
+	stored := func(key string) int {
+		var result int
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			badgerTx, ok := tx.(libbadgerkv.Tx)
+			Expect(ok).To(BeTrue())
+			item, err := badgerTx.Tx().Get(libbadgerkv.BucketAddKey(events, []byte(key)))
+			if err != nil {
+				return err
+			}
+			result = int(item.ValueSize())
+			return nil
+		})
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		events = libkv.NewBucketName("events")
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			_, err := tx.CreateBucket(ctx, events)
+			return err
+		})).To(Succeed())
+		large = []byte(strings.Repeat(`{"type":"order_created","status":"open"},`, 100))
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	DescribeTable("compresses values",
+		func(compression string) {
+			meta := libbadgerkv.BucketMeta{Compression: compression}
+			Expect(setBucketMeta(ctx, db, events, meta)).To(Succeed())
+			Expect(putValue(ctx, db, events, "1", large)).To(Succeed())
+			Expect(stored("1")).To(BeNumerically("<", len(large)/5))
+			Expect(get("1")).To(Equal(large))
+		},
+		Entry("snappy", libbadgerkv.CompressionSnappy),
+		Entry("zstd", libbadgerkv.CompressionZstd),
+	)
+
+	It("stores small values uncompressed", func() {
+		meta := libbadgerkv.BucketMeta{Compression: libbadgerkv.CompressionZstd}
+		Expect(setBucketMeta(ctx, db, events, meta)).To(Succeed())
+		Expect(putValue(ctx, db, events, "1", []byte("small"))).To(Succeed())
+		Expect(stored("1")).To(Equal(len("small")))
+		Expect(get("1")).To(Equal([]byte("small")))
+	})
+
+	It("reads mixed values", func() {
+		Expect(putValue(ctx, db, events, "1", large)).To(Succeed())
+		meta := libbadgerkv.BucketMeta{Compression: libbadgerkv.CompressionZstd}
+		Expect(setBucketMeta(ctx, db, events, meta)).To(Succeed())
+		Expect(putValue(ctx, db, events, "2", large)).To(Succeed())
+		meta = libbadgerkv.BucketMeta{Compression: libbadgerkv.CompressionSnappy}
+		Expect(setBucketMeta(ctx, db, events, meta)).To(Succeed())
+		Expect(putValue(ctx, db, events, "3", large)).To(Succeed())
+		Expect(setBucketMeta(ctx, db, events, libbadgerkv.BucketMeta{})).To(Succeed())
+
+		Expect(stored("1")).To(Equal(len(large)))
+		var values [][]byte
+		err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, events)
+			if err != nil {
+				return err
+			}
+			return libkv.ForEach(ctx, bucket, func(item libkv.Item) error {
+				return item.Value(func(val []byte) error {
+					values = append(values, append([]byte{}, val...))
+					return nil
+				})
+			})
+		})
+		Expect(err).To(BeNil())
+		Expect(values).To(Equal([][]byte{large, large, large}))
+	})
+
+	It("returns decompressed values with GetMany", func() {
+		meta := libbadgerkv.BucketMeta{Compression: libbadgerkv.CompressionZstd}
+		Expect(setBucketMeta(ctx, db, events, meta)).To(Succeed())
+		Expect(putValue(ctx, db, events, "1", large)).To(Succeed())
+		err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, events)
+			if err != nil {
+				return err
+			}
+			badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			items, err := badgerBucket.GetMany(ctx, [][]byte{[]byte("1")})
+			if err != nil {
+				return err
+			}
+			return items[0].Value(func(val []byte) error {
+				Expect(val).To(Equal(large))
+				return nil
+			})
+		})
+		Expect(err).To(BeNil())
+	})
+
+	It("indexes decompressed values", func() {
+		Expect(db.RegisterIndex(ctx, events, libbadgerkv.Index{
+			Name: "type",
+			Func: func(ctx context.Context, value []byte) ([][]byte, error) {
+				if !bytes.HasPrefix(value, []byte(`{"type":"order_created"`)) {
+					return nil, errors.Errorf(ctx, "unexpected value")
+				}
+				return [][]byte{[]byte("order_created")}, nil
+			},
+		})).To(Succeed())
+		meta := libbadgerkv.BucketMeta{Compression: libbadgerkv.CompressionZstd}
+		Expect(setBucketMeta(ctx, db, events, meta)).To(Succeed())
+		Expect(putValue(ctx, db, events, "1", large)).To(Succeed())
+		// the old value is decompressed to remove its index entries
+		Expect(putValue(ctx, db, events, "1", large)).To(Succeed())
+
+		err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, events)
+			if err != nil {
+				return err
+			}
+			badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			items, err := badgerBucket.LookupIndex(ctx, "type", []byte("order_created"))
+			if err != nil {
+				return err
+			}
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].Key()).To(Equal([]byte("1")))
+			return nil
+		})
+		Expect(err).To(BeNil())
+	})
+
+	Context("dictionary", func() {
+		var meta libbadgerkv.BucketMeta
+
+		BeforeEach(func() {
+			meta = libbadgerkv.BucketMeta{
+				Compression:     libbadgerkv.CompressionZstd,
+				CompressionDict: 7,
+			}
+			Expect(setBucketMeta(ctx, db, events, meta)).To(Succeed())
+		})
+
+		It("compresses with registered dictionary", func() {
+			Expect(db.RegisterCompressionDict(ctx, 7, large)).To(Succeed())
+			Expect(putValue(ctx, db, events, "1", large)).To(Succeed())
+			Expect(get("1")).To(Equal(large))
+		})
+
+		It("reads values while dictionaries are added", func() {
+			Expect(db.RegisterCompressionDict(ctx, 7, large)).To(Succeed())
+			Expect(putValue(ctx, db, events, "1", large)).To(Succeed())
+
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for j := 0; j < 20; j++ {
+						Expect(get("1")).To(Equal(large))
+					}
+				}()
+			}
+			for id := uint32(8); id < 28; id++ {
+				Expect(db.RegisterCompressionDict(ctx, id, large)).To(Succeed())
+			}
+			wg.Wait()
+			Expect(get("1")).To(Equal(large))
+		})
+
+		It("fails for unregistered dictionary", func() {
+			err = putValue(ctx, db, events, "1", large)
+			Expect(errors.Is(err, libbadgerkv.ErrCompressionUnsupported)).To(BeTrue())
+		})
+	})
+
+	It("fails for unknown compression", func() {
+		err = setBucketMeta(ctx, db, events, libbadgerkv.BucketMeta{Compression: "lz4"})
+		Expect(errors.Is(err, libbadgerkv.ErrCompressionUnsupported)).To(BeTrue())
+	})
+
+	It("fails for dictionary without zstd", func() {
+		err = setBucketMeta(ctx, db, events, libbadgerkv.BucketMeta{
+			Compression:     libbadgerkv.CompressionSnappy,
+			CompressionDict: 1,
+		})
+		Expect(errors.Is(err, libbadgerkv.ErrCompressionUnsupported)).To(BeTrue())
+	})
+})
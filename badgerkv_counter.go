@@ -0,0 +1,97 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// DefaultConflictRetries is how often DB.Increment retries a transaction after a conflict.
+const DefaultConflictRetries = 10
+
+// ParseCounter decodes a counter value written by Increment (8 byte big-endian int64).
+// An empty value is a counter of zero.
+func ParseCounter(ctx context.Context, value []byte) (int64, error) {
+	switch len(value) {
+	case 0:
+		return 0, nil
+	case 8:
+		// #nosec G115 -- two's complement round trip of the value written by Increment
+		return int64(binary.BigEndian.Uint64(value)), nil
+	default:
+		return 0, errors.Wrapf(
+			ctx,
+			ErrInvalidCounter,
+			"counter has %d bytes, expected 8",
+			len(value),
+		)
+	}
+}
+
+// Increment adds delta to the counter stored at key and returns the new value.
+// A missing key counts as zero. The read is part of the transaction, so concurrent
+// increments of the same key conflict on commit instead of losing updates.
+func (b *bucket) Increment(ctx context.Context, key []byte, delta int64) (int64, error) {
+	item, err := b.Get(ctx, key)
+	if err != nil {
+		return 0, errors.Wrapf(ctx, err, "get counter failed")
+	}
+	var value int64
+	err = item.Value(func(val []byte) error {
+		value, err = ParseCounter(ctx, val)
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrapf(ctx, err, "parse counter failed")
+	}
+	value += delta
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(value)) // #nosec G115 -- two's complement round trip
+	if err := b.Put(ctx, key, buf); err != nil {
+		return 0, errors.Wrapf(ctx, err, "put counter failed")
+	}
+	return value, nil
+}
+
+// Increment adds delta to the counter at key in the given bucket in its own
+// update transaction, creating the bucket if needed. Conflicting transactions are
+// retried up to DefaultConflictRetries times.
+func (b *badgerdb) Increment(
+	ctx context.Context,
+	bucketName libkv.BucketName,
+	key []byte,
+	delta int64,
+) (int64, error) {
+	var result int64
+	var err error
+	for i := 0; i < DefaultConflictRetries; i++ {
+		err = b.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, bucketName)
+			if err != nil {
+				return errors.Wrapf(ctx, err, "get bucket %s failed", bucketName)
+			}
+			counterBucket, ok := bucket.(Bucket)
+			if !ok {
+				return errors.Errorf(ctx, "bucket %s is not a badgerkv bucket", bucketName)
+			}
+			result, err = counterBucket.Increment(ctx, key, delta)
+			return err
+		})
+		if !errors.Is(err, badger.ErrConflict) {
+			break
+		}
+		glog.V(3).Infof("increment %s in bucket %s conflicted, retry", key, bucketName)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(ctx, err, "increment failed")
+	}
+	return result, nil
+}
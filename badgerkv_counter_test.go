@@ -0,0 +1,99 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Counter", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var bucketName libkv.BucketName
+	var err error
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		bucketName = libkv.NewBucketName("counters")
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	Context("Bucket.Increment", func() {
+		It("starts at zero and adds delta", func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.CreateBucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+				counterBucket, ok := bucket.(libbadgerkv.Bucket)
+				Expect(ok).To(BeTrue())
+
+				value, err := counterBucket.Increment(ctx, []byte("orders"), 5)
+				Expect(err).To(BeNil())
+				Expect(value).To(Equal(int64(5)))
+
+				value, err = counterBucket.Increment(ctx, []byte("orders"), -7)
+				Expect(err).To(BeNil())
+				Expect(value).To(Equal(int64(-2)))
+
+				item, err := bucket.Get(ctx, []byte("orders"))
+				Expect(err).To(BeNil())
+				return item.Value(func(val []byte) error {
+					parsed, err := libbadgerkv.ParseCounter(ctx, val)
+					Expect(err).To(BeNil())
+					Expect(parsed).To(Equal(int64(-2)))
+					return nil
+				})
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("fails on non counter value", func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.CreateBucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+				Expect(bucket.Put(ctx, []byte("orders"), []byte("abc"))).To(Succeed())
+				counterBucket, ok := bucket.(libbadgerkv.Bucket)
+				Expect(ok).To(BeTrue())
+				_, err = counterBucket.Increment(ctx, []byte("orders"), 1)
+				return err
+			})
+			Expect(errors.Is(err, libbadgerkv.ErrInvalidCounter)).To(BeTrue())
+		})
+	})
+
+	Context("DB.Increment", func() {
+		It("does not lose concurrent increments", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for j := 0; j < 10; j++ {
+						_, err := db.Increment(ctx, bucketName, []byte("orders"), 1)
+						Expect(err).To(BeNil())
+					}
+				}()
+			}
+			wg.Wait()
+
+			value, err := db.Increment(ctx, bucketName, []byte("orders"), 0)
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal(int64(40)))
+		})
+	})
+})
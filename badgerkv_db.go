@@ -30,7 +30,10 @@ package badgerkv
 
 import (
 	"context"
+	"io"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/bborbe/collection"
 	"github.com/bborbe/errors"
@@ -46,6 +49,67 @@ const stateCtxKey contextKey = "state"
 type DB interface {
 	libkv.DB
 	DB() *badger.DB
+	// ViewAt opens a read only transaction at the given version. Requires a managed DB.
+	ViewAt(
+		ctx context.Context,
+		readTs uint64,
+		fn func(ctx context.Context, tx libkv.Tx) error,
+	) error
+	// CurrentVersion returns the version of the latest committed write transaction.
+	CurrentVersion() uint64
+	// Snapshot returns a read only transaction valid until released.
+	Snapshot(ctx context.Context) (Snapshot, error)
+	// SnapshotWithMaxAge returns a read only transaction valid until released or maxAge exceeded.
+	SnapshotWithMaxAge(ctx context.Context, maxAge time.Duration) (Snapshot, error)
+	// SnapshotStats returns statistics about snapshots not released yet.
+	SnapshotStats() SnapshotStats
+	// Increment adds delta to a counter in its own transaction, retrying on conflicts.
+	Increment(
+		ctx context.Context,
+		bucketName libkv.BucketName,
+		key []byte,
+		delta int64,
+	) (int64, error)
+	// Sequence returns a leased integer sequence for ID allocation.
+	Sequence(ctx context.Context, name []byte, bandwidth uint64) (Sequence, error)
+	// CopyBucket copies src into the new bucket dst in batches.
+	CopyBucket(ctx context.Context, src libkv.BucketName, dst libkv.BucketName) error
+	// RenameBucket renames oldName to newName, copying in batches.
+	RenameBucket(ctx context.Context, oldName libkv.BucketName, newName libkv.BucketName) error
+	// TruncateBucket deletes all keys of the bucket but keeps it registered.
+	TruncateBucket(ctx context.Context, name libkv.BucketName) error
+	// EnableBucketStats counts the bucket and maintains its key and byte counters.
+	EnableBucketStats(ctx context.Context, name libkv.BucketName) error
+	// DisableBucketStats stops maintaining the counters of the bucket.
+	DisableBucketStats(ctx context.Context, name libkv.BucketName) error
+	// VerifyBucketStats compares the maintained counters of the bucket with a full scan.
+	VerifyBucketStats(ctx context.Context, name libkv.BucketName) (*BucketStatsCheck, error)
+	// EstimateBucketSize estimates the on-disk size of the bucket from Badger's tables.
+	EstimateBucketSize(ctx context.Context, name libkv.BucketName) (*BucketSize, error)
+	// Compact flattens the LSM tree and runs value log GC to reclaim disk space.
+	Compact(ctx context.Context, opts CompactOptions) error
+	// Verify checks registry, key encoding and table checksums.
+	Verify(ctx context.Context) (*VerifyReport, error)
+	// Ready returns nil if the store is open and readable.
+	Ready(ctx context.Context) error
+	// Repair fixes inconsistencies between the bucket registry and the keys.
+	Repair(ctx context.Context, opts RepairOptions) (*RepairReport, error)
+	// RegisterIndex maintains the index of the bucket on every write from now on.
+	RegisterIndex(ctx context.Context, name libkv.BucketName, index Index) error
+	// RebuildIndex indexes all keys of the bucket again.
+	RebuildIndex(ctx context.Context, name libkv.BucketName, indexName string) error
+	// SchemaReport counts the values of the bucket per schema version.
+	SchemaReport(ctx context.Context, name libkv.BucketName) (*SchemaReport, error)
+	// UpgradeBucket rewrites all values of the bucket older than schema.
+	UpgradeBucket(ctx context.Context, name libkv.BucketName, schema Schema) (*SchemaReport, error)
+	// RegisterCompressionDict registers a zstd dictionary for BucketMeta.CompressionDict.
+	RegisterCompressionDict(ctx context.Context, id uint32, dict []byte) error
+	// PutStream stores the content of r at key of the bucket, writing chunks in batches.
+	PutStream(ctx context.Context, name libkv.BucketName, key []byte, r io.Reader) error
+	// Namespace returns a view of the DB whose bucket names are prefixed with name.
+	Namespace(name string) DB
+	// DropNamespace deletes all buckets of the namespace name.
+	DropNamespace(ctx context.Context, name string) error
 }
 
 type ChangeOptions func(opts *badger.Options)
@@ -72,6 +136,9 @@ func OpenPath(ctx context.Context, path string, fn ...ChangeOptions) (DB, error)
 	for _, f := range fn {
 		f(&opts)
 	}
+	if err := validateOptions(ctx, opts); err != nil {
+		return nil, errors.Wrapf(ctx, err, "validate options failed")
+	}
 	db, err := badger.Open(opts)
 	if err != nil {
 		return nil, errors.Wrapf(ctx, err, "open badger db failed")
@@ -92,6 +159,9 @@ func OpenMemory(ctx context.Context, fn ...ChangeOptions) (DB, error) {
 	for _, f := range fn {
 		f(&opts)
 	}
+	if err := validateOptions(ctx, opts); err != nil {
+		return nil, errors.Wrapf(ctx, err, "validate options failed")
+	}
 	db, err := badger.Open(opts)
 	if err != nil {
 		return nil, errors.Wrapf(ctx, err, "open badger db failed")
@@ -99,14 +169,83 @@ func OpenMemory(ctx context.Context, fn ...ChangeOptions) (DB, error) {
 	return NewDB(db), nil
 }
 
+// OpenPathManaged opens a file-based BadgerDB database in managed mode.
+// Managed mode assigns the commit timestamps itself, which allows consistent
+// reads across several transactions with CurrentVersion and ViewAt.
+// Write transactions are committed one after another in this mode.
+func OpenPathManaged(ctx context.Context, path string, fn ...ChangeOptions) (DB, error) {
+	opts := badger.DefaultOptions(path)
+	opts.Logger = nil
+	for _, f := range fn {
+		f(&opts)
+	}
+	if err := validateOptions(ctx, opts); err != nil {
+		return nil, errors.Wrapf(ctx, err, "validate options failed")
+	}
+	db, err := badger.OpenManaged(opts)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "open managed badger db failed")
+	}
+	return NewManagedDB(db, DefaultVersionRetention), nil
+}
+
+// OpenMemoryManaged opens an in-memory BadgerDB database in managed mode.
+// See OpenPathManaged.
+func OpenMemoryManaged(ctx context.Context, fn ...ChangeOptions) (DB, error) {
+	opts := badger.DefaultOptions("").WithInMemory(true)
+	opts.Logger = nil
+	for _, f := range fn {
+		f(&opts)
+	}
+	if err := validateOptions(ctx, opts); err != nil {
+		return nil, errors.Wrapf(ctx, err, "validate options failed")
+	}
+	db, err := badger.OpenManaged(opts)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "open managed badger db failed")
+	}
+	return NewManagedDB(db, DefaultVersionRetention), nil
+}
+
 func NewDB(db *badger.DB) DB {
 	return &badgerdb{
-		db: db,
+		db:        db,
+		snapshots: newSnapshotTracker(),
+		indexes:   newIndexRegistry(),
+		values:    newValueCodec(),
+		copies:    newBucketReservations(),
+		streams:   newBucketReservations(),
+	}
+}
+
+// NewManagedDB wraps a BadgerDB opened with badger.OpenManaged.
+// Versions stay readable via ViewAt for at least the given retention.
+func NewManagedDB(db *badger.DB, retention time.Duration) DB {
+	return &badgerdb{
+		db:        db,
+		versions:  newVersionTracker(db.MaxVersion(), retention, db.SetDiscardTs),
+		snapshots: newSnapshotTracker(),
+		indexes:   newIndexRegistry(),
+		values:    newValueCodec(),
+		copies:    newBucketReservations(),
+		streams:   newBucketReservations(),
 	}
 }
 
 type badgerdb struct {
-	db *badger.DB
+	db        *badger.DB
+	versions  *versionTracker
+	snapshots *snapshotTracker
+	indexes   *indexRegistry
+	values    *valueCodec
+	copies    *bucketReservations
+	// streams are the packed bucket and stream id of DB.PutStream writing chunks
+	// before the manifest.
+	streams *bucketReservations
+
+	// dropMux is held for reading by update transactions and for writing by
+	// TruncateBucket while it checks for buckets sharing the key prefix and drops it.
+	dropMux sync.RWMutex
 }
 
 func (b *badgerdb) Remove() error {
@@ -136,13 +275,25 @@ func (b *badgerdb) DB() *badger.DB {
 }
 
 func (b *badgerdb) Close() error {
-	return b.db.Close()
+	b.snapshots.ReleaseAll()
+	err := b.db.Close()
+	b.values.Close()
+	return err
 }
 
+// Update runs fn in a read-write transaction. It does not retry: if a concurrent
+// transaction wrote a key fn read, the commit fails with badger.ErrConflict. Writers of a
+// bucket tracked with EnableBucketStats conflict if their keys share a counter shard.
+// Update waits while TruncateBucket drops a key prefix.
 func (b *badgerdb) Update(
 	ctx context.Context,
 	fn func(ctx context.Context, tx libkv.Tx) error,
 ) error {
+	b.dropMux.RLock()
+	defer b.dropMux.RUnlock()
+	if b.versions != nil {
+		return b.runTx(ctx, "update", b.updateManaged, fn)
+	}
 	return b.runTx(ctx, "update", b.db.Update, fn)
 }
 
@@ -153,6 +304,60 @@ func (b *badgerdb) View(
 	return b.runTx(ctx, "view", b.db.View, fn)
 }
 
+// viewTxn runs fn in a read transaction with direct access to the Badger transaction.
+func (b *badgerdb) viewTxn(
+	ctx context.Context,
+	fn func(ctx context.Context, txn *badger.Txn) error,
+) error {
+	return b.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+		badgerTx, ok := tx.(Tx)
+		if !ok {
+			return errors.Errorf(ctx, "unexpected tx type %T", tx)
+		}
+		return fn(ctx, badgerTx.Tx())
+	})
+}
+
+// updateTxn runs fn in an update transaction with direct access to the Badger transaction.
+func (b *badgerdb) updateTxn(
+	ctx context.Context,
+	fn func(ctx context.Context, txn *badger.Txn) error,
+) error {
+	return b.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+		badgerTx, ok := tx.(Tx)
+		if !ok {
+			return errors.Errorf(ctx, "unexpected tx type %T", tx)
+		}
+		return fn(ctx, badgerTx.Tx())
+	})
+}
+
+// retryOnConflict runs fn like updateTxn and retries it up to DefaultConflictRetries times
+// if the commit fails with badger.ErrConflict.
+func (b *badgerdb) retryOnConflict(
+	ctx context.Context,
+	fn func(ctx context.Context, txn *badger.Txn) error,
+) error {
+	var err error
+	for i := 0; i < DefaultConflictRetries; i++ {
+		err = b.updateTxn(ctx, fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+		glog.V(3).Infof("update conflicted, retry")
+	}
+	return err
+}
+
+// newTx returns a tx whose buckets maintain the registered indexes.
+func (b *badgerdb) newTx(txn *badger.Txn) *tx {
+	t := newTx(txn)
+	t.indexes = b.indexes
+	t.values = b.values
+	t.reserved = b.copies
+	return t
+}
+
 func (b *badgerdb) runTx(
 	ctx context.Context,
 	op string,
@@ -166,7 +371,7 @@ func (b *badgerdb) runTx(
 	err := badgerFn(func(tx *badger.Txn) error {
 		glog.V(4).Infof("db %s started", op)
 		ctx = SetOpenState(ctx)
-		if err := fn(ctx, NewTx(tx)); err != nil {
+		if err := fn(ctx, b.newTx(tx)); err != nil {
 			return errors.Wrapf(ctx, err, "db %s failed", op)
 		}
 		glog.V(4).Infof("db %s completed", op)
@@ -0,0 +1,99 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+	"time"
+
+	"github.com/bborbe/errors"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// DefaultEncryptionIndexCacheSize is the index cache size WithEncryptionKey configures
+// when no IndexCacheSize was set before.
+const DefaultEncryptionIndexCacheSize = 100 << 20
+
+// WithEncryptionKey enables AES encryption at rest. The key length selects AES-128, AES-192
+// or AES-256 (16, 24 or 32 bytes). Badger generates a new data key after each rotation
+// interval; a zero rotation keeps Badger's default of 10 days. IndexCacheSize is set to
+// DefaultEncryptionIndexCacheSize if it is still zero, because Badger requires an index
+// cache for encrypted tables. The key length is validated when the database is opened.
+//
+// Example:
+//
+//	db, err := badgerkv.OpenPath(ctx, "/tmp/mydb", badgerkv.WithEncryptionKey(key, 24*time.Hour))
+func WithEncryptionKey(key []byte, rotation time.Duration) ChangeOptions {
+	return func(opts *badger.Options) {
+		opts.EncryptionKey = key
+		if rotation > 0 {
+			opts.EncryptionKeyRotationDuration = rotation
+		}
+		if opts.IndexCacheSize == 0 {
+			opts.IndexCacheSize = DefaultEncryptionIndexCacheSize
+		}
+	}
+}
+
+// ValidateEncryptionKey returns ErrInvalidEncryptionKey if key is neither empty
+// nor 16, 24 or 32 bytes long. An empty key means no encryption.
+func ValidateEncryptionKey(ctx context.Context, key []byte) error {
+	switch len(key) {
+	case 0, 16, 24, 32:
+		return nil
+	default:
+		return errors.Wrapf(
+			ctx,
+			ErrInvalidEncryptionKey,
+			"encryption key has %d bytes, expected 16, 24 or 32",
+			len(key),
+		)
+	}
+}
+
+// RotateEncryptionKey re-encrypts the key registry of the database at path from oldKey
+// to newKey. Only the registry holding the data keys is rewritten, so rotation is cheap
+// regardless of database size. The database must not be open while rotating.
+// An empty oldKey enables encryption for an unencrypted database, an empty newKey
+// disables it for data written afterwards.
+func RotateEncryptionKey(ctx context.Context, path string, oldKey []byte, newKey []byte) error {
+	if err := ValidateEncryptionKey(ctx, oldKey); err != nil {
+		return errors.Wrapf(ctx, err, "validate old key failed")
+	}
+	if err := ValidateEncryptionKey(ctx, newKey); err != nil {
+		return errors.Wrapf(ctx, err, "validate new key failed")
+	}
+	opts := badger.KeyRegistryOptions{
+		Dir:                           path,
+		ReadOnly:                      true,
+		EncryptionKey:                 oldKey,
+		EncryptionKeyRotationDuration: badger.DefaultOptions(path).EncryptionKeyRotationDuration,
+	}
+	registry, err := badger.OpenKeyRegistry(opts)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "open key registry in %s failed", path)
+	}
+	opts.EncryptionKey = newKey
+	if err := badger.WriteKeyRegistry(registry, opts); err != nil {
+		return errors.Wrapf(ctx, err, "write key registry in %s failed", path)
+	}
+	glog.V(2).Infof("rotate encryption key of %s completed", path)
+	return nil
+}
+
+func validateOptions(ctx context.Context, opts badger.Options) error {
+	if err := ValidateEncryptionKey(ctx, opts.EncryptionKey); err != nil {
+		return errors.Wrapf(ctx, err, "validate encryption key failed")
+	}
+	if len(opts.EncryptionKey) > 0 && opts.IndexCacheSize <= 0 {
+		return errors.Wrapf(
+			ctx,
+			ErrIndexCacheRequired,
+			"set IndexCacheSize or use WithEncryptionKey",
+		)
+	}
+	return nil
+}
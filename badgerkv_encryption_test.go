@@ -0,0 +1,133 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Encryption", func() {
+	var ctx context.Context
+	var key1 []byte
+	var key2 []byte
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		key1 = []byte("0123456789abcdef")
+		key2 = []byte("fedcba9876543210fedcba9876543210")
+	})
+
+	Context("WithEncryptionKey", func() {
+		var opts badger.Options
+		BeforeEach(func() {
+			opts = badger.DefaultOptions("")
+		})
+		It("sets key, rotation and index cache", func() {
+			libbadgerkv.WithEncryptionKey(key1, time.Hour)(&opts)
+			Expect(opts.EncryptionKey).To(Equal(key1))
+			Expect(opts.EncryptionKeyRotationDuration).To(Equal(time.Hour))
+			Expect(opts.IndexCacheSize).To(
+				Equal(int64(libbadgerkv.DefaultEncryptionIndexCacheSize)),
+			)
+		})
+		It("keeps configured index cache size and default rotation", func() {
+			opts.IndexCacheSize = 1 << 20
+			libbadgerkv.WithEncryptionKey(key1, 0)(&opts)
+			Expect(opts.IndexCacheSize).To(Equal(int64(1 << 20)))
+			Expect(opts.EncryptionKeyRotationDuration).To(Equal(10 * 24 * time.Hour))
+		})
+	})
+
+	Context("ValidateEncryptionKey", func() {
+		It("accepts valid lengths", func() {
+			for _, size := range []int{0, 16, 24, 32} {
+				Expect(libbadgerkv.ValidateEncryptionKey(ctx, make([]byte, size))).To(Succeed())
+			}
+		})
+		It("rejects invalid length", func() {
+			err := libbadgerkv.ValidateEncryptionKey(ctx, []byte("short"))
+			Expect(errors.Is(err, libbadgerkv.ErrInvalidEncryptionKey)).To(BeTrue())
+		})
+	})
+
+	Context("Open", func() {
+		It("fails with invalid key before opening badger", func() {
+			_, err := libbadgerkv.OpenMemory(ctx, libbadgerkv.WithEncryptionKey([]byte("short"), 0))
+			Expect(errors.Is(err, libbadgerkv.ErrInvalidEncryptionKey)).To(BeTrue())
+		})
+		It("fails if index cache is missing", func() {
+			_, err := libbadgerkv.OpenMemory(ctx, func(opts *badger.Options) {
+				opts.EncryptionKey = key1
+			})
+			Expect(errors.Is(err, libbadgerkv.ErrIndexCacheRequired)).To(BeTrue())
+		})
+	})
+
+	Context("RotateEncryptionKey", func() {
+		var dir string
+		var bucketName libkv.BucketName
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "badgerkv-encryption")
+			Expect(err).To(BeNil())
+			bucketName = libkv.NewBucketName("secrets")
+
+			db, err := libbadgerkv.OpenPath(ctx, dir, libbadgerkv.WithEncryptionKey(key1, 0))
+			Expect(err).To(BeNil())
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.CreateBucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+				return bucket.Put(ctx, []byte("key"), []byte("value"))
+			})
+			Expect(err).To(BeNil())
+			Expect(db.Close()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(dir)
+		})
+
+		It("allows opening with the new key", func() {
+			Expect(libbadgerkv.RotateEncryptionKey(ctx, dir, key1, key2)).To(Succeed())
+
+			db, err := libbadgerkv.OpenPath(ctx, dir, libbadgerkv.WithEncryptionKey(key2, 0))
+			Expect(err).To(BeNil())
+			defer func() { _ = db.Close() }()
+			err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+				item, err := bucket.Get(ctx, []byte("key"))
+				Expect(err).To(BeNil())
+				return item.Value(func(val []byte) error {
+					Expect(val).To(Equal([]byte("value")))
+					return nil
+				})
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("rejects the old key after rotation", func() {
+			Expect(libbadgerkv.RotateEncryptionKey(ctx, dir, key1, key2)).To(Succeed())
+
+			_, err := libbadgerkv.OpenPath(ctx, dir, libbadgerkv.WithEncryptionKey(key1, 0))
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("fails with wrong old key", func() {
+			Expect(libbadgerkv.RotateEncryptionKey(ctx, dir, key2, key1)).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,87 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"errors"
+)
+
+// ErrInvalidEncryptionKey is returned when an encryption key is not 16, 24 or 32 bytes long.
+var ErrInvalidEncryptionKey = errors.New("invalid encryption key")
+
+// ErrIndexCacheRequired is returned when encryption is enabled without an index cache.
+// Badger keeps decrypted table indices only in the index cache and panics on first read
+// if none is configured.
+var ErrIndexCacheRequired = errors.New("index cache required for encryption")
+
+// ErrManagedModeRequired is returned by operations that need a DB opened in managed mode.
+var ErrManagedModeRequired = errors.New("managed mode required")
+
+// ErrVersionNotCommitted is returned by ViewAt for a version newer than CurrentVersion.
+var ErrVersionNotCommitted = errors.New("version not committed")
+
+// ErrVersionDiscarded is returned by ViewAt for a version older than the version retention.
+var ErrVersionDiscarded = errors.New("version discarded")
+
+// ErrSnapshotExpired is returned by a Snapshot used after its max age.
+var ErrSnapshotExpired = errors.New("snapshot expired")
+
+// ErrManagedModeNotSupported is returned by operations not available on a managed DB.
+var ErrManagedModeNotSupported = errors.New("not supported in managed mode")
+
+// ErrInvalidCounter is returned when a counter value is not an 8 byte integer.
+var ErrInvalidCounter = errors.New("invalid counter")
+
+// ErrPreconditionFailed is returned by conditional writes whose precondition does not hold.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ErrInvalidBucketName is returned for sub-bucket names that are empty or contain the
+// separator of sub-bucket names.
+var ErrInvalidBucketName = errors.New("invalid bucket name")
+
+// ErrVerifyFailed is returned by VerifyReport.Err if Verify found problems.
+var ErrVerifyFailed = errors.New("verify failed")
+
+// ErrInvalidEncoding is returned by codecs for data they can not decode.
+var ErrInvalidEncoding = errors.New("invalid encoding")
+
+// ErrIndexExists is returned by RegisterIndex for an index name already registered
+// for the bucket.
+var ErrIndexExists = errors.New("index exists")
+
+// ErrIndexNotFound is returned by RebuildIndex for an index not registered.
+var ErrIndexNotFound = errors.New("index not found")
+
+// ErrUniqueViolation is returned by writes that would add an index key of a unique
+// index already used by another key.
+var ErrUniqueViolation = errors.New("unique violation")
+
+// ErrSchemaVersionUnsupported is returned for values whose schema version is newer than
+// the schema or can not be upgraded.
+var ErrSchemaVersionUnsupported = errors.New("schema version unsupported")
+
+// ErrCompressionUnsupported is returned for unknown compressions and for dictionaries
+// not registered or used without zstd.
+var ErrCompressionUnsupported = errors.New("compression unsupported")
+
+// ErrChecksumMismatch is returned when data read does not match its stored checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrKeyTooLarge is returned by writes of keys longer than BucketMeta.MaxKeySize.
+var ErrKeyTooLarge = errors.New("key too large")
+
+// ErrValueTooLarge is returned by writes of values longer than BucketMeta.MaxValueSize.
+var ErrValueTooLarge = errors.New("value too large")
+
+// ErrQuotaExceeded is returned by writes that would grow a bucket beyond
+// BucketMeta.MaxBytes.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ErrChecksumUnsupported is returned for unknown checksums.
+var ErrChecksumUnsupported = errors.New("checksum unsupported")
+
+// ErrInvalidNamespace is returned for empty namespace names and names containing a
+// separator or '_', and by operations not supported on a namespace.
+var ErrInvalidNamespace = errors.New("invalid namespace")
@@ -0,0 +1,56 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+// updateTx runs fn in an update transaction of db with the badgerkv Tx.
+func updateTx(
+	ctx context.Context,
+	db libbadgerkv.DB,
+	fn func(tx libbadgerkv.Tx) error,
+) error {
+	return db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+		badgerTx, ok := tx.(libbadgerkv.Tx)
+		Expect(ok).To(BeTrue())
+		return fn(badgerTx)
+	})
+}
+
+// setBucketMeta replaces the metadata of the bucket name in its own transaction.
+func setBucketMeta(
+	ctx context.Context,
+	db libbadgerkv.DB,
+	name libkv.BucketName,
+	meta libbadgerkv.BucketMeta,
+) error {
+	return updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+		return tx.SetBucketMeta(ctx, name, meta)
+	})
+}
+
+// putValue stores value at key in the bucket name in its own transaction.
+func putValue(
+	ctx context.Context,
+	db libbadgerkv.DB,
+	name libkv.BucketName,
+	key string,
+	value []byte,
+) error {
+	return updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+		bucket, err := tx.Bucket(ctx, name)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(ctx, []byte(key), value)
+	})
+}
@@ -0,0 +1,539 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// indexBucketName is the internal bucket holding the entries of all indexes.
+// Entry keys are the packed Tuple{bucket, index, index key, key}. Unique indexes
+// store Tuple{bucket, index, index key} with the key as value instead.
+var indexBucketName = libkv.NewBucketName("__index")
+
+// IndexFunc returns the index keys of a value stored in the indexed bucket,
+// none if the value is not indexed.
+type IndexFunc func(ctx context.Context, value []byte) ([][]byte, error)
+
+// Index is a secondary index of a bucket, for example email to user.
+type Index struct {
+	Name string
+	Func IndexFunc
+	// Unique rejects writes with ErrUniqueViolation if another key has the same index key.
+	Unique bool
+}
+
+// RegisterIndex maintains the index on every Bucket.Put and Bucket.Delete of the bucket
+// in the same transaction, until the DB is closed. Indexes are not persisted and must
+// be registered after each open, before the first write. Keys written before the index
+// was registered are indexed with RebuildIndex. Copy and rename carry the entries over to
+// the new bucket, register the index for the new name to maintain them.
+func (b *badgerdb) RegisterIndex(ctx context.Context, name libkv.BucketName, index Index) error {
+	if index.Name == "" || index.Func == nil {
+		return errors.Errorf(ctx, "index of bucket %s needs name and func", name)
+	}
+	if err := b.indexes.Add(ctx, name, index); err != nil {
+		return errors.Wrapf(ctx, err, "register index %s of bucket %s failed", index.Name, name)
+	}
+	glog.V(2).Infof("index %s of bucket %s registered", index.Name, name)
+	return nil
+}
+
+// RebuildIndex deletes all entries of the registered index and indexes all keys of the
+// bucket in batches of DefaultBatchSize. Writes during the rebuild maintain the index.
+func (b *badgerdb) RebuildIndex(
+	ctx context.Context,
+	name libkv.BucketName,
+	indexName string,
+) error {
+	index, ok := b.indexes.Get(name, indexName)
+	if !ok {
+		return errors.Wrapf(
+			ctx,
+			ErrIndexNotFound,
+			"index %s of bucket %s not registered",
+			indexName,
+			name,
+		)
+	}
+	snapshot, err := b.Snapshot(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "create snapshot failed")
+	}
+	defer snapshot.Release()
+
+	if _, err := snapshot.Bucket(ctx, name); err != nil {
+		return errors.Wrapf(ctx, err, "get bucket %s failed", name)
+	}
+	if err := b.deleteIndexEntries(ctx, snapshot, name, indexName); err != nil {
+		return errors.Wrapf(ctx, err, "delete entries of index %s failed", indexName)
+	}
+	var batch [][]byte
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := b.retryOnConflict(ctx, func(ctx context.Context, txn *badger.Txn) error {
+			handle, err := b.newTx(txn).bucket(ctx, name)
+			if err != nil {
+				return err
+			}
+			return handle.indexKeys(ctx, index, batch)
+		})
+		batch = nil
+		return err
+	}
+	err = forEachBucketKey(ctx, snapshot.Tx(), name, func(item *badger.Item) error {
+		batch = append(batch, BucketRemoveKey(name, item.KeyCopy(nil)))
+		if len(batch) >= DefaultBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "index keys of bucket %s failed", name)
+	}
+	if err := flush(); err != nil {
+		return errors.Wrapf(ctx, err, "index keys of bucket %s failed", name)
+	}
+	glog.V(2).Infof("rebuild index %s of bucket %s completed", indexName, name)
+	return nil
+}
+
+// indexKeys adds the index entries for the current values of keys.
+func (b *bucket) indexKeys(ctx context.Context, index Index, keys [][]byte) error {
+	for _, key := range keys {
+		value, exists, err := b.value(ctx, key)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "get %s failed", key)
+		}
+		if !exists {
+			continue
+		}
+		indexKeys, err := index.Func(ctx, value)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "index %s of key %s failed", index.Name, key)
+		}
+		for _, indexKey := range indexKeys {
+			err := addIndexEntry(ctx, b.badgerTx, b.bucketName, index, indexKey, key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteIndexEntries deletes the entries of the index as seen by the snapshot in batches.
+func (b *badgerdb) deleteIndexEntries(
+	ctx context.Context,
+	snapshot Snapshot,
+	name libkv.BucketName,
+	indexName string,
+) error {
+	prefix, err := indexPrefix(ctx, Tuple{name.Bytes(), indexName})
+	if err != nil {
+		return err
+	}
+	return b.deleteTupleKeys(ctx, snapshot, prefix)
+}
+
+// deleteTupleKeys deletes the keys starting with the packed tuple prefix as seen by the
+// snapshot in batches.
+func (b *badgerdb) deleteTupleKeys(ctx context.Context, snapshot Snapshot, prefix []byte) error {
+	deleter := b.newKeyDeleter()
+	err := forEachTupleKey(ctx, snapshot.Tx(), prefix, func(item *badger.Item) error {
+		return deleter.Add(ctx, item)
+	})
+	if err != nil {
+		return err
+	}
+	return deleter.Flush(ctx)
+}
+
+// LookupIndex returns the items whose value has indexKey in the index, ordered by key.
+func (b *bucket) LookupIndex(
+	ctx context.Context,
+	indexName string,
+	indexKey []byte,
+) ([]libkv.Item, error) {
+	done, err := b.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	prefix, err := indexPrefix(ctx, Tuple{b.bucketName.Bytes(), indexName, indexKey})
+	if err != nil {
+		return nil, err
+	}
+	var keys [][]byte
+	err = forEachTupleKey(ctx, b.badgerTx, prefix, func(item *badger.Item) error {
+		key, err := indexEntryKey(ctx, item, prefix)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "read index entry failed")
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	items, err := b.GetMany(ctx, keys)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "get keys failed")
+	}
+	result := make([]libkv.Item, 0, len(items))
+	for _, item := range items {
+		if item.Exists() {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// indexEntryKey returns the indexed key of the entry, stored in the value of unique
+// index entries and as last tuple element otherwise.
+func indexEntryKey(ctx context.Context, item *badger.Item, prefix []byte) ([]byte, error) {
+	if len(item.Key()) == len(prefix) {
+		return item.ValueCopy(nil)
+	}
+	rest, err := UnpackTuple(ctx, item.Key()[len(prefix):])
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "unpack failed")
+	}
+	if len(rest) != 1 {
+		return nil, errors.Wrapf(ctx, ErrInvalidEncoding, "invalid index entry %q", rest)
+	}
+	key, ok := rest[0].([]byte)
+	if !ok {
+		return nil, errors.Wrapf(ctx, ErrInvalidEncoding, "invalid index entry %q", rest)
+	}
+	return key, nil
+}
+
+// updateIndexes replaces the index entries of the current value of key with the
+// entries of value, or removes them if the key is deleted.
+func (b *bucket) updateIndexes(ctx context.Context, key []byte, value []byte, deleted bool) error {
+	updates, err := b.planIndexUpdates(ctx, key, value, deleted)
+	if err != nil {
+		return err
+	}
+	return b.applyIndexUpdates(ctx, key, updates)
+}
+
+// indexUpdate are the index keys whose entries of a key are deleted and added.
+type indexUpdate struct {
+	index   Index
+	deleted [][]byte
+	added   [][]byte
+}
+
+// planIndexUpdates returns the changes replacing the index entries of the current value
+// of key with the entries of value, or removing them if the key is deleted. It writes
+// nothing and returns ErrUniqueViolation if an added unique index key belongs to another
+// key, so a failed Put leaves the transaction unchanged.
+func (b *bucket) planIndexUpdates(
+	ctx context.Context,
+	key []byte,
+	value []byte,
+	deleted bool,
+) ([]indexUpdate, error) {
+	indexes := b.indexes()
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	oldValue, exists, err := b.value(ctx, key)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "get old value failed")
+	}
+	result := make([]indexUpdate, 0, len(indexes))
+	for _, index := range indexes {
+		var oldKeys, newKeys [][]byte
+		if exists {
+			if oldKeys, err = index.Func(ctx, oldValue); err != nil {
+				return nil, errors.Wrapf(ctx, err, "index %s of old value failed", index.Name)
+			}
+		}
+		if !deleted {
+			if newKeys, err = index.Func(ctx, value); err != nil {
+				return nil, errors.Wrapf(ctx, err, "index %s of value failed", index.Name)
+			}
+		}
+		update := indexUpdate{index: index}
+		for _, indexKey := range oldKeys {
+			if !containsBytes(newKeys, indexKey) {
+				update.deleted = append(update.deleted, indexKey)
+			}
+		}
+		for _, indexKey := range newKeys {
+			if containsBytes(oldKeys, indexKey) {
+				continue
+			}
+			if index.Unique {
+				err := checkUniqueIndexEntry(ctx, b.badgerTx, b.bucketName, index, indexKey, key)
+				if err != nil {
+					return nil, err
+				}
+			}
+			update.added = append(update.added, indexKey)
+		}
+		result = append(result, update)
+	}
+	return result, nil
+}
+
+// applyIndexUpdates deletes and adds the index entries of key.
+func (b *bucket) applyIndexUpdates(ctx context.Context, key []byte, updates []indexUpdate) error {
+	for _, update := range updates {
+		for _, indexKey := range update.deleted {
+			err := deleteIndexEntry(ctx, b.badgerTx, b.bucketName, update.index, indexKey, key)
+			if err != nil {
+				return err
+			}
+		}
+		for _, indexKey := range update.added {
+			err := addIndexEntry(ctx, b.badgerTx, b.bucketName, update.index, indexKey, key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexes returns the indexes registered for the bucket, none for buckets created
+// outside of a DB transaction.
+func (b *bucket) indexes() []Index {
+	if b.tx == nil || b.tx.indexes == nil {
+		return nil
+	}
+	return b.tx.indexes.List(b.bucketName)
+}
+
+// addIndexEntry adds the entry of key for indexKey. Unique entries are read first, so
+// Badger rejects the commit with badger.ErrConflict if a concurrent transaction adds
+// the same index key.
+func addIndexEntry(
+	ctx context.Context,
+	txn *badger.Txn,
+	name libkv.BucketName,
+	index Index,
+	indexKey []byte,
+	key []byte,
+) error {
+	if index.Unique {
+		return addUniqueIndexEntry(ctx, txn, name, index, indexKey, key)
+	}
+	entryKey, err := indexEntryPath(ctx, name, index, indexKey, key)
+	if err != nil {
+		return err
+	}
+	if err := txn.Set(entryKey, nil); err != nil {
+		return errors.Wrapf(ctx, err, "add entry of index %s failed", index.Name)
+	}
+	return nil
+}
+
+func addUniqueIndexEntry(
+	ctx context.Context,
+	txn *badger.Txn,
+	name libkv.BucketName,
+	index Index,
+	indexKey []byte,
+	key []byte,
+) error {
+	if err := checkUniqueIndexEntry(ctx, txn, name, index, indexKey, key); err != nil {
+		return err
+	}
+	entryKey, err := indexEntryPath(ctx, name, index, indexKey, key)
+	if err != nil {
+		return err
+	}
+	if err := txn.Set(entryKey, key); err != nil {
+		return errors.Wrapf(ctx, err, "add entry of index %s failed", index.Name)
+	}
+	return nil
+}
+
+// checkUniqueIndexEntry returns ErrUniqueViolation if indexKey of the unique index belongs
+// to another key than key. The entry is read in txn, so Badger rejects the commit with
+// badger.ErrConflict if a concurrent transaction adds the same index key.
+func checkUniqueIndexEntry(
+	ctx context.Context,
+	txn *badger.Txn,
+	name libkv.BucketName,
+	index Index,
+	indexKey []byte,
+	key []byte,
+) error {
+	entryKey, err := indexEntryPath(ctx, name, index, indexKey, key)
+	if err != nil {
+		return err
+	}
+	other, exists, err := getValue(txn, entryKey)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "get entry of index %s failed", index.Name)
+	}
+	if exists && !bytes.Equal(other, key) {
+		return errors.Wrapf(
+			ctx,
+			ErrUniqueViolation,
+			"index %s of bucket %s has %q for key %s already",
+			index.Name,
+			name,
+			indexKey,
+			other,
+		)
+	}
+	return nil
+}
+
+// deleteIndexEntry deletes the entry of key for indexKey. Unique entries of other keys
+// are kept.
+func deleteIndexEntry(
+	ctx context.Context,
+	txn *badger.Txn,
+	name libkv.BucketName,
+	index Index,
+	indexKey []byte,
+	key []byte,
+) error {
+	entryKey, err := indexEntryPath(ctx, name, index, indexKey, key)
+	if err != nil {
+		return err
+	}
+	if index.Unique {
+		other, exists, err := getValue(txn, entryKey)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "get entry of index %s failed", index.Name)
+		}
+		if !exists || !bytes.Equal(other, key) {
+			return nil
+		}
+	}
+	if err := txn.Delete(entryKey); err != nil {
+		return errors.Wrapf(ctx, err, "delete entry of index %s failed", index.Name)
+	}
+	return nil
+}
+
+// deleteBucketIndexEntries deletes the entries of all indexes of the bucket.
+func deleteBucketIndexEntries(ctx context.Context, txn *badger.Txn, name libkv.BucketName) error {
+	prefix, err := indexPrefix(ctx, Tuple{name.Bytes()})
+	if err != nil {
+		return err
+	}
+	return forEachTupleKey(ctx, txn, prefix, func(item *badger.Item) error {
+		if err := txn.Delete(item.KeyCopy(nil)); err != nil {
+			return errors.Wrapf(ctx, err, "delete index entry failed")
+		}
+		return nil
+	})
+}
+
+// indexEntryPath returns the key of the entry of key for indexKey.
+func indexEntryPath(
+	ctx context.Context,
+	name libkv.BucketName,
+	index Index,
+	indexKey []byte,
+	key []byte,
+) ([]byte, error) {
+	if index.Unique {
+		return indexPrefix(ctx, Tuple{name.Bytes(), index.Name, indexKey})
+	}
+	return indexPrefix(ctx, Tuple{name.Bytes(), index.Name, indexKey, key})
+}
+
+// indexPrefix returns the key of the packed tuple in the index bucket.
+func indexPrefix(ctx context.Context, tuple Tuple) ([]byte, error) {
+	packed, err := tuple.Pack(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "pack index key failed")
+	}
+	return BucketAddKey(indexBucketName, packed), nil
+}
+
+// getValue returns a copy of the value of key and whether it exists.
+func getValue(txn *badger.Txn, key []byte) ([]byte, bool, error) {
+	item, err := txn.Get(key)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func containsBytes(values [][]byte, value []byte) bool {
+	for _, v := range values {
+		if bytes.Equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func newIndexRegistry() *indexRegistry {
+	return &indexRegistry{
+		indexes: make(map[string][]Index),
+	}
+}
+
+// indexRegistry holds the indexes registered per bucket.
+type indexRegistry struct {
+	mux     sync.RWMutex
+	indexes map[string][]Index
+}
+
+func (r *indexRegistry) Add(ctx context.Context, name libkv.BucketName, index Index) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for _, other := range r.indexes[name.String()] {
+		if other.Name == index.Name {
+			return errors.Wrapf(ctx, ErrIndexExists, "index %s exists", index.Name)
+		}
+	}
+	r.indexes[name.String()] = append(r.indexes[name.String()], index)
+	return nil
+}
+
+func (r *indexRegistry) List(name libkv.BucketName) []Index {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.indexes[name.String()]
+}
+
+// RemovePrefix removes the indexes of all buckets whose name starts with prefix.
+func (r *indexRegistry) RemovePrefix(prefix []byte) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for name := range r.indexes {
+		if bytes.HasPrefix([]byte(name), prefix) {
+			delete(r.indexes, name)
+		}
+	}
+}
+
+func (r *indexRegistry) Get(name libkv.BucketName, indexName string) (Index, bool) {
+	for _, index := range r.List(name) {
+		if index.Name == indexName {
+			return index, true
+		}
+	}
+	return Index{}, false
+}
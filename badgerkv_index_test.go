@@ -0,0 +1,370 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Index", func() {
+	type order struct {
+		Email  string `json:"email"`
+		Status string `json:"status"`
+	}
+
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var orders libkv.BucketName
+	var err error
+
+	statusIndex := libbadgerkv.Index{
+		Name: "status",
+		Func: func(ctx context.Context, value []byte) ([][]byte, error) {
+			var o order
+			if err := json.Unmarshal(value, &o); err != nil {
+				return nil, err
+			}
+			if o.Status == "" {
+				return nil, nil
+			}
+			return [][]byte{[]byte(o.Status)}, nil
+		},
+	}
+
+	put := func(key string, o order) {
+		value, err := json.Marshal(o)
+		Expect(err).To(BeNil())
+		err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, orders)
+			if err != nil {
+				return err
+			}
+			return bucket.Put(ctx, []byte(key), value)
+		})
+		Expect(err).To(BeNil())
+	}
+
+	lookup := func(status string) []string {
+		var result []string
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, orders)
+			if err != nil {
+				return err
+			}
+			badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			items, err := badgerBucket.LookupIndex(ctx, "status", []byte(status))
+			for _, item := range items {
+				result = append(result, string(item.Key()))
+			}
+			return err
+		})
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		orders = libkv.NewBucketName("orders")
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	Context("registered", func() {
+		BeforeEach(func() {
+			Expect(db.RegisterIndex(ctx, orders, statusIndex)).To(Succeed())
+			put("2", order{Email: "bob@example.com", Status: "open"})
+			put("1", order{Email: "alice@example.com", Status: "open"})
+			put("3", order{Email: "carol@example.com", Status: "shipped"})
+		})
+
+		It("returns items ordered by key", func() {
+			Expect(lookup("open")).To(Equal([]string{"1", "2"}))
+			Expect(lookup("shipped")).To(Equal([]string{"3"}))
+			Expect(lookup("missing")).To(BeEmpty())
+		})
+
+		It("ignores index keys extending the index key with 0x00", func() {
+			put("4", order{Email: "dave@example.com", Status: "open\x00late"})
+			Expect(lookup("open")).To(Equal([]string{"1", "2"}))
+			Expect(lookup("open\x00late")).To(Equal([]string{"4"}))
+		})
+
+		It("keeps entries of buckets extending the name with 0x00 on truncate", func() {
+			other := orders
+			orders = libkv.NewBucketName("orders\x00b")
+			Expect(db.RegisterIndex(ctx, orders, statusIndex)).To(Succeed())
+			put("4", order{Email: "dave@example.com", Status: "open"})
+
+			Expect(db.TruncateBucket(ctx, other)).To(Succeed())
+			Expect(lookup("open")).To(Equal([]string{"4"}))
+			orders = other
+			Expect(lookup("open")).To(BeEmpty())
+		})
+
+		It("carries entries over on rename", func() {
+			renamed := libkv.NewBucketName("orders_v2")
+			Expect(db.RegisterIndex(ctx, renamed, statusIndex)).To(Succeed())
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				badgerTx, ok := tx.(libbadgerkv.Tx)
+				Expect(ok).To(BeTrue())
+				return badgerTx.RenameBucket(ctx, orders, renamed)
+			})
+			Expect(err).To(BeNil())
+			orders = renamed
+			Expect(lookup("open")).To(Equal([]string{"1", "2"}))
+			put("4", order{Email: "dave@example.com", Status: "open"})
+			Expect(lookup("open")).To(Equal([]string{"1", "2", "4"}))
+		})
+
+		It("carries entries over on batched rename and copy", func() {
+			renamed := libkv.NewBucketName("orders_v2")
+			copied := libkv.NewBucketName("orders_v3")
+			Expect(db.RenameBucket(ctx, orders, renamed)).To(Succeed())
+			Expect(db.CopyBucket(ctx, renamed, copied)).To(Succeed())
+			orders = renamed
+			Expect(lookup("open")).To(Equal([]string{"1", "2"}))
+			orders = copied
+			Expect(lookup("open")).To(Equal([]string{"1", "2"}))
+		})
+
+		It("removes stale entries on put", func() {
+			put("1", order{Email: "alice@example.com", Status: "shipped"})
+			Expect(lookup("open")).To(Equal([]string{"2"}))
+			Expect(lookup("shipped")).To(Equal([]string{"1", "3"}))
+		})
+
+		It("removes entries if value is no longer indexed", func() {
+			put("1", order{Email: "alice@example.com"})
+			Expect(lookup("open")).To(Equal([]string{"2"}))
+		})
+
+		It("removes entries on delete", func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, orders)
+				if err != nil {
+					return err
+				}
+				return bucket.Delete(ctx, []byte("2"))
+			})
+			Expect(err).To(BeNil())
+			Expect(lookup("open")).To(Equal([]string{"1"}))
+		})
+
+		It("removes entries with the bucket", func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				return tx.DeleteBucket(ctx, orders)
+			})
+			Expect(err).To(BeNil())
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				_, err := tx.CreateBucket(ctx, orders)
+				return err
+			})
+			Expect(err).To(BeNil())
+			Expect(lookup("open")).To(BeEmpty())
+		})
+
+		It("discards entries of failed transactions", func() {
+			value, err := json.Marshal(order{Status: "open"})
+			Expect(err).To(BeNil())
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, orders)
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put(ctx, []byte("4"), value); err != nil {
+					return err
+				}
+				return errors.Errorf(ctx, "rollback")
+			})
+			Expect(err).NotTo(BeNil())
+			Expect(lookup("open")).To(Equal([]string{"1", "2"}))
+		})
+
+		It("keeps verify clean", func() {
+			report, err := db.Verify(ctx)
+			Expect(err).To(BeNil())
+			Expect(report.OK()).To(BeTrue())
+		})
+
+		It("fails to register index twice", func() {
+			err = db.RegisterIndex(ctx, orders, statusIndex)
+			Expect(errors.Is(err, libbadgerkv.ErrIndexExists)).To(BeTrue())
+		})
+	})
+
+	Context("unique", func() {
+		var emailIndex libbadgerkv.Index
+
+		lookupEmail := func(email string) []string {
+			var result []string
+			err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, orders)
+				if err != nil {
+					return err
+				}
+				badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+				Expect(ok).To(BeTrue())
+				items, err := badgerBucket.LookupIndex(ctx, "email", []byte(email))
+				for _, item := range items {
+					result = append(result, string(item.Key()))
+				}
+				return err
+			})
+			Expect(err).To(BeNil())
+			return result
+		}
+
+		putErr := func(ctx context.Context, key string, o order) error {
+			value, err := json.Marshal(o)
+			Expect(err).To(BeNil())
+			return db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.CreateBucketIfNotExists(ctx, orders)
+				if err != nil {
+					return err
+				}
+				return bucket.Put(ctx, []byte(key), value)
+			})
+		}
+
+		BeforeEach(func() {
+			emailIndex = libbadgerkv.Index{
+				Name:   "email",
+				Unique: true,
+				Func: func(ctx context.Context, value []byte) ([][]byte, error) {
+					var o order
+					if err := json.Unmarshal(value, &o); err != nil {
+						return nil, err
+					}
+					return [][]byte{[]byte(o.Email)}, nil
+				},
+			}
+			Expect(db.RegisterIndex(ctx, orders, emailIndex)).To(Succeed())
+			put("1", order{Email: "alice@example.com"})
+		})
+
+		It("returns item", func() {
+			Expect(lookupEmail("alice@example.com")).To(Equal([]string{"1"}))
+		})
+
+		It("allows rewriting the same key", func() {
+			put("1", order{Email: "alice@example.com", Status: "open"})
+			Expect(lookupEmail("alice@example.com")).To(Equal([]string{"1"}))
+		})
+
+		It("fails with unique violation for another key", func() {
+			err = putErr(ctx, "2", order{Email: "alice@example.com"})
+			Expect(errors.Is(err, libbadgerkv.ErrUniqueViolation)).To(BeTrue())
+			Expect(lookupEmail("alice@example.com")).To(Equal([]string{"1"}))
+		})
+
+		It("changes nothing if the violation is ignored", func() {
+			put("2", order{Email: "bob@example.com"})
+			Expect(db.EnableBucketStats(ctx, orders)).To(Succeed())
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, orders)
+				if err != nil {
+					return err
+				}
+				value, err := json.Marshal(order{Email: "alice@example.com"})
+				Expect(err).To(BeNil())
+				err = bucket.Put(ctx, []byte("2"), value)
+				Expect(errors.Is(err, libbadgerkv.ErrUniqueViolation)).To(BeTrue())
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(lookupEmail("bob@example.com")).To(Equal([]string{"2"}))
+			Expect(lookupEmail("alice@example.com")).To(Equal([]string{"1"}))
+			check, err := db.VerifyBucketStats(ctx, orders)
+			Expect(err).To(BeNil())
+			Expect(check.OK()).To(BeTrue())
+		})
+
+		It("frees index key after change", func() {
+			put("1", order{Email: "alice@example.org"})
+			put("2", order{Email: "alice@example.com"})
+			Expect(lookupEmail("alice@example.com")).To(Equal([]string{"2"}))
+			Expect(lookupEmail("alice@example.org")).To(Equal([]string{"1"}))
+		})
+
+		It("rejects concurrent writers of the same index key", func() {
+			err = putErr(ctx, "2", order{Email: "bob@example.com"})
+			Expect(err).To(BeNil())
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, orders)
+				if err != nil {
+					return err
+				}
+				value, err := json.Marshal(order{Email: "carol@example.com"})
+				Expect(err).To(BeNil())
+				if err := bucket.Put(ctx, []byte("3"), value); err != nil {
+					return err
+				}
+				// a concurrent transaction commits the same email first
+				other := order{Email: "carol@example.com"}
+				Expect(putErr(context.Background(), "4", other)).To(Succeed())
+				return nil
+			})
+			Expect(errors.Is(err, badger.ErrConflict)).To(BeTrue())
+			Expect(lookupEmail("carol@example.com")).To(Equal([]string{"4"}))
+		})
+
+		It("fails rebuild for duplicates", func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, orders)
+				if err != nil {
+					return err
+				}
+				badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+				Expect(ok).To(BeTrue())
+				// bypass the index like keys written before it was registered
+				raw := libbadgerkv.NewBucket(badgerBucket.Tx(), orders)
+				value, err := json.Marshal(order{Email: "alice@example.com"})
+				Expect(err).To(BeNil())
+				return raw.Put(ctx, []byte("2"), value)
+			})
+			Expect(err).To(BeNil())
+			err = db.RebuildIndex(ctx, orders, "email")
+			Expect(errors.Is(err, libbadgerkv.ErrUniqueViolation)).To(BeTrue())
+		})
+	})
+
+	Context("RebuildIndex", func() {
+		BeforeEach(func() {
+			for i := 0; i < libbadgerkv.DefaultBatchSize+3; i++ {
+				put(fmt.Sprintf("order-%05d", i), order{Status: "open"})
+			}
+			put("1", order{Status: "shipped"})
+		})
+
+		It("indexes existing keys", func() {
+			Expect(db.RegisterIndex(ctx, orders, statusIndex)).To(Succeed())
+			Expect(lookup("shipped")).To(BeEmpty())
+
+			Expect(db.RebuildIndex(ctx, orders, "status")).To(Succeed())
+			Expect(lookup("shipped")).To(Equal([]string{"1"}))
+			Expect(lookup("open")).To(HaveLen(libbadgerkv.DefaultBatchSize + 3))
+		})
+
+		It("fails for unregistered index", func() {
+			err = db.RebuildIndex(ctx, orders, "status")
+			Expect(errors.Is(err, libbadgerkv.ErrIndexNotFound)).To(BeTrue())
+		})
+	})
+})
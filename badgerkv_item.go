@@ -5,6 +5,8 @@
 package badgerkv
 
 import (
+	"context"
+
 	libkv "github.com/bborbe/kv"
 	"github.com/dgraph-io/badger/v4"
 )
@@ -18,16 +20,26 @@ type Item interface {
 func NewItem(
 	bucketName libkv.BucketName,
 	badgerItem *badger.Item,
+) Item {
+	return newItem(bucketName, badgerItem, defaultValueCodec)
+}
+
+func newItem(
+	bucketName libkv.BucketName,
+	badgerItem *badger.Item,
+	values *valueCodec,
 ) Item {
 	return &item{
 		badgerItem: badgerItem,
 		bucketName: bucketName,
+		values:     values,
 	}
 }
 
 type item struct {
 	badgerItem *badger.Item
 	bucketName libkv.BucketName
+	values     *valueCodec
 }
 
 func (i *item) BucketName() libkv.BucketName {
@@ -46,6 +58,8 @@ func (i *item) Key() []byte {
 	return BucketRemoveKey(i.bucketName, i.badgerItem.Key())
 }
 
+// Value calls fn with the value, decompressed if it was stored compressed. Values
+// stored with checksum fail with ErrChecksumMismatch if they are corrupted.
 func (i *item) Value(fn func(val []byte) error) error {
-	return i.badgerItem.Value(fn)
+	return i.values.ItemValue(context.Background(), i.bucketName, i.badgerItem, fn)
 }
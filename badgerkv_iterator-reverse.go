@@ -12,6 +12,14 @@ import (
 func NewIteratorReverse(
 	badgerTx *badger.Txn,
 	bucketName libkv.BucketName,
+) Iterator {
+	return newIteratorReverse(badgerTx, bucketName, defaultValueCodec)
+}
+
+func newIteratorReverse(
+	badgerTx *badger.Txn,
+	bucketName libkv.BucketName,
+	values *valueCodec,
 ) Iterator {
 	opts := badger.DefaultIteratorOptions
 	opts.PrefetchSize = 10
@@ -19,12 +27,14 @@ func NewIteratorReverse(
 	return &iteratorReverse{
 		badgerIterator: badgerTx.NewIterator(opts),
 		bucketName:     bucketName,
+		values:         values,
 	}
 }
 
 type iteratorReverse struct {
 	badgerIterator *badger.Iterator
 	bucketName     libkv.BucketName
+	values         *valueCodec
 }
 
 func (i iteratorReverse) BucketName() libkv.BucketName {
@@ -40,9 +50,10 @@ func (i iteratorReverse) Close() {
 }
 
 func (i iteratorReverse) Item() libkv.Item {
-	return NewItem(
+	return newItem(
 		i.bucketName,
 		i.badgerIterator.Item(),
+		i.values,
 	)
 }
 
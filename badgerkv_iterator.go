@@ -18,6 +18,14 @@ type Iterator interface {
 func NewIterator(
 	badgerTx *badger.Txn,
 	bucketName libkv.BucketName,
+) Iterator {
+	return newIterator(badgerTx, bucketName, defaultValueCodec)
+}
+
+func newIterator(
+	badgerTx *badger.Txn,
+	bucketName libkv.BucketName,
+	values *valueCodec,
 ) Iterator {
 	opts := badger.DefaultIteratorOptions
 	opts.PrefetchSize = 10
@@ -25,12 +33,14 @@ func NewIterator(
 	return &iterator{
 		badgerIterator: badgerTx.NewIterator(opts),
 		bucketName:     bucketName,
+		values:         values,
 	}
 }
 
 type iterator struct {
 	badgerIterator *badger.Iterator
 	bucketName     libkv.BucketName
+	values         *valueCodec
 }
 
 func (i iterator) BucketName() libkv.BucketName {
@@ -46,9 +56,10 @@ func (i iterator) Close() {
 }
 
 func (i iterator) Item() libkv.Item {
-	return NewItem(
+	return newItem(
 		i.bucketName,
 		i.badgerIterator.Item(),
+		i.values,
 	)
 }
 
@@ -15,7 +15,7 @@ const bucketKeySeperator = byte('_')
 const bucketKeySeperatorPlusone = byte('_' + 1)
 
 func BucketToPrefix(bucket libkv.BucketName) []byte {
-	return append(bucket, bucketKeySeperator)
+	return BucketAddKey(bucket, nil)
 }
 
 func BucketAddKey(bucket libkv.BucketName, key []byte) []byte {
@@ -0,0 +1,503 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// namespaceSeparator joins a namespace and the names of its buckets. It differs from
+// the sub-bucket separator, so buckets of namespaces are top level buckets.
+const namespaceSeparator = byte(0x1E)
+
+// NamespaceBucketName returns the full name of the bucket name of the namespace, as
+// listed by the DB the namespace was created from.
+func NamespaceBucketName(namespace string, name libkv.BucketName) libkv.BucketName {
+	return libkv.BucketName(append(namespacePrefix(nil, namespace), name...))
+}
+
+func namespacePrefix(parent []byte, namespace string) []byte {
+	result := make([]byte, 0, len(parent)+len(namespace)+1)
+	result = append(result, parent...)
+	result = append(result, namespace...)
+	return append(result, namespaceSeparator)
+}
+
+func validateNamespace(ctx context.Context, namespace string) error {
+	if namespace == "" || strings.ContainsAny(namespace, string([]byte{
+		namespaceSeparator,
+		subBucketSeparator,
+		bucketKeySeperator,
+	})) {
+		return errors.Wrapf(ctx, ErrInvalidNamespace, "namespace %q", namespace)
+	}
+	return nil
+}
+
+// Namespace returns a view of the DB whose bucket names are prefixed with the namespace,
+// so tenants sharing one Badger instance never see each other's buckets. The name must
+// not be empty or contain the separators 0x1E, 0x1F and '_', because the keys of bucket t
+// start with the prefix t_a of namespace t_a. Other names fail on first use with
+// ErrInvalidNamespace. Namespaces can be nested.
+//
+// Close of the view is a no-op and Remove fails, the DB is closed by its owner and
+// namespaces are dropped with DropNamespace. Compact, Verify, Ready, Repair and
+// RegisterCompressionDict act on the whole DB. Bucket handles and items report the full
+// bucket name.
+func (b *badgerdb) Namespace(name string) DB {
+	return &namespace{db: b, name: name, prefix: namespacePrefix(nil, name)}
+}
+
+// DropNamespace deletes all buckets of the namespace and its nested namespaces with
+// Badger's DropPrefix, including registry entries, counters, index entries, stream
+// chunks and sequences, and removes the indexes registered for its buckets. Writers of
+// the namespace must be stopped before.
+func (b *badgerdb) DropNamespace(ctx context.Context, name string) error {
+	if err := validateNamespace(ctx, name); err != nil {
+		return err
+	}
+	if err := b.dropNamespace(ctx, namespacePrefix(nil, name)); err != nil {
+		return errors.Wrapf(ctx, err, "drop namespace %s failed", name)
+	}
+	return nil
+}
+
+func (b *badgerdb) dropNamespace(ctx context.Context, prefix []byte) error {
+	indexEntries, err := indexPrefix(ctx, Tuple{prefix})
+	if err != nil {
+		return err
+	}
+	chunks, err := chunkPrefix(ctx, Tuple{prefix})
+	if err != nil {
+		return err
+	}
+	statShards, err := Tuple{prefix}.Pack(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "pack counter shard prefix failed")
+	}
+	prefixes := [][]byte{
+		prefix,
+		BucketAddKey(bucketRegistryName, prefix),
+		BucketAddKey(bucketMetaName, prefix),
+		BucketAddKey(bucketStatsName, prefix),
+		BucketAddKey(sequenceBucketName, prefix),
+		// without the terminator of the packed bytes, to match all names of the namespace
+		indexEntries[:len(indexEntries)-1],
+		chunks[:len(chunks)-1],
+		BucketAddKey(bucketStatShardsName, statShards[:len(statShards)-1]),
+	}
+	if err := b.db.DropPrefix(prefixes...); err != nil {
+		return errors.Wrapf(ctx, err, "drop prefix failed")
+	}
+	b.indexes.RemovePrefix(prefix)
+	glog.V(2).Infof("drop namespace %q completed", prefix[:len(prefix)-1])
+	return nil
+}
+
+// bucketPrefixLister lists the registered buckets starting with prefix without reading
+// the whole registry. It is implemented by the transactions and snapshots of the DB.
+type bucketPrefixLister interface {
+	listBucketNamesWithPrefix(ctx context.Context, prefix []byte) ([]libkv.BucketName, error)
+}
+
+// listNamespaceBuckets returns the full names of the top level buckets of the namespace
+// with prefix, without buckets of nested namespaces.
+func listNamespaceBuckets(
+	ctx context.Context,
+	tx libkv.Tx,
+	prefix []byte,
+) (libkv.BucketNames, error) {
+	lister, ok := tx.(bucketPrefixLister)
+	if !ok {
+		return nil, errors.Errorf(ctx, "unexpected tx type %T", tx)
+	}
+	names, err := lister.listBucketNamesWithPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	result := libkv.BucketNames{}
+	for _, name := range names {
+		rest := name[len(prefix):]
+		if bytes.IndexByte(rest, namespaceSeparator) >= 0 {
+			continue
+		}
+		if bytes.IndexByte(rest, subBucketSeparator) >= 0 {
+			continue
+		}
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+type namespace struct {
+	db     *badgerdb
+	name   string
+	prefix []byte
+}
+
+// bucketName returns the full name of the bucket name of the namespace.
+func (n *namespace) bucketName(name libkv.BucketName) libkv.BucketName {
+	return libkv.BucketName(append(bytes.Clone(n.prefix), name...))
+}
+
+func (n *namespace) wrap(
+	fn func(ctx context.Context, tx libkv.Tx) error,
+) func(ctx context.Context, tx libkv.Tx) error {
+	return func(ctx context.Context, tx libkv.Tx) error {
+		if err := validateNamespace(ctx, n.name); err != nil {
+			return err
+		}
+		badgerTx, ok := tx.(Tx)
+		if !ok {
+			return errors.Errorf(ctx, "unexpected tx type %T", tx)
+		}
+		return fn(ctx, &namespaceTx{tx: badgerTx, namespace: n})
+	}
+}
+
+func (n *namespace) Update(
+	ctx context.Context,
+	fn func(ctx context.Context, tx libkv.Tx) error,
+) error {
+	return n.db.Update(ctx, n.wrap(fn))
+}
+
+func (n *namespace) View(
+	ctx context.Context,
+	fn func(ctx context.Context, tx libkv.Tx) error,
+) error {
+	return n.db.View(ctx, n.wrap(fn))
+}
+
+func (n *namespace) ViewAt(
+	ctx context.Context,
+	readTs uint64,
+	fn func(ctx context.Context, tx libkv.Tx) error,
+) error {
+	return n.db.ViewAt(ctx, readTs, n.wrap(fn))
+}
+
+func (n *namespace) Sync() error {
+	return n.db.Sync()
+}
+
+// Close is a no-op, the DB is closed by its owner.
+func (n *namespace) Close() error {
+	return nil
+}
+
+// Remove fails, namespaces are dropped with DropNamespace of the parent.
+func (n *namespace) Remove() error {
+	return errors.Wrapf(context.Background(), ErrInvalidNamespace, "remove namespace %s", n.name)
+}
+
+// Stats returns the stats of the buckets of the namespace. SizeB is their sum.
+func (n *namespace) Stats(ctx context.Context) (*libkv.Stats, error) {
+	if err := validateNamespace(ctx, n.name); err != nil {
+		return nil, err
+	}
+	return n.db.statsImpl(ctx, n.prefix, false)
+}
+
+// StatsDetailed returns Stats plus the key counts of the buckets of the namespace.
+func (n *namespace) StatsDetailed(ctx context.Context) (*libkv.Stats, error) {
+	if err := validateNamespace(ctx, n.name); err != nil {
+		return nil, err
+	}
+	return n.db.statsImpl(ctx, n.prefix, true)
+}
+
+func (n *namespace) DB() *badger.DB {
+	return n.db.DB()
+}
+
+func (n *namespace) CurrentVersion() uint64 {
+	return n.db.CurrentVersion()
+}
+
+func (n *namespace) Snapshot(ctx context.Context) (Snapshot, error) {
+	return n.SnapshotWithMaxAge(ctx, DefaultSnapshotMaxAge)
+}
+
+func (n *namespace) SnapshotWithMaxAge(
+	ctx context.Context,
+	maxAge time.Duration,
+) (Snapshot, error) {
+	if err := validateNamespace(ctx, n.name); err != nil {
+		return nil, err
+	}
+	snapshot, err := n.db.SnapshotWithMaxAge(ctx, maxAge)
+	if err != nil {
+		return nil, err
+	}
+	return &namespaceSnapshot{
+		namespaceTx: &namespaceTx{tx: snapshot, namespace: n},
+		snapshot:    snapshot,
+	}, nil
+}
+
+func (n *namespace) SnapshotStats() SnapshotStats {
+	return n.db.SnapshotStats()
+}
+
+func (n *namespace) Increment(
+	ctx context.Context,
+	bucketName libkv.BucketName,
+	key []byte,
+	delta int64,
+) (int64, error) {
+	return n.db.Increment(ctx, n.bucketName(bucketName), key, delta)
+}
+
+func (n *namespace) Sequence(ctx context.Context, name []byte, bandwidth uint64) (Sequence, error) {
+	return n.db.Sequence(ctx, n.bucketName(name), bandwidth)
+}
+
+func (n *namespace) CopyBucket(
+	ctx context.Context,
+	src libkv.BucketName,
+	dst libkv.BucketName,
+) error {
+	return n.db.CopyBucket(ctx, n.bucketName(src), n.bucketName(dst))
+}
+
+func (n *namespace) RenameBucket(
+	ctx context.Context,
+	oldName libkv.BucketName,
+	newName libkv.BucketName,
+) error {
+	return n.db.RenameBucket(ctx, n.bucketName(oldName), n.bucketName(newName))
+}
+
+func (n *namespace) TruncateBucket(ctx context.Context, name libkv.BucketName) error {
+	return n.db.TruncateBucket(ctx, n.bucketName(name))
+}
+
+func (n *namespace) EnableBucketStats(ctx context.Context, name libkv.BucketName) error {
+	return n.db.EnableBucketStats(ctx, n.bucketName(name))
+}
+
+func (n *namespace) DisableBucketStats(ctx context.Context, name libkv.BucketName) error {
+	return n.db.DisableBucketStats(ctx, n.bucketName(name))
+}
+
+func (n *namespace) VerifyBucketStats(
+	ctx context.Context,
+	name libkv.BucketName,
+) (*BucketStatsCheck, error) {
+	check, err := n.db.VerifyBucketStats(ctx, n.bucketName(name))
+	if err != nil {
+		return nil, err
+	}
+	check.Name = name
+	check.Tracked.Name = name
+	check.Actual.Name = name
+	return check, nil
+}
+
+func (n *namespace) EstimateBucketSize(
+	ctx context.Context,
+	name libkv.BucketName,
+) (*BucketSize, error) {
+	size, err := n.db.EstimateBucketSize(ctx, n.bucketName(name))
+	if err != nil {
+		return nil, err
+	}
+	size.Name = name
+	return size, nil
+}
+
+func (n *namespace) Compact(ctx context.Context, opts CompactOptions) error {
+	return n.db.Compact(ctx, opts)
+}
+
+func (n *namespace) Verify(ctx context.Context) (*VerifyReport, error) {
+	return n.db.Verify(ctx)
+}
+
+func (n *namespace) Ready(ctx context.Context) error {
+	return n.db.Ready(ctx)
+}
+
+func (n *namespace) Repair(ctx context.Context, opts RepairOptions) (*RepairReport, error) {
+	return n.db.Repair(ctx, opts)
+}
+
+func (n *namespace) RegisterIndex(ctx context.Context, name libkv.BucketName, index Index) error {
+	return n.db.RegisterIndex(ctx, n.bucketName(name), index)
+}
+
+func (n *namespace) RebuildIndex(
+	ctx context.Context,
+	name libkv.BucketName,
+	indexName string,
+) error {
+	return n.db.RebuildIndex(ctx, n.bucketName(name), indexName)
+}
+
+func (n *namespace) SchemaReport(
+	ctx context.Context,
+	name libkv.BucketName,
+) (*SchemaReport, error) {
+	report, err := n.db.SchemaReport(ctx, n.bucketName(name))
+	if err != nil {
+		return nil, err
+	}
+	report.Name = name
+	return report, nil
+}
+
+func (n *namespace) UpgradeBucket(
+	ctx context.Context,
+	name libkv.BucketName,
+	schema Schema,
+) (*SchemaReport, error) {
+	report, err := n.db.UpgradeBucket(ctx, n.bucketName(name), schema)
+	if err != nil {
+		return nil, err
+	}
+	report.Name = name
+	return report, nil
+}
+
+func (n *namespace) RegisterCompressionDict(ctx context.Context, id uint32, dict []byte) error {
+	return n.db.RegisterCompressionDict(ctx, id, dict)
+}
+
+func (n *namespace) PutStream(
+	ctx context.Context,
+	name libkv.BucketName,
+	key []byte,
+	r io.Reader,
+) error {
+	return n.db.PutStream(ctx, n.bucketName(name), key, r)
+}
+
+// Namespace returns the nested namespace name.
+func (n *namespace) Namespace(name string) DB {
+	return &namespace{db: n.db, name: name, prefix: namespacePrefix(n.prefix, name)}
+}
+
+// DropNamespace drops the nested namespace name.
+func (n *namespace) DropNamespace(ctx context.Context, name string) error {
+	if err := validateNamespace(ctx, n.name); err != nil {
+		return err
+	}
+	if err := validateNamespace(ctx, name); err != nil {
+		return err
+	}
+	if err := n.db.dropNamespace(ctx, namespacePrefix(n.prefix, name)); err != nil {
+		return errors.Wrapf(ctx, err, "drop namespace %s failed", name)
+	}
+	return nil
+}
+
+// namespaceTx prefixes the bucket names of tx with the namespace.
+type namespaceTx struct {
+	tx        Tx
+	namespace *namespace
+}
+
+func (t *namespaceTx) Tx() *badger.Txn {
+	return t.tx.Tx()
+}
+
+func (t *namespaceTx) Bucket(ctx context.Context, name libkv.BucketName) (libkv.Bucket, error) {
+	return t.tx.Bucket(ctx, t.namespace.bucketName(name))
+}
+
+func (t *namespaceTx) CreateBucket(
+	ctx context.Context,
+	name libkv.BucketName,
+) (libkv.Bucket, error) {
+	return t.tx.CreateBucket(ctx, t.namespace.bucketName(name))
+}
+
+func (t *namespaceTx) CreateBucketIfNotExists(
+	ctx context.Context,
+	name libkv.BucketName,
+) (libkv.Bucket, error) {
+	return t.tx.CreateBucketIfNotExists(ctx, t.namespace.bucketName(name))
+}
+
+func (t *namespaceTx) DeleteBucket(ctx context.Context, name libkv.BucketName) error {
+	return t.tx.DeleteBucket(ctx, t.namespace.bucketName(name))
+}
+
+// ListBucketNames returns the names of the top level buckets of the namespace.
+func (t *namespaceTx) ListBucketNames(ctx context.Context) (libkv.BucketNames, error) {
+	names, err := listNamespaceBuckets(ctx, t.tx, t.namespace.prefix)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "list buckets of namespace %s failed", t.namespace.name)
+	}
+	for i, name := range names {
+		names[i] = name[len(t.namespace.prefix):]
+	}
+	return names, nil
+}
+
+func (t *namespaceTx) CopyBucket(
+	ctx context.Context,
+	src libkv.BucketName,
+	dst libkv.BucketName,
+) error {
+	return t.tx.CopyBucket(ctx, t.namespace.bucketName(src), t.namespace.bucketName(dst))
+}
+
+func (t *namespaceTx) RenameBucket(
+	ctx context.Context,
+	oldName libkv.BucketName,
+	newName libkv.BucketName,
+) error {
+	return t.tx.RenameBucket(ctx, t.namespace.bucketName(oldName), t.namespace.bucketName(newName))
+}
+
+func (t *namespaceTx) ClearBucket(ctx context.Context, name libkv.BucketName) error {
+	return t.tx.ClearBucket(ctx, t.namespace.bucketName(name))
+}
+
+func (t *namespaceTx) BucketInfo(ctx context.Context, name libkv.BucketName) (*BucketInfo, error) {
+	info, err := t.tx.BucketInfo(ctx, t.namespace.bucketName(name))
+	if err != nil {
+		return nil, err
+	}
+	info.Name = name
+	return info, nil
+}
+
+func (t *namespaceTx) SetBucketMeta(
+	ctx context.Context,
+	name libkv.BucketName,
+	meta BucketMeta,
+) error {
+	return t.tx.SetBucketMeta(ctx, t.namespace.bucketName(name), meta)
+}
+
+// namespaceSnapshot is a Snapshot whose bucket names are prefixed with the namespace.
+type namespaceSnapshot struct {
+	*namespaceTx
+	snapshot Snapshot
+}
+
+func (s *namespaceSnapshot) Version() uint64 {
+	return s.snapshot.Version()
+}
+
+func (s *namespaceSnapshot) CreatedAt() time.Time {
+	return s.snapshot.CreatedAt()
+}
+
+func (s *namespaceSnapshot) Release() {
+	s.snapshot.Release()
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Namespace", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var tenantA libbadgerkv.DB
+	var tenantB libbadgerkv.DB
+	var orders libkv.BucketName
+	var err error
+
+	put := func(db libbadgerkv.DB, key string, value string) error {
+		return db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, orders)
+			if err != nil {
+				return err
+			}
+			return bucket.Put(ctx, []byte(key), []byte(value))
+		})
+	}
+
+	get := func(db libbadgerkv.DB, key string) (string, error) {
+		var result string
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, orders)
+			if err != nil {
+				return err
+			}
+			item, err := bucket.Get(ctx, []byte(key))
+			if err != nil {
+				return err
+			}
+			return item.Value(func(val []byte) error {
+				result = string(val)
+				return nil
+			})
+		})
+		return result, err
+	}
+
+	list := func(db libbadgerkv.DB) libkv.BucketNames {
+		var result libkv.BucketNames
+		Expect(db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			result, err = tx.ListBucketNames(ctx)
+			return err
+		})).To(Succeed())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		tenantA = db.Namespace("a")
+		tenantB = db.Namespace("b")
+		orders = libkv.NewBucketName("orders")
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("isolates buckets of the same name", func() {
+		Expect(put(tenantA, "1", "from a")).To(Succeed())
+		Expect(put(tenantB, "1", "from b")).To(Succeed())
+		Expect(get(tenantA, "1")).To(Equal("from a"))
+		Expect(get(tenantB, "1")).To(Equal("from b"))
+		_, err = get(db, "1")
+		Expect(errors.Is(err, libkv.ErrBucketNotFound)).To(BeTrue())
+	})
+
+	It("lists only the buckets of the namespace", func() {
+		Expect(put(db, "1", "root")).To(Succeed())
+		Expect(put(tenantA, "1", "a")).To(Succeed())
+		Expect(put(tenantA.Namespace("nested"), "1", "nested")).To(Succeed())
+		Expect(tenantA.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, orders)
+			if err != nil {
+				return err
+			}
+			badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			_, err = badgerBucket.CreateSubBucket(ctx, libkv.NewBucketName("archive"))
+			return err
+		})).To(Succeed())
+		Expect(list(tenantA)).To(Equal(libkv.BucketNames{orders}))
+		Expect(list(tenantB)).To(BeEmpty())
+		Expect(list(tenantA.Namespace("nested"))).To(Equal(libkv.BucketNames{orders}))
+		Expect(list(db)).To(ContainElement(orders))
+		Expect(list(db)).To(ContainElement(libbadgerkv.NamespaceBucketName("a", orders)))
+	})
+
+	It("returns the stats of the namespace", func() {
+		Expect(put(tenantA, "1", "a")).To(Succeed())
+		Expect(put(tenantA, "2", "a")).To(Succeed())
+		Expect(put(tenantB, "1", "b")).To(Succeed())
+		stats, err := tenantA.StatsDetailed(ctx)
+		Expect(err).To(BeNil())
+		Expect(stats.Buckets).To(HaveLen(1))
+		Expect(stats.Buckets[0].Name).To(Equal(orders))
+		Expect(stats.Buckets[0].KeyCount).To(Equal(int64(2)))
+	})
+
+	It("drops a namespace", func() {
+		Expect(put(tenantA, "1", "a")).To(Succeed())
+		Expect(put(tenantA.Namespace("nested"), "1", "nested")).To(Succeed())
+		Expect(put(tenantB, "1", "b")).To(Succeed())
+		_, err = tenantA.Increment(ctx, orders, []byte("count"), 1)
+		Expect(err).To(BeNil())
+
+		Expect(db.DropNamespace(ctx, "a")).To(Succeed())
+		Expect(list(tenantA)).To(BeEmpty())
+		Expect(list(tenantA.Namespace("nested"))).To(BeEmpty())
+		Expect(get(tenantB, "1")).To(Equal("b"))
+		report, err := db.Verify(ctx)
+		Expect(err).To(BeNil())
+		Expect(report.OK()).To(BeTrue())
+	})
+
+	It("removes the indexes of a dropped namespace", func() {
+		index := libbadgerkv.Index{
+			Name: "value",
+			Func: func(ctx context.Context, value []byte) ([][]byte, error) {
+				return [][]byte{value}, nil
+			},
+		}
+		Expect(tenantA.RegisterIndex(ctx, orders, index)).To(Succeed())
+		Expect(tenantB.RegisterIndex(ctx, orders, index)).To(Succeed())
+
+		Expect(db.DropNamespace(ctx, "a")).To(Succeed())
+		Expect(tenantA.RegisterIndex(ctx, orders, index)).To(Succeed())
+		err = tenantB.RegisterIndex(ctx, orders, index)
+		Expect(errors.Is(err, libbadgerkv.ErrIndexExists)).To(BeTrue())
+	})
+
+	It("drops a nested namespace", func() {
+		Expect(put(tenantA, "1", "a")).To(Succeed())
+		Expect(put(tenantA.Namespace("nested"), "1", "nested")).To(Succeed())
+		Expect(tenantA.DropNamespace(ctx, "nested")).To(Succeed())
+		Expect(list(tenantA.Namespace("nested"))).To(BeEmpty())
+		Expect(get(tenantA, "1")).To(Equal("a"))
+	})
+
+	It("rejects invalid names", func() {
+		err = db.DropNamespace(ctx, "")
+		Expect(errors.Is(err, libbadgerkv.ErrInvalidNamespace)).To(BeTrue())
+		err = put(db.Namespace("a\x1Eb"), "1", "a")
+		Expect(errors.Is(err, libbadgerkv.ErrInvalidNamespace)).To(BeTrue())
+		err = db.DropNamespace(ctx, "t_a")
+		Expect(errors.Is(err, libbadgerkv.ErrInvalidNamespace)).To(BeTrue())
+		Expect(errors.Is(tenantA.Remove(), libbadgerkv.ErrInvalidNamespace)).To(BeTrue())
+	})
+
+	It("reads a snapshot of the namespace", func() {
+		Expect(put(tenantA, "1", "before")).To(Succeed())
+		snapshot, err := tenantA.Snapshot(ctx)
+		Expect(err).To(BeNil())
+		defer snapshot.Release()
+		Expect(put(tenantA, "1", "after")).To(Succeed())
+
+		bucket, err := snapshot.Bucket(ctx, orders)
+		Expect(err).To(BeNil())
+		item, err := bucket.Get(ctx, []byte("1"))
+		Expect(err).To(BeNil())
+		Expect(item.Value(func(val []byte) error {
+			Expect(string(val)).To(Equal("before"))
+			return nil
+		})).To(Succeed())
+		Expect(snapshot.ListBucketNames(ctx)).To(Equal(libkv.BucketNames{orders}))
+	})
+})
@@ -0,0 +1,223 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// RepairOptions configures Repair. Reregister and DropOrphans exclude each other.
+type RepairOptions struct {
+	// DryRun only reports what would be repaired.
+	DryRun bool
+	// Reregister registers the buckets of orphaned keys and rewrites registry entries
+	// whose metadata can not be parsed.
+	Reregister bool
+	// Buckets are the names orphaned keys are registered under on Reregister. Keys
+	// starting with none of them are only registered if they contain a single separator.
+	Buckets []libkv.BucketName
+	// DropOrphans deletes orphaned keys, index entries and stream chunks and the counters
+	// and metadata entries of unregistered buckets.
+	DropOrphans bool
+}
+
+// RepairReport is the result of Repair.
+type RepairReport struct {
+	DryRun bool `json:"dry_run"`
+	// Verify are the findings before the repair.
+	Verify VerifyReport `json:"verify"`
+	// Reregistered are the buckets registered for orphaned keys.
+	Reregistered []libkv.BucketName `json:"reregistered,omitempty"`
+	// RewrittenEntries are registry entries replaced because they could not be parsed.
+	RewrittenEntries []libkv.BucketName `json:"rewritten_entries,omitempty"`
+	// AmbiguousKeys is the number of orphaned keys not reregistered because their bucket
+	// name is unknown.
+	AmbiguousKeys int64 `json:"ambiguous_keys"`
+	// DroppedKeys is the number of orphaned keys deleted.
+	DroppedKeys int64 `json:"dropped_keys"`
+	// DroppedCounters is the number of counters and metadata entries of unregistered
+	// buckets deleted.
+	DroppedCounters int64 `json:"dropped_counters"`
+	// DroppedIndexEntries is the number of orphaned index entries deleted.
+	DroppedIndexEntries int64 `json:"dropped_index_entries"`
+	// DroppedChunks is the number of orphaned stream chunks deleted.
+	DroppedChunks int64 `json:"dropped_chunks"`
+	// InProgressKeys is the number of orphaned keys, index entries and chunks left
+	// untouched because a running DB.CopyBucket, DB.RenameBucket or DB.PutStream writes
+	// them and registers them at its end.
+	InProgressKeys int64 `json:"in_progress_keys"`
+}
+
+// Repair fixes the inconsistencies Verify reports between the bucket registry and the
+// keys, for example buckets half deleted by a crash in DeleteBucket of older versions,
+// which removed the registry entry before the keys. Malformed keys and checksum errors are
+// only reported.
+//
+// On Reregister an orphaned key belongs to the longest of opts.Buckets it starts with.
+// Otherwise the bucket name is only derived from keys with a single separator, because
+// users_v2_1 may be key v2_1 of users or key 1 of users_v2. The other keys are counted as
+// AmbiguousKeys and left untouched. Run with DryRun first to check the result.
+//
+// Keys of buckets reserved by DB.CopyBucket and DB.RenameBucket and chunks of streams
+// written by DB.PutStream are orphaned until the operation registers them. Repair skips
+// them if the operation runs at any time during the repair and counts them as
+// InProgressKeys.
+func (b *badgerdb) Repair(ctx context.Context, opts RepairOptions) (*RepairReport, error) {
+	if opts.Reregister && opts.DropOrphans {
+		return nil, errors.Errorf(ctx, "reregister and drop orphans exclude each other")
+	}
+	// hold before the snapshot, so operations writing keys of the snapshot are recorded
+	releaseCopies := b.copies.Hold()
+	defer releaseCopies()
+	releaseStreams := b.streams.Hold()
+	defer releaseStreams()
+	snapshot, err := b.Snapshot(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "create snapshot failed")
+	}
+	defer snapshot.Release()
+
+	report := &RepairReport{DryRun: opts.DryRun}
+	deleter := b.newKeyDeleter()
+	orphanBuckets := make(map[string]bool)
+	err = verifyKeys(
+		ctx,
+		snapshot.Tx(),
+		&report.Verify,
+		func(item *badger.Item, kind orphanKind, name []byte) error {
+			if b.inProgress(ctx, item.Key(), kind, name) {
+				report.InProgressKeys++
+				return nil
+			}
+			switch {
+			case opts.Reregister && kind == orphanedKey:
+				name, ok := orphanBucketName(item.Key(), opts.Buckets)
+				if !ok {
+					report.AmbiguousKeys++
+					return nil
+				}
+				orphanBuckets[string(name)] = true
+				return nil
+			case !opts.DropOrphans:
+				return nil
+			case kind == orphanedCounter:
+				report.DroppedCounters++
+			case kind == orphanedIndexEntry:
+				report.DroppedIndexEntries++
+			case kind == orphanedChunk:
+				report.DroppedChunks++
+			default:
+				report.DroppedKeys++
+			}
+			if opts.DryRun {
+				return nil
+			}
+			return deleter.Add(ctx, item)
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "verify keys failed")
+	}
+	if err := deleter.Flush(ctx); err != nil {
+		return nil, errors.Wrapf(ctx, err, "delete orphans failed")
+	}
+	if opts.Reregister {
+		report.Reregistered = sortedBucketNames(orphanBuckets)
+		report.RewrittenEntries = report.Verify.InvalidRegistryEntries
+		if err := b.reregister(ctx, report); err != nil {
+			return nil, errors.Wrapf(ctx, err, "reregister buckets failed")
+		}
+	}
+	glog.V(2).Infof("repair completed: %+v", report)
+	return report, nil
+}
+
+// inProgress returns true if the orphaned key of kind is written by a DB.CopyBucket,
+// DB.RenameBucket or DB.PutStream running since the reservations are held.
+func (b *badgerdb) inProgress(
+	ctx context.Context,
+	key []byte,
+	kind orphanKind,
+	name []byte,
+) bool {
+	switch kind {
+	case orphanedKey:
+		return b.copies.Held(key, true)
+	case orphanedChunk:
+		if b.copies.Held(name, false) {
+			return true
+		}
+		_, stream, _, ok := parseChunkKey(ctx, key)
+		return ok && b.streams.Held(stream, false)
+	default:
+		return b.copies.Held(name, false)
+	}
+}
+
+// reregister registers the buckets of orphaned keys and rewrites invalid registry entries.
+func (b *badgerdb) reregister(ctx context.Context, report *RepairReport) error {
+	if report.DryRun {
+		return nil
+	}
+	return b.updateTxn(ctx, func(ctx context.Context, txn *badger.Txn) error {
+		t := newTx(txn)
+		for _, name := range report.RewrittenEntries {
+			if err := t.putBucketInfo(ctx, BucketInfo{Name: name}); err != nil {
+				return errors.Wrapf(ctx, err, "rewrite bucket %s failed", name)
+			}
+		}
+		for _, name := range report.Reregistered {
+			exists, err := t.existsBucket(ctx, name)
+			if err != nil {
+				return errors.Wrapf(ctx, err, "check exists of %s failed", name)
+			}
+			if exists {
+				continue
+			}
+			if _, err := t.createBucket(ctx, name); err != nil {
+				return errors.Wrapf(ctx, err, "create bucket %s failed", name)
+			}
+		}
+		return nil
+	})
+}
+
+// orphanBucketName returns the longest of names key starts with, or the part of key before
+// the separator if key contains only one. It returns false if the bucket is ambiguous.
+func orphanBucketName(key []byte, names []libkv.BucketName) (libkv.BucketName, bool) {
+	var result libkv.BucketName
+	for _, name := range names {
+		if len(name) > len(result) && bytes.HasPrefix(key, BucketToPrefix(name)) {
+			result = name
+		}
+	}
+	if result != nil {
+		return bytes.Clone(result), true
+	}
+	if bytes.Count(key, []byte{bucketKeySeperator}) != 1 {
+		return nil, false
+	}
+	return bytes.Clone(key[:bytes.IndexByte(key, bucketKeySeperator)]), true
+}
+
+func sortedBucketNames(names map[string]bool) []libkv.BucketName {
+	keys := make([]string, 0, len(names))
+	for name := range names {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	result := make([]libkv.BucketName, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, libkv.BucketName(key))
+	}
+	return result
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"io"
+
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Repair", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var users libkv.BucketName
+	var orders libkv.BucketName
+	var err error
+
+	setRaw := func(key string, value string) {
+		err := db.DB().Update(func(txn *badger.Txn) error {
+			return txn.Set([]byte(key), []byte(value))
+		})
+		Expect(err).To(BeNil())
+	}
+
+	verify := func() *libbadgerkv.VerifyReport {
+		report, err := db.Verify(ctx)
+		Expect(err).To(BeNil())
+		return report
+	}
+
+	keys := func(name libkv.BucketName) []string {
+		var result []string
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, name)
+			if err != nil {
+				return err
+			}
+			return libkv.ForEach(ctx, bucket, func(item libkv.Item) error {
+				result = append(result, string(item.Key()))
+				return nil
+			})
+		})
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		users = libkv.NewBucketName("users")
+		orders = libkv.NewBucketName("orders")
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucket(ctx, users)
+			Expect(err).To(BeNil())
+			return bucket.Put(ctx, []byte("alice"), []byte("admin"))
+		})
+		Expect(err).To(BeNil())
+
+		// keys of a bucket whose registry entry is gone
+		setRaw("orders_1", "a")
+		setRaw("orders_2", "b")
+		setRaw("__stats_orders", "0000000000000000")
+		Expect(verify().OK()).To(BeFalse())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("changes nothing on dry run", func() {
+		report, err := db.Repair(ctx, libbadgerkv.RepairOptions{DryRun: true, DropOrphans: true})
+		Expect(err).To(BeNil())
+		Expect(report.DroppedKeys).To(Equal(int64(2)))
+		Expect(report.DroppedCounters).To(Equal(int64(1)))
+		Expect(report.Verify.OrphanedKeys).To(Equal(int64(2)))
+		Expect(verify().OrphanedKeys).To(Equal(int64(2)))
+	})
+
+	It("drops orphans", func() {
+		report, err := db.Repair(ctx, libbadgerkv.RepairOptions{DropOrphans: true})
+		Expect(err).To(BeNil())
+		Expect(report.DroppedKeys).To(Equal(int64(2)))
+		Expect(verify().OK()).To(BeTrue())
+		Expect(keys(users)).To(Equal([]string{"alice"}))
+	})
+
+	It("reregisters buckets of orphans", func() {
+		setRaw("__bucket_users", "{")
+
+		report, err := db.Repair(ctx, libbadgerkv.RepairOptions{Reregister: true})
+		Expect(err).To(BeNil())
+		Expect(report.Reregistered).To(Equal([]libkv.BucketName{orders}))
+		Expect(report.RewrittenEntries).To(Equal([]libkv.BucketName{users}))
+		Expect(verify().OK()).To(BeTrue())
+		Expect(keys(orders)).To(Equal([]string{"1", "2"}))
+		Expect(keys(users)).To(Equal([]string{"alice"}))
+	})
+
+	It("keeps counters of unregistered buckets without drop orphans", func() {
+		report, err := db.Repair(ctx, libbadgerkv.RepairOptions{})
+		Expect(err).To(BeNil())
+		Expect(report.DroppedCounters).To(Equal(int64(0)))
+		Expect(verify().OrphanedCounters).To(HaveLen(1))
+	})
+
+	It("skips orphaned keys with an ambiguous bucket", func() {
+		setRaw("order_items_1", "c")
+
+		report, err := db.Repair(ctx, libbadgerkv.RepairOptions{Reregister: true})
+		Expect(err).To(BeNil())
+		Expect(report.Reregistered).To(Equal([]libkv.BucketName{orders}))
+		Expect(report.AmbiguousKeys).To(Equal(int64(1)))
+		Expect(verify().OrphanedKeys).To(Equal(int64(1)))
+	})
+
+	It("reregisters orphaned keys under the given buckets", func() {
+		orderItems := libkv.NewBucketName("order_items")
+		setRaw("order_items_1", "c")
+		setRaw("order_items_2_a", "d")
+
+		report, err := db.Repair(ctx, libbadgerkv.RepairOptions{
+			Reregister: true,
+			Buckets:    []libkv.BucketName{libkv.NewBucketName("order"), orderItems},
+		})
+		Expect(err).To(BeNil())
+		Expect(report.Reregistered).To(Equal([]libkv.BucketName{orderItems, orders}))
+		Expect(report.AmbiguousKeys).To(Equal(int64(0)))
+		Expect(verify().OK()).To(BeTrue())
+		Expect(keys(orderItems)).To(Equal([]string{"1", "2_a"}))
+	})
+
+	It("drops orphaned index entries and chunks", func() {
+		setTuple := func(internalName string, tuple libbadgerkv.Tuple) {
+			packed, err := tuple.Pack(ctx)
+			Expect(err).To(BeNil())
+			setRaw(internalName+"_"+string(packed), "value")
+		}
+		setTuple("__index", libbadgerkv.Tuple{orders.Bytes(), "role", []byte("x"), []byte("1")})
+		setTuple("__chunk", libbadgerkv.Tuple{orders.Bytes(), []byte("id"), 0})
+		setTuple("__chunk", libbadgerkv.Tuple{users.Bytes(), []byte("unknown"), 0})
+
+		report, err := db.Repair(ctx, libbadgerkv.RepairOptions{DropOrphans: true})
+		Expect(err).To(BeNil())
+		Expect(report.DroppedIndexEntries).To(Equal(int64(1)))
+		Expect(report.DroppedChunks).To(Equal(int64(2)))
+		Expect(verify().OK()).To(BeTrue())
+	})
+
+	It("keeps the chunks of running stream writes", func() {
+		_, err := db.Repair(ctx, libbadgerkv.RepairOptions{DropOrphans: true})
+		Expect(err).To(BeNil())
+		chunks := 8
+		r, w := io.Pipe()
+		proceed := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			// a full batch of chunks is written before the reader blocks
+			_, _ = w.Write(make([]byte, chunks*libbadgerkv.DefaultStreamChunkSize))
+			<-proceed
+			_, _ = w.Write([]byte("rest"))
+			_ = w.Close()
+		}()
+		done := make(chan error, 1)
+		go func() {
+			done <- db.PutStream(ctx, users, []byte("avatar"), r)
+		}()
+		Eventually(func() int64 {
+			return verify().OrphanedChunks
+		}).Should(Equal(int64(chunks)))
+
+		report, err := db.Repair(ctx, libbadgerkv.RepairOptions{DropOrphans: true})
+		Expect(err).To(BeNil())
+		Expect(report.DroppedChunks).To(BeZero())
+		Expect(report.InProgressKeys).To(Equal(int64(chunks)))
+
+		close(proceed)
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(verify().OK()).To(BeTrue())
+	})
+
+	It("rejects reregister together with drop orphans", func() {
+		_, err := db.Repair(ctx, libbadgerkv.RepairOptions{Reregister: true, DropOrphans: true})
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,315 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// schemaVersionMarker starts values written with a schema version. Values without it
+// were written before versioning and have version 0. A leading 0x00 is invalid in JSON
+// and protobuf and 0xC1 never occurs in UTF-8, so legacy values are not misread.
+var schemaVersionMarker = []byte{0x00, 0xC1, 'S', 'V'}
+
+// UpgradeFunc upgrades a value by one schema version. It must not modify value.
+type UpgradeFunc func(ctx context.Context, value []byte) ([]byte, error)
+
+// Schema is the current version of the values of a bucket and the upgrades of older
+// values. Upgrades[n] upgrades a value of version n to version n+1.
+//
+// Version is the newest version readers understand, WriteVersion the version values are
+// written with, Version if zero. Roll out a new version in two deployments: first
+// raise Version and keep WriteVersion, so all binaries read the new version before
+// any writes it, then raise WriteVersion. Downgrades[n] converts a value of version n+1
+// to version n and is needed for every version from WriteVersion to Version.
+type Schema struct {
+	Version      uint32
+	WriteVersion uint32
+	Upgrades     map[uint32]UpgradeFunc
+	Downgrades   map[uint32]UpgradeFunc
+}
+
+// writeVersion returns the version values are written with.
+func (s Schema) writeVersion() uint32 {
+	if s.WriteVersion == 0 {
+		return s.Version
+	}
+	return s.WriteVersion
+}
+
+// Upgrade upgrades value from version to the version of the schema.
+// Returns ErrSchemaVersionUnsupported for values newer than the schema,
+// for example written by a newer binary during a rolling deployment.
+func (s Schema) Upgrade(ctx context.Context, version uint32, value []byte) ([]byte, error) {
+	if version > s.Version {
+		return nil, errors.Wrapf(
+			ctx,
+			ErrSchemaVersionUnsupported,
+			"value version %d newer than schema version %d",
+			version,
+			s.Version,
+		)
+	}
+	return s.upgrade(ctx, version, s.Version, value)
+}
+
+// upgrade upgrades value from version to target.
+func (s Schema) upgrade(ctx context.Context, version, target uint32, value []byte) ([]byte, error) {
+	for v := version; v < target; v++ {
+		upgrade, ok := s.Upgrades[v]
+		if !ok {
+			return nil, errors.Wrapf(ctx, ErrSchemaVersionUnsupported, "upgrade of %d missing", v)
+		}
+		var err error
+		if value, err = upgrade(ctx, value); err != nil {
+			return nil, errors.Wrapf(ctx, err, "upgrade version %d failed", v)
+		}
+	}
+	return value, nil
+}
+
+// downgrade converts value of the schema version to the write version.
+func (s Schema) downgrade(ctx context.Context, value []byte) ([]byte, error) {
+	target := s.writeVersion()
+	if target > s.Version {
+		return nil, errors.Wrapf(
+			ctx,
+			ErrSchemaVersionUnsupported,
+			"write version %d newer than schema version %d",
+			target,
+			s.Version,
+		)
+	}
+	for v := s.Version; v > target; v-- {
+		downgrade, ok := s.Downgrades[v-1]
+		if !ok {
+			return nil, errors.Wrapf(
+				ctx,
+				ErrSchemaVersionUnsupported,
+				"downgrade to %d missing",
+				v-1,
+			)
+		}
+		var err error
+		if value, err = downgrade(ctx, value); err != nil {
+			return nil, errors.Wrapf(ctx, err, "downgrade to version %d failed", v-1)
+		}
+	}
+	return value, nil
+}
+
+// AppendSchemaVersion returns value prefixed with the schema version.
+func AppendSchemaVersion(version uint32, value []byte) []byte {
+	result := make([]byte, 0, len(value)+binary.MaxVarintLen32+len(schemaVersionMarker))
+	result = append(result, schemaVersionMarker...)
+	result = binary.AppendUvarint(result, uint64(version))
+	return append(result, value...)
+}
+
+// SplitSchemaVersion returns the schema version of value and the value without it.
+// Values written without a version have version 0.
+func SplitSchemaVersion(ctx context.Context, value []byte) (uint32, []byte, error) {
+	if !bytes.HasPrefix(value, schemaVersionMarker) {
+		return 0, value, nil
+	}
+	value = value[len(schemaVersionMarker):]
+	version, n := binary.Uvarint(value)
+	if n <= 0 || version > 1<<32-1 {
+		return 0, nil, errors.Wrapf(ctx, ErrInvalidEncoding, "invalid schema version")
+	}
+	return uint32(version), value[n:], nil // #nosec G115 -- checked above
+}
+
+// NewVersionedCodec returns a value codec writing values with the write version of the
+// schema and upgrading older values on decode, so readers always get the current version.
+// Stored values are not changed on read, use DB.UpgradeBucket to rewrite them.
+func NewVersionedCodec[T any](schema Schema, codec Codec[T]) Codec[T] {
+	return versionedCodec[T]{
+		schema: schema,
+		codec:  codec,
+	}
+}
+
+type versionedCodec[T any] struct {
+	schema Schema
+	codec  Codec[T]
+}
+
+func (v versionedCodec[T]) Encode(ctx context.Context, value T) ([]byte, error) {
+	result, err := v.codec.Encode(ctx, value)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "encode failed")
+	}
+	if result, err = v.schema.downgrade(ctx, result); err != nil {
+		return nil, errors.Wrapf(ctx, err, "downgrade failed")
+	}
+	return AppendSchemaVersion(v.schema.writeVersion(), result), nil
+}
+
+func (v versionedCodec[T]) Decode(ctx context.Context, data []byte) (T, error) {
+	var result T
+	version, data, err := SplitSchemaVersion(ctx, data)
+	if err != nil {
+		return result, errors.Wrapf(ctx, err, "read version failed")
+	}
+	if data, err = v.schema.Upgrade(ctx, version, data); err != nil {
+		return result, errors.Wrapf(ctx, err, "upgrade from version %d failed", version)
+	}
+	if result, err = v.codec.Decode(ctx, data); err != nil {
+		return result, errors.Wrapf(ctx, err, "decode failed")
+	}
+	return result, nil
+}
+
+// SchemaReport is the number of values per schema version of a bucket.
+type SchemaReport struct {
+	Name libkv.BucketName `json:"name"`
+	// SchemaVersion is the version of the bucket metadata, set by UpgradeBucket.
+	SchemaVersion uint32            `json:"schema_version"`
+	Versions      map[uint32]uint64 `json:"versions"`
+}
+
+// SchemaReport counts the values of the bucket per schema version.
+func (b *badgerdb) SchemaReport(ctx context.Context, name libkv.BucketName) (*SchemaReport, error) {
+	result := &SchemaReport{
+		Name:     name,
+		Versions: make(map[uint32]uint64),
+	}
+	err := b.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+		badgerTx, ok := tx.(Tx)
+		if !ok {
+			return errors.Errorf(ctx, "unexpected tx type %T", tx)
+		}
+		info, err := badgerTx.BucketInfo(ctx, name)
+		if err != nil {
+			return err
+		}
+		result.SchemaVersion = info.SchemaVersion
+		return forEachBucketKey(ctx, badgerTx.Tx(), name, func(item *badger.Item) error {
+			return b.values.ItemValue(ctx, name, item, func(val []byte) error {
+				version, _, err := SplitSchemaVersion(ctx, val)
+				if err != nil {
+					return errors.Wrapf(ctx, err, "read version of key %s failed", item.Key())
+				}
+				result.Versions[version]++
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "schema report of bucket %s failed", name)
+	}
+	return result, nil
+}
+
+// UpgradeBucket rewrites all values of the bucket older than the write version of the
+// schema with the upgraded value in batches of DefaultBatchSize and sets the schema version of the
+// bucket metadata afterwards. Newer values are kept. Run it in a goroutine to upgrade
+// in the background, writers keep going and conflicting batches are retried.
+func (b *badgerdb) UpgradeBucket(
+	ctx context.Context,
+	name libkv.BucketName,
+	schema Schema,
+) (*SchemaReport, error) {
+	snapshot, err := b.Snapshot(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "create snapshot failed")
+	}
+	defer snapshot.Release()
+
+	if _, err := snapshot.BucketInfo(ctx, name); err != nil {
+		return nil, errors.Wrapf(ctx, err, "get bucket %s failed", name)
+	}
+	var batch [][]byte
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := b.retryOnConflict(ctx, func(ctx context.Context, txn *badger.Txn) error {
+			return b.upgradeKeys(ctx, txn, name, schema, batch)
+		})
+		batch = nil
+		return err
+	}
+	err = forEachBucketKey(ctx, snapshot.Tx(), name, func(item *badger.Item) error {
+		batch = append(batch, BucketRemoveKey(name, item.KeyCopy(nil)))
+		if len(batch) >= DefaultBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "upgrade bucket %s failed", name)
+	}
+	if err := flush(); err != nil {
+		return nil, errors.Wrapf(ctx, err, "upgrade bucket %s failed", name)
+	}
+	err = b.updateTxn(ctx, func(ctx context.Context, txn *badger.Txn) error {
+		t := newTx(txn)
+		info, err := t.BucketInfo(ctx, name)
+		if err != nil {
+			return err
+		}
+		if info.SchemaVersion >= schema.writeVersion() {
+			return nil
+		}
+		info.SchemaVersion = schema.writeVersion()
+		return t.putBucketInfo(ctx, *info)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "set schema version of bucket %s failed", name)
+	}
+	glog.V(2).Infof(
+		"upgrade bucket %s to schema version %d completed",
+		name,
+		schema.writeVersion(),
+	)
+	return b.SchemaReport(ctx, name)
+}
+
+// upgradeKeys rewrites the current values of keys older than the write version.
+func (b *badgerdb) upgradeKeys(
+	ctx context.Context,
+	txn *badger.Txn,
+	name libkv.BucketName,
+	schema Schema,
+	keys [][]byte,
+) error {
+	handle, err := b.newTx(txn).bucket(ctx, name)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "get bucket failed")
+	}
+	for _, key := range keys {
+		value, exists, err := handle.value(ctx, key)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "get %s failed", key)
+		}
+		if !exists {
+			continue
+		}
+		version, value, err := SplitSchemaVersion(ctx, value)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "read version of %s failed", key)
+		}
+		target := schema.writeVersion()
+		if version >= target {
+			continue
+		}
+		if value, err = schema.upgrade(ctx, version, target, value); err != nil {
+			return errors.Wrapf(ctx, err, "upgrade %s failed", key)
+		}
+		if err := handle.Put(ctx, key, AppendSchemaVersion(target, value)); err != nil {
+			return errors.Wrapf(ctx, err, "put %s failed", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Schema", func() {
+	type userV2 struct {
+		FullName string `json:"full_name"`
+	}
+
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var users libkv.BucketName
+	var schemaV1 libbadgerkv.Schema
+	var schemaV2 libbadgerkv.Schema
+	var schemaV2WriteV1 libbadgerkv.Schema
+	var err error
+
+	renameField := func(ctx context.Context, value []byte) ([]byte, error) {
+		return bytes.Replace(value, []byte(`"name"`), []byte(`"full_name"`), 1), nil
+	}
+
+	putRaw := func(key string, value []byte) {
+		err := db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, users)
+			if err != nil {
+				return err
+			}
+			return bucket.Put(ctx, []byte(key), value)
+		})
+		Expect(err).To(BeNil())
+	}
+
+	getRaw := func(key string) []byte {
+		var result []byte
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, users)
+			if err != nil {
+				return err
+			}
+			item, err := bucket.Get(ctx, []byte(key))
+			if err != nil {
+				return err
+			}
+			return item.Value(func(val []byte) error {
+				result = bytes.Clone(val)
+				return nil
+			})
+		})
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	put := func(schema libbadgerkv.Schema, key string, value userV2) error {
+		return db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, users)
+			if err != nil {
+				return err
+			}
+			typed := libbadgerkv.NewTypedBucket(
+				bucket.(libbadgerkv.Bucket),
+				libbadgerkv.NewStringCodec(),
+				libbadgerkv.NewVersionedCodec(schema, libbadgerkv.NewJSONCodec[userV2]()),
+			)
+			return typed.Put(ctx, key, value)
+		})
+	}
+
+	get := func(schema libbadgerkv.Schema, key string) (*userV2, error) {
+		var result *userV2
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, users)
+			if err != nil {
+				return err
+			}
+			badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			typed := libbadgerkv.NewTypedBucket(
+				badgerBucket,
+				libbadgerkv.NewStringCodec(),
+				libbadgerkv.NewVersionedCodec(schema, libbadgerkv.NewJSONCodec[userV2]()),
+			)
+			result, err = typed.Get(ctx, key)
+			return err
+		})
+		return result, err
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		users = libkv.NewBucketName("users")
+		schemaV1 = libbadgerkv.Schema{
+			Version: 1,
+			Upgrades: map[uint32]libbadgerkv.UpgradeFunc{
+				0: func(ctx context.Context, value []byte) ([]byte, error) { return value, nil },
+			},
+		}
+		schemaV2 = libbadgerkv.Schema{
+			Version: 2,
+			Upgrades: map[uint32]libbadgerkv.UpgradeFunc{
+				0: schemaV1.Upgrades[0],
+				1: renameField,
+			},
+		}
+
+		schemaV2WriteV1 = libbadgerkv.Schema{
+			Version:      2,
+			WriteVersion: 1,
+			Upgrades:     schemaV2.Upgrades,
+			Downgrades: map[uint32]libbadgerkv.UpgradeFunc{
+				1: func(ctx context.Context, value []byte) ([]byte, error) {
+					return bytes.Replace(value, []byte(`"full_name"`), []byte(`"name"`), 1), nil
+				},
+			},
+		}
+
+		putRaw("legacy", []byte(`{"name":"alice"}`))
+		putRaw("v1", libbadgerkv.AppendSchemaVersion(1, []byte(`{"name":"bob"}`)))
+		putRaw("v2", libbadgerkv.AppendSchemaVersion(2, []byte(`{"full_name":"carol"}`)))
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("splits version", func() {
+		version, value, err := libbadgerkv.SplitSchemaVersion(
+			ctx,
+			libbadgerkv.AppendSchemaVersion(300, []byte("x")),
+		)
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal(uint32(300)))
+		Expect(string(value)).To(Equal("x"))
+	})
+
+	It("reads legacy values starting with 0x00 as version 0", func() {
+		value := []byte{0x00, 0x00, 0x00, 0x01}
+		version, rest, err := libbadgerkv.SplitSchemaVersion(ctx, value)
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal(uint32(0)))
+		Expect(rest).To(Equal(value))
+	})
+
+	It("upgrades values on read", func() {
+		for key, name := range map[string]string{"legacy": "alice", "v1": "bob", "v2": "carol"} {
+			user, err := get(schemaV2, key)
+			Expect(err).To(BeNil())
+			Expect(user.FullName).To(Equal(name))
+		}
+	})
+
+	It("fails for values newer than the schema", func() {
+		_, err = get(schemaV1, "v2")
+		Expect(errors.Is(err, libbadgerkv.ErrSchemaVersionUnsupported)).To(BeTrue())
+	})
+
+	It("fails for missing upgrade", func() {
+		_, err = get(libbadgerkv.Schema{Version: 2}, "v1")
+		Expect(errors.Is(err, libbadgerkv.ErrSchemaVersionUnsupported)).To(BeTrue())
+	})
+
+	It("writes the write version readable by older binaries", func() {
+		Expect(put(schemaV2WriteV1, "dave", userV2{FullName: "dave"})).To(Succeed())
+
+		version, value, err := libbadgerkv.SplitSchemaVersion(ctx, getRaw("dave"))
+		Expect(err).To(BeNil())
+		Expect(version).To(Equal(uint32(1)))
+		Expect(string(value)).To(ContainSubstring(`"name"`))
+
+		user, err := get(schemaV1, "dave")
+		Expect(err).To(BeNil())
+		Expect(user).NotTo(BeNil())
+		user, err = get(schemaV2WriteV1, "dave")
+		Expect(err).To(BeNil())
+		Expect(user.FullName).To(Equal("dave"))
+	})
+
+	It("reads values newer than the write version", func() {
+		user, err := get(schemaV2WriteV1, "v2")
+		Expect(err).To(BeNil())
+		Expect(user.FullName).To(Equal("carol"))
+	})
+
+	It("fails writes for missing downgrade", func() {
+		schema := libbadgerkv.Schema{Version: 2, WriteVersion: 1}
+		err = put(schema, "dave", userV2{FullName: "dave"})
+		Expect(errors.Is(err, libbadgerkv.ErrSchemaVersionUnsupported)).To(BeTrue())
+	})
+
+	It("rewrites old values to the write version", func() {
+		report, err := db.UpgradeBucket(ctx, users, schemaV2WriteV1)
+		Expect(err).To(BeNil())
+		Expect(report.SchemaVersion).To(Equal(uint32(1)))
+		Expect(report.Versions).To(Equal(map[uint32]uint64{1: 2, 2: 1}))
+	})
+
+	It("reports values per version", func() {
+		report, err := db.SchemaReport(ctx, users)
+		Expect(err).To(BeNil())
+		Expect(report.SchemaVersion).To(Equal(uint32(0)))
+		Expect(report.Versions).To(Equal(map[uint32]uint64{0: 1, 1: 1, 2: 1}))
+	})
+
+	It("rewrites old values", func() {
+		report, err := db.UpgradeBucket(ctx, users, schemaV2)
+		Expect(err).To(BeNil())
+		Expect(report.SchemaVersion).To(Equal(uint32(2)))
+		Expect(report.Versions).To(Equal(map[uint32]uint64{2: 3}))
+
+		user, err := get(libbadgerkv.Schema{Version: 2}, "legacy")
+		Expect(err).To(BeNil())
+		Expect(user.FullName).To(Equal("alice"))
+	})
+
+	It("fails upgrade of missing bucket", func() {
+		_, err = db.UpgradeBucket(ctx, libkv.NewBucketName("missing"), schemaV2)
+		Expect(errors.Is(err, libkv.BucketNotFoundError)).To(BeTrue())
+	})
+})
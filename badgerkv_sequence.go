@@ -0,0 +1,38 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+)
+
+var sequenceBucketName = libkv.NewBucketName("__sequence")
+
+// Sequence hands out monotonically increasing integers. It is implemented by
+// badger.Sequence, which leases bandwidth numbers per write transaction.
+type Sequence interface {
+	// Next returns the next integer of the sequence.
+	Next() (uint64, error)
+	// Release returns unused leased integers. Call it before closing the DB.
+	Release() error
+}
+
+// Sequence returns the sequence with the given name. Numbers start at zero and
+// bandwidth numbers are leased per write, so allocations do not need an Update
+// each. Numbers leased but not used are lost on crash. Sequences are not
+// supported in managed mode.
+func (b *badgerdb) Sequence(ctx context.Context, name []byte, bandwidth uint64) (Sequence, error) {
+	if b.versions != nil {
+		return nil, errors.Wrapf(ctx, ErrManagedModeNotSupported, "sequence %s failed", name)
+	}
+	sequence, err := b.db.GetSequence(BucketAddKey(sequenceBucketName, name), bandwidth)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "get sequence %s failed", name)
+	}
+	return sequence, nil
+}
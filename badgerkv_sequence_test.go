@@ -0,0 +1,89 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+
+	"github.com/bborbe/errors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Sequence", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var err error
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	Context("not managed", func() {
+		BeforeEach(func() {
+			db, err = libbadgerkv.OpenMemory(ctx)
+			Expect(err).To(BeNil())
+		})
+
+		It("returns increasing numbers", func() {
+			sequence, err := db.Sequence(ctx, []byte("order-id"), 10)
+			Expect(err).To(BeNil())
+			defer func() { _ = sequence.Release() }()
+
+			var last uint64
+			for i := 0; i < 25; i++ {
+				next, err := sequence.Next()
+				Expect(err).To(BeNil())
+				if i > 0 {
+					Expect(next).To(BeNumerically(">", last))
+				}
+				last = next
+			}
+		})
+
+		It("continues after release", func() {
+			sequence, err := db.Sequence(ctx, []byte("order-id"), 10)
+			Expect(err).To(BeNil())
+			first, err := sequence.Next()
+			Expect(err).To(BeNil())
+			Expect(sequence.Release()).To(Succeed())
+
+			sequence, err = db.Sequence(ctx, []byte("order-id"), 10)
+			Expect(err).To(BeNil())
+			defer func() { _ = sequence.Release() }()
+			second, err := sequence.Next()
+			Expect(err).To(BeNil())
+			Expect(second).To(BeNumerically(">", first))
+		})
+
+		It("does not show up as bucket", func() {
+			sequence, err := db.Sequence(ctx, []byte("order-id"), 10)
+			Expect(err).To(BeNil())
+			Expect(sequence.Release()).To(Succeed())
+
+			stats, err := db.Stats(ctx)
+			Expect(err).To(BeNil())
+			Expect(stats.Buckets).To(BeEmpty())
+		})
+	})
+
+	Context("managed", func() {
+		BeforeEach(func() {
+			db, err = libbadgerkv.OpenMemoryManaged(ctx)
+			Expect(err).To(BeNil())
+		})
+
+		It("is not supported", func() {
+			_, err := db.Sequence(ctx, []byte("order-id"), 10)
+			Expect(errors.Is(err, libbadgerkv.ErrManagedModeNotSupported)).To(BeTrue())
+		})
+	})
+})
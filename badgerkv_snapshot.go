@@ -0,0 +1,380 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// DefaultSnapshotMaxAge is the max age of snapshots created with DB.Snapshot.
+const DefaultSnapshotMaxAge = 30 * time.Minute
+
+// Snapshot is a read only transaction that stays valid until Release is called,
+// independent of a callback. All reads of the snapshot and its bucket handles fail with
+// ErrSnapshotExpired once the max age is exceeded. Each snapshot pins old versions in
+// Badger, so it must be released as soon as possible. Expired snapshots without active
+// reads are released on the next DB.Snapshot or DB.SnapshotStats, unless Tx was called.
+type Snapshot interface {
+	Tx
+	// Version returns the version the snapshot reads at.
+	Version() uint64
+	// CreatedAt returns the creation time of the snapshot.
+	CreatedAt() time.Time
+	// Release discards the snapshot. Calling it more than once is a no-op.
+	Release()
+}
+
+// SnapshotStats reports snapshots not released yet. Expired counts snapshots
+// older than their max age, which usually means a missing Release. They are released
+// after reporting if no read is active.
+type SnapshotStats struct {
+	Open      int           `json:"open"`
+	Expired   int           `json:"expired"`
+	OldestAge time.Duration `json:"oldest_age"`
+}
+
+// Snapshot returns a snapshot with DefaultSnapshotMaxAge.
+func (b *badgerdb) Snapshot(ctx context.Context) (Snapshot, error) {
+	return b.SnapshotWithMaxAge(ctx, DefaultSnapshotMaxAge)
+}
+
+// SnapshotWithMaxAge returns a snapshot of the current database state.
+func (b *badgerdb) SnapshotWithMaxAge(ctx context.Context, maxAge time.Duration) (Snapshot, error) {
+	if maxAge <= 0 {
+		return nil, errors.Errorf(ctx, "max age must be positive, got %v", maxAge)
+	}
+	var txn *badger.Txn
+	var version uint64
+	release := func() {}
+	if b.versions != nil {
+		release, version = b.versions.AcquireCurrent()
+		txn = b.db.NewTransactionAt(version, false)
+	} else {
+		version = b.db.MaxVersion()
+		txn = b.db.NewTransaction(false)
+	}
+	t := b.newTx(txn)
+	s := &snapshot{
+		tx:        t,
+		version:   version,
+		createdAt: time.Now(),
+		maxAge:    maxAge,
+	}
+	t.guard = s.acquire
+	s.release = func() {
+		txn.Discard()
+		release()
+		b.snapshots.Remove(s)
+	}
+	b.snapshots.ReleaseExpired()
+	b.snapshots.Add(s)
+	glog.V(4).Infof("snapshot at version %d created", version)
+	return s, nil
+}
+
+// SnapshotStats returns statistics about snapshots not released yet and releases the
+// expired ones without active reads afterwards.
+func (b *badgerdb) SnapshotStats() SnapshotStats {
+	stats := b.snapshots.Stats()
+	b.snapshots.ReleaseExpired()
+	return stats
+}
+
+type snapshot struct {
+	tx        Tx
+	version   uint64
+	createdAt time.Time
+	maxAge    time.Duration
+
+	once    sync.Once
+	release func()
+
+	mux sync.Mutex
+	// active is the number of reads in progress, which keep an expired snapshot.
+	active int
+	// pinned is set by Tx, whose reads can not be tracked.
+	pinned bool
+	// released is set once the tracker releases the expired snapshot.
+	released bool
+}
+
+func (s *snapshot) Version() uint64 {
+	return s.version
+}
+
+func (s *snapshot) CreatedAt() time.Time {
+	return s.createdAt
+}
+
+func (s *snapshot) Release() {
+	s.once.Do(s.release)
+}
+
+func (s *snapshot) expired() bool {
+	return time.Since(s.createdAt) > s.maxAge
+}
+
+func (s *snapshot) checkExpired(ctx context.Context) error {
+	if s.released || s.expired() {
+		return errors.Wrapf(
+			ctx,
+			ErrSnapshotExpired,
+			"snapshot at version %d older than %v",
+			s.version,
+			s.maxAge,
+		)
+	}
+	return nil
+}
+
+// acquire marks a read as active, so the snapshot is not released before the returned
+// func is called. Returns ErrSnapshotExpired once the max age is exceeded.
+func (s *snapshot) acquire(ctx context.Context) (func(), error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.checkExpired(ctx); err != nil {
+		return nil, err
+	}
+	s.active++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+			s.active--
+		})
+	}, nil
+}
+
+// releaseIfExpired releases the snapshot if it is expired and has no active reads.
+// Expiry is final, so no read can start afterwards.
+func (s *snapshot) releaseIfExpired() bool {
+	s.mux.Lock()
+	if s.released || s.pinned || s.active > 0 || !s.expired() {
+		s.mux.Unlock()
+		return false
+	}
+	s.released = true
+	s.mux.Unlock()
+	s.Release()
+	return true
+}
+
+// Tx returns the Badger transaction. Its reads are not checked against the max age,
+// so the snapshot is only released with Release afterwards.
+func (s *snapshot) Tx() *badger.Txn {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.pinned = true
+	return s.tx.Tx()
+}
+
+func (s *snapshot) Bucket(ctx context.Context, name libkv.BucketName) (libkv.Bucket, error) {
+	return s.tx.Bucket(ctx, name)
+}
+
+func (s *snapshot) ListBucketNames(ctx context.Context) (libkv.BucketNames, error) {
+	return s.tx.ListBucketNames(ctx)
+}
+
+func (s *snapshot) listBucketNamesWithPrefix(
+	ctx context.Context,
+	prefix []byte,
+) ([]libkv.BucketName, error) {
+	lister, ok := s.tx.(bucketPrefixLister)
+	if !ok {
+		return nil, errors.Errorf(ctx, "unexpected tx type %T", s.tx)
+	}
+	return lister.listBucketNamesWithPrefix(ctx, prefix)
+}
+
+func (s *snapshot) CreateBucket(ctx context.Context, name libkv.BucketName) (libkv.Bucket, error) {
+	return nil, errors.Wrapf(ctx, badger.ErrReadOnlyTxn, "create bucket %s failed", name)
+}
+
+func (s *snapshot) CreateBucketIfNotExists(
+	ctx context.Context,
+	name libkv.BucketName,
+) (libkv.Bucket, error) {
+	return s.tx.Bucket(ctx, name)
+}
+
+func (s *snapshot) DeleteBucket(ctx context.Context, name libkv.BucketName) error {
+	return errors.Wrapf(ctx, badger.ErrReadOnlyTxn, "delete bucket %s failed", name)
+}
+
+func (s *snapshot) CopyBucket(
+	ctx context.Context,
+	src libkv.BucketName,
+	dst libkv.BucketName,
+) error {
+	return errors.Wrapf(ctx, badger.ErrReadOnlyTxn, "copy bucket %s failed", src)
+}
+
+func (s *snapshot) RenameBucket(
+	ctx context.Context,
+	oldName libkv.BucketName,
+	newName libkv.BucketName,
+) error {
+	return errors.Wrapf(ctx, badger.ErrReadOnlyTxn, "rename bucket %s failed", oldName)
+}
+
+func (s *snapshot) ClearBucket(ctx context.Context, name libkv.BucketName) error {
+	return errors.Wrapf(ctx, badger.ErrReadOnlyTxn, "clear bucket %s failed", name)
+}
+
+func (s *snapshot) BucketInfo(ctx context.Context, name libkv.BucketName) (*BucketInfo, error) {
+	return s.tx.BucketInfo(ctx, name)
+}
+
+func (s *snapshot) SetBucketMeta(
+	ctx context.Context,
+	name libkv.BucketName,
+	meta BucketMeta,
+) error {
+	return errors.Wrapf(ctx, badger.ErrReadOnlyTxn, "set meta of bucket %s failed", name)
+}
+
+func newSnapshotTracker() *snapshotTracker {
+	return &snapshotTracker{
+		snapshots: make(map[*snapshot]struct{}),
+	}
+}
+
+type snapshotTracker struct {
+	mux       sync.Mutex
+	snapshots map[*snapshot]struct{}
+}
+
+func (t *snapshotTracker) Add(s *snapshot) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.snapshots[s] = struct{}{}
+}
+
+func (t *snapshotTracker) Remove(s *snapshot) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	delete(t.snapshots, s)
+}
+
+func (t *snapshotTracker) Stats() SnapshotStats {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	var result SnapshotStats
+	for s := range t.snapshots {
+		result.Open++
+		if s.expired() {
+			result.Expired++
+		}
+		if age := time.Since(s.createdAt); age > result.OldestAge {
+			result.OldestAge = age
+		}
+	}
+	return result
+}
+
+// ReleaseExpired releases the expired snapshots without active reads.
+func (t *snapshotTracker) ReleaseExpired() {
+	t.mux.Lock()
+	snapshots := make([]*snapshot, 0, len(t.snapshots))
+	for s := range t.snapshots {
+		snapshots = append(snapshots, s)
+	}
+	t.mux.Unlock()
+	for _, s := range snapshots {
+		if s.releaseIfExpired() {
+			glog.Warningf("release expired snapshot at version %d", s.version)
+		}
+	}
+}
+
+// ReleaseAll releases all open snapshots, used on close.
+func (t *snapshotTracker) ReleaseAll() {
+	t.mux.Lock()
+	snapshots := make([]*snapshot, 0, len(t.snapshots))
+	for s := range t.snapshots {
+		snapshots = append(snapshots, s)
+	}
+	t.mux.Unlock()
+	for _, s := range snapshots {
+		glog.Warningf("release leaked snapshot at version %d on close", s.version)
+		s.Release()
+	}
+}
+
+// guardedIterator keeps the snapshot of a bucket until it is closed.
+type guardedIterator struct {
+	Iterator
+	done func()
+}
+
+func (g guardedIterator) Close() {
+	g.Iterator.Close()
+	g.done()
+}
+
+// expiredIterator is returned for buckets of expired snapshots. It yields a single
+// expiredItem after Rewind or Seek, so the error reaches callers reading values.
+type expiredIterator struct {
+	bucketName libkv.BucketName
+	err        error
+	consumed   bool
+}
+
+func (e *expiredIterator) BucketName() libkv.BucketName {
+	return e.bucketName
+}
+
+func (e *expiredIterator) Iterator() *badger.Iterator {
+	return nil
+}
+
+func (e *expiredIterator) Close() {
+}
+
+func (e *expiredIterator) Item() libkv.Item {
+	return expiredItem{err: e.err}
+}
+
+func (e *expiredIterator) Next() {
+	e.consumed = true
+}
+
+func (e *expiredIterator) Valid() bool {
+	return !e.consumed
+}
+
+func (e *expiredIterator) Rewind() {
+	e.consumed = false
+}
+
+func (e *expiredIterator) Seek(key []byte) {
+	e.consumed = false
+}
+
+// expiredItem is the item of an expiredIterator, its Value returns the expiry error.
+type expiredItem struct {
+	err error
+}
+
+func (e expiredItem) Exists() bool {
+	return false
+}
+
+func (e expiredItem) Key() []byte {
+	return nil
+}
+
+func (e expiredItem) Value(fn func(val []byte) error) error {
+	return e.err
+}
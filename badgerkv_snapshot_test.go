@@ -0,0 +1,188 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Snapshot", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var bucketName libkv.BucketName
+	var err error
+
+	put := func(value string) {
+		err := db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, bucketName)
+			Expect(err).To(BeNil())
+			return bucket.Put(ctx, []byte("key"), []byte(value))
+		})
+		Expect(err).To(BeNil())
+	}
+
+	get := func(tx libkv.Tx) string {
+		bucket, err := tx.Bucket(ctx, bucketName)
+		Expect(err).To(BeNil())
+		item, err := bucket.Get(ctx, []byte("key"))
+		Expect(err).To(BeNil())
+		var result string
+		Expect(item.Value(func(val []byte) error {
+			result = string(val)
+			return nil
+		})).To(Succeed())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		bucketName = libkv.NewBucketName("exports")
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	for _, managed := range []bool{false, true} {
+		managed := managed
+		Context("managed "+map[bool]string{false: "off", true: "on"}[managed], func() {
+			BeforeEach(func() {
+				if managed {
+					db, err = libbadgerkv.OpenMemoryManaged(ctx)
+				} else {
+					db, err = libbadgerkv.OpenMemory(ctx)
+				}
+				Expect(err).To(BeNil())
+				put("v1")
+			})
+
+			It("keeps reading the state of creation", func() {
+				snapshot, err := db.Snapshot(ctx)
+				Expect(err).To(BeNil())
+				defer snapshot.Release()
+
+				put("v2")
+				Expect(get(snapshot)).To(Equal("v1"))
+				put("v3")
+				Expect(get(snapshot)).To(Equal("v1"))
+			})
+
+			It("is read only", func() {
+				snapshot, err := db.Snapshot(ctx)
+				Expect(err).To(BeNil())
+				defer snapshot.Release()
+
+				bucket, err := snapshot.Bucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+				Expect(bucket.Put(ctx, []byte("key"), []byte("v2"))).NotTo(Succeed())
+			})
+
+			It("tracks open snapshots", func() {
+				snapshot, err := db.Snapshot(ctx)
+				Expect(err).To(BeNil())
+				Expect(db.SnapshotStats().Open).To(Equal(1))
+
+				snapshot.Release()
+				snapshot.Release()
+				Expect(db.SnapshotStats().Open).To(Equal(0))
+			})
+		})
+	}
+
+	Context("max age", func() {
+		BeforeEach(func() {
+			db, err = libbadgerkv.OpenMemory(ctx)
+			Expect(err).To(BeNil())
+			put("v1")
+		})
+
+		It("fails after max age and reports it as expired", func() {
+			snapshot, err := db.SnapshotWithMaxAge(ctx, 10*time.Millisecond)
+			Expect(err).To(BeNil())
+			defer snapshot.Release()
+
+			time.Sleep(20 * time.Millisecond)
+			_, err = snapshot.Bucket(ctx, bucketName)
+			Expect(errors.Is(err, libbadgerkv.ErrSnapshotExpired)).To(BeTrue())
+			_, err = snapshot.ListBucketNames(ctx)
+			Expect(errors.Is(err, libbadgerkv.ErrSnapshotExpired)).To(BeTrue())
+
+			stats := db.SnapshotStats()
+			Expect(stats.Open).To(Equal(1))
+			Expect(stats.Expired).To(Equal(1))
+			Expect(stats.OldestAge).To(BeNumerically(">=", 20*time.Millisecond))
+		})
+
+		It("fails reads of bucket handles after max age", func() {
+			snapshot, err := db.SnapshotWithMaxAge(ctx, 10*time.Millisecond)
+			Expect(err).To(BeNil())
+			defer snapshot.Release()
+			bucket, err := snapshot.Bucket(ctx, bucketName)
+			Expect(err).To(BeNil())
+
+			time.Sleep(20 * time.Millisecond)
+			_, err = bucket.Get(ctx, []byte("key"))
+			Expect(errors.Is(err, libbadgerkv.ErrSnapshotExpired)).To(BeTrue())
+			err = libkv.ForEach(ctx, bucket, func(item libkv.Item) error {
+				return item.Value(func(val []byte) error {
+					return nil
+				})
+			})
+			Expect(errors.Is(err, libbadgerkv.ErrSnapshotExpired)).To(BeTrue())
+		})
+
+		It("releases expired snapshots", func() {
+			_, err := db.SnapshotWithMaxAge(ctx, 10*time.Millisecond)
+			Expect(err).To(BeNil())
+
+			time.Sleep(20 * time.Millisecond)
+			Expect(db.SnapshotStats().Expired).To(Equal(1))
+			Expect(db.SnapshotStats().Open).To(Equal(0))
+		})
+
+		It("keeps expired snapshots with open iterators", func() {
+			snapshot, err := db.SnapshotWithMaxAge(ctx, 10*time.Millisecond)
+			Expect(err).To(BeNil())
+			defer snapshot.Release()
+			bucket, err := snapshot.Bucket(ctx, bucketName)
+			Expect(err).To(BeNil())
+			it := bucket.Iterator()
+
+			time.Sleep(20 * time.Millisecond)
+			db.SnapshotStats()
+			Expect(db.SnapshotStats().Open).To(Equal(1))
+			it.Rewind()
+			Expect(it.Valid()).To(BeTrue())
+
+			it.Close()
+			db.SnapshotStats()
+			Expect(db.SnapshotStats().Open).To(Equal(0))
+		})
+
+		It("keeps expired snapshots after Tx", func() {
+			snapshot, err := db.SnapshotWithMaxAge(ctx, 10*time.Millisecond)
+			Expect(err).To(BeNil())
+			defer snapshot.Release()
+			Expect(snapshot.Tx()).NotTo(BeNil())
+
+			time.Sleep(20 * time.Millisecond)
+			db.SnapshotStats()
+			Expect(db.SnapshotStats().Open).To(Equal(1))
+		})
+
+		It("rejects non positive max age", func() {
+			_, err := db.SnapshotWithMaxAge(ctx, 0)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
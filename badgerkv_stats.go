@@ -14,26 +14,48 @@ import (
 // Stats returns a fast overview: total LSM + value-log size and bucket inventory
 // (names only). Per-bucket KeyCount is left at zero — call StatsDetailed for
 // counts, but note that Badger has no native per-prefix counter so detailed
-// counting requires scanning every key in each bucket.
+// counting requires scanning every key in each bucket. Buckets with stats enabled
+// via DB.EnableBucketStats report their maintained KeyCount and SizeB without a scan,
+// other buckets report the on-disk estimate of DB.EstimateBucketSize as SizeB. The table
+// list for the estimates is read once per call.
 func (b *badgerdb) Stats(ctx context.Context) (*libkv.Stats, error) {
-	return b.statsImpl(ctx, false)
+	return b.statsImpl(ctx, nil, false)
 }
 
 // StatsDetailed returns Stats plus per-bucket KeyCount. Cost: O(total keys)
 // — Badger scans every key with each bucket's prefix. Do not poll hot.
 func (b *badgerdb) StatsDetailed(ctx context.Context) (*libkv.Stats, error) {
-	return b.statsImpl(ctx, true)
+	return b.statsImpl(ctx, nil, true)
 }
 
-func (b *badgerdb) statsImpl(ctx context.Context, detailed bool) (*libkv.Stats, error) {
-	lsm, vlog := b.db.Size()
+// statsImpl returns the stats of the top level buckets, or of the buckets of the
+// namespace with prefix if set. SizeB of a namespace is the sum of its buckets.
+func (b *badgerdb) statsImpl(
+	ctx context.Context,
+	prefix []byte,
+	detailed bool,
+) (*libkv.Stats, error) {
 	s := &libkv.Stats{
 		Backend:  "badger",
-		SizeB:    lsm + vlog,
 		Detailed: detailed,
 	}
+	if prefix == nil {
+		lsm, vlog := b.db.Size()
+		s.SizeB = lsm + vlog
+	}
+	var sizes *tableSizes
 	err := b.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
-		names, err := tx.ListBucketNames(ctx)
+		badgerTx, ok := tx.(Tx)
+		if !ok {
+			return errors.Errorf(ctx, "unexpected tx type %T", tx)
+		}
+		var names libkv.BucketNames
+		var err error
+		if prefix == nil {
+			names, err = tx.ListBucketNames(ctx)
+		} else {
+			names, err = listNamespaceBuckets(ctx, badgerTx, prefix)
+		}
 		if err != nil {
 			return errors.Wrapf(ctx, err, "list bucket names failed")
 		}
@@ -44,19 +66,26 @@ func (b *badgerdb) statsImpl(ctx context.Context, detailed bool) (*libkv.Stats,
 			default:
 			}
 
-			bs := libkv.BucketStats{Name: name}
-			if detailed {
-				bucket, err := tx.Bucket(ctx, name)
-				if err != nil {
-					return errors.Wrapf(ctx, err, "get bucket %s failed", name)
+			bs, tracked, err := bucketStats(ctx, tx, name, detailed)
+			if err != nil {
+				return errors.Wrapf(ctx, err, "stats of bucket %s failed", name)
+			}
+			if !tracked {
+				if sizes == nil {
+					read := b.readTableSizes()
+					sizes = &read
 				}
-				count, err := libkv.Count(ctx, bucket)
+				size, err := sizes.estimate(ctx, badgerTx.Tx(), name)
 				if err != nil {
-					return errors.Wrapf(ctx, err, "count bucket %s failed", name)
+					return errors.Wrapf(ctx, err, "estimate size of bucket %s failed", name)
 				}
-				bs.KeyCount = count
+				bs.SizeB = size.Total()
 			}
-			s.Buckets = append(s.Buckets, bs)
+			if prefix != nil {
+				bs.Name = name[len(prefix):]
+				s.SizeB += bs.SizeB
+			}
+			s.Buckets = append(s.Buckets, *bs)
 		}
 		return nil
 	})
@@ -65,3 +94,42 @@ func (b *badgerdb) statsImpl(ctx context.Context, detailed bool) (*libkv.Stats,
 	}
 	return s, nil
 }
+
+// bucketStats returns the maintained counters of tracked buckets and scans other buckets
+// if detailed is set. Returns true if the bucket is tracked.
+func bucketStats(
+	ctx context.Context,
+	tx libkv.Tx,
+	name libkv.BucketName,
+	detailed bool,
+) (*libkv.BucketStats, bool, error) {
+	badgerTx, ok := tx.(Tx)
+	if !ok {
+		return nil, false, errors.Errorf(ctx, "unexpected tx type %T", tx)
+	}
+	info, err := badgerTx.BucketInfo(ctx, name)
+	if err != nil {
+		return nil, false, errors.Wrapf(ctx, err, "get info failed")
+	}
+	if info.TrackStats {
+		counters, err := readBucketCounters(ctx, badgerTx.Tx(), name)
+		if err != nil {
+			return nil, false, err
+		}
+		bs := counters.BucketStats(name)
+		return &bs, true, nil
+	}
+	bs := libkv.BucketStats{Name: name}
+	if detailed {
+		bucket, err := tx.Bucket(ctx, name)
+		if err != nil {
+			return nil, false, errors.Wrapf(ctx, err, "get bucket failed")
+		}
+		count, err := libkv.Count(ctx, bucket)
+		if err != nil {
+			return nil, false, errors.Wrapf(ctx, err, "count bucket failed")
+		}
+		bs.KeyCount = count
+	}
+	return &bs, false, nil
+}
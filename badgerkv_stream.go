@@ -0,0 +1,513 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// DefaultStreamChunkSize is the max size of the chunks PutStream splits content into.
+// It is below Badger's default value threshold, which in-memory databases can not exceed.
+const DefaultStreamChunkSize = 512 << 10
+
+// chunkBucketName is the internal bucket holding the chunks of all streams.
+// Chunk keys are the packed Tuple{bucket, stream id, chunk index}.
+var chunkBucketName = libkv.NewBucketName("__chunk")
+
+// streamManifestMeta is set in Badger's UserMeta for values that are the
+// JSON StreamManifest of a stream written with PutStream.
+const streamManifestMeta byte = 0x02
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// StreamManifest describes a stream written with PutStream. It is the value
+// returned by Bucket.Get for the key of a stream.
+type StreamManifest struct {
+	// ID identifies the chunks of the stream, a new one is used for every write.
+	ID []byte `json:"id"`
+	// Size is the number of bytes of the content.
+	Size int64 `json:"size"`
+	// ChunkSize is the size of all chunks but the last.
+	ChunkSize int `json:"chunk_size"`
+	// Checksums are the CRC32C checksums of the chunks.
+	Checksums []uint32 `json:"checksums"`
+	// SHA256 is the checksum of the content.
+	SHA256 []byte `json:"sha256"`
+}
+
+// PutStream stores the content of r at key, split into chunks of
+// DefaultStreamChunkSize. All chunks are written in the transaction, so the size is
+// limited by Badger's transaction size. Use DB.PutStream for larger content. Returns
+// ErrQuotaExceeded as soon as the content exceeds BucketMeta.MaxBytes.
+func (b *bucket) PutStream(ctx context.Context, key []byte, r io.Reader) error {
+	// fail before writing chunks
+	if err := b.checkKeySize(ctx, key); err != nil {
+		return err
+	}
+	quota, err := b.streamQuota(ctx, key)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "read quota of bucket %s failed", b.bucketName)
+	}
+	id, err := newStreamID(ctx)
+	if err != nil {
+		return err
+	}
+	var written int64
+	manifest, err := writeChunks(ctx, b.bucketName, id, r, func(key, chunk []byte) error {
+		written += int64(len(chunk))
+		if err := quota(written); err != nil {
+			return err
+		}
+		return b.badgerTx.Set(key, chunk)
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "write chunks of key %s failed", key)
+	}
+	return b.putManifest(ctx, key, manifest)
+}
+
+// GetStream returns the content of the stream at key. The reader verifies the checksums
+// and must be read before the transaction ends. Values not written with PutStream are
+// returned as they are. Returns an error wrapping libkv.ErrKeyNotFound if key is missing.
+func (b *bucket) GetStream(ctx context.Context, key []byte) (io.ReadCloser, error) {
+	done, err := b.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := b.getStream(ctx, key, done)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// getStream returns the reader of the stream at key, which calls done on Close.
+func (b *bucket) getStream(ctx context.Context, key []byte, done func()) (io.ReadCloser, error) {
+	item, err := b.badgerTx.Get(BucketAddKey(b.bucketName, key))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, errors.Wrapf(ctx, libkv.ErrKeyNotFound, "key %s not found", key)
+		}
+		return nil, errors.Wrapf(ctx, err, "get failed")
+	}
+	if item.UserMeta()&streamManifestMeta == 0 {
+		value, err := b.values().ItemValueCopy(ctx, b.bucketName, item)
+		if err != nil {
+			return nil, errors.Wrapf(ctx, err, "get value of key %s failed", key)
+		}
+		done()
+		return io.NopCloser(bytes.NewReader(value)), nil
+	}
+	manifest, err := parseStreamManifest(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkReader{
+		ctx:      ctx,
+		txn:      b.badgerTx,
+		name:     b.bucketName,
+		key:      key,
+		manifest: *manifest,
+		hash:     sha256.New(),
+		done:     done,
+	}, nil
+}
+
+// PutStream stores the content of r at key of the bucket like Bucket.PutStream, but
+// writes the chunks in batches of DefaultBatchBytes, so the size is not limited by a
+// single transaction. The stream is replaced in a last transaction and is not visible
+// before. Chunks of a failed write are deleted. The quota is checked against the
+// counters read before the first chunk, so concurrent writes may still exceed it until
+// the manifest is written, which fails with ErrQuotaExceeded.
+func (b *badgerdb) PutStream(
+	ctx context.Context,
+	name libkv.BucketName,
+	key []byte,
+	r io.Reader,
+) error {
+	var quota func(written int64) error
+	err := b.viewTxn(ctx, func(ctx context.Context, txn *badger.Txn) error {
+		handle, err := b.newTx(txn).bucket(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err := handle.checkKeySize(ctx, key); err != nil {
+			return err
+		}
+		quota, err = handle.streamQuota(ctx, key)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "put stream %s to bucket %s failed", key, name)
+	}
+	id, err := newStreamID(ctx)
+	if err != nil {
+		return err
+	}
+	stream, err := Tuple{name.Bytes(), id}.Pack(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "pack stream failed")
+	}
+	release, err := b.streams.Reserve(ctx, stream)
+	if err != nil {
+		return err
+	}
+	defer release()
+	var written int64
+	var batch []*badger.Entry
+	var batchBytes int
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := b.updateTxn(ctx, func(ctx context.Context, txn *badger.Txn) error {
+			for _, entry := range batch {
+				if err := txn.SetEntry(entry); err != nil {
+					return errors.Wrapf(ctx, err, "set chunk failed")
+				}
+			}
+			return nil
+		})
+		batch = nil
+		batchBytes = 0
+		return err
+	}
+	manifest, err := writeChunks(ctx, name, id, r, func(key, chunk []byte) error {
+		written += int64(len(chunk))
+		if err := quota(written); err != nil {
+			return err
+		}
+		batch = append(batch, badger.NewEntry(key, chunk))
+		batchBytes += len(key) + len(chunk)
+		if batchBytes >= DefaultBatchBytes {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err == nil {
+		err = b.updateTxn(ctx, func(ctx context.Context, txn *badger.Txn) error {
+			handle, err := b.newTx(txn).bucket(ctx, name)
+			if err != nil {
+				return err
+			}
+			return handle.putManifest(ctx, key, manifest)
+		})
+	}
+	if err != nil {
+		if cleanupErr := b.deleteStreamChunks(ctx, name, id); cleanupErr != nil {
+			glog.Warningf("delete chunks of failed stream %s failed: %v", key, cleanupErr)
+		}
+		return errors.Wrapf(ctx, err, "put stream %s to bucket %s failed", key, name)
+	}
+	glog.V(3).Infof("put stream %s with %d bytes to bucket %s", key, manifest.Size, name)
+	return nil
+}
+
+// deleteStreamChunks deletes the chunks of the stream id in batches.
+func (b *badgerdb) deleteStreamChunks(ctx context.Context, name libkv.BucketName, id []byte) error {
+	prefix, err := chunkPrefix(ctx, Tuple{name.Bytes(), id})
+	if err != nil {
+		return err
+	}
+	snapshot, err := b.Snapshot(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "create snapshot failed")
+	}
+	defer snapshot.Release()
+	return b.deleteTupleKeys(ctx, snapshot, prefix)
+}
+
+// putManifest replaces the value of key with the manifest and deletes the chunks of
+// the stream it replaces. Streams are not indexed.
+func (b *bucket) putManifest(ctx context.Context, key []byte, manifest *StreamManifest) error {
+	if err := b.checkKeySize(ctx, key); err != nil {
+		return err
+	}
+	if err := b.enableStreams(ctx); err != nil {
+		return err
+	}
+	value, err := json.Marshal(manifest)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "marshal manifest failed")
+	}
+	if err := b.deleteStream(ctx, key); err != nil {
+		return errors.Wrapf(ctx, err, "delete stream failed")
+	}
+	if b.trackStats {
+		if err := b.trackPut(ctx, key, manifest.Size); err != nil {
+			return errors.Wrapf(ctx, err, "track put failed")
+		}
+	}
+	if err := b.updateIndexes(ctx, key, nil, true); err != nil {
+		return errors.Wrapf(ctx, err, "update indexes failed")
+	}
+	entry := badger.NewEntry(BucketAddKey(b.bucketName, key), value)
+	return b.badgerTx.SetEntry(entry.WithMeta(streamManifestMeta))
+}
+
+// enableStreams marks the bucket as containing streams, so Put and Delete delete the
+// chunks of the streams they replace.
+func (b *bucket) enableStreams(ctx context.Context) error {
+	if b.streams {
+		return nil
+	}
+	t := b.tx
+	if t == nil {
+		t = newTx(b.badgerTx)
+	}
+	info, err := t.bucketInfo(ctx, b.bucketName)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "get info of bucket %s failed", b.bucketName)
+	}
+	if info == nil {
+		return errors.Wrapf(ctx, libkv.BucketNotFoundError, "bucket %s not found", b.bucketName)
+	}
+	if !info.Streams {
+		info.Streams = true
+		if err := t.putBucketInfo(ctx, *info); err != nil {
+			return errors.Wrapf(ctx, err, "put info of bucket %s failed", b.bucketName)
+		}
+	}
+	b.streams = true
+	return nil
+}
+
+// deleteStream deletes the chunks of the stream at key, if any.
+func (b *bucket) deleteStream(ctx context.Context, key []byte) error {
+	item, err := b.badgerTx.Get(BucketAddKey(b.bucketName, key))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		return errors.Wrapf(ctx, err, "get failed")
+	}
+	if item.UserMeta()&streamManifestMeta == 0 {
+		return nil
+	}
+	manifest, err := parseStreamManifest(ctx, item)
+	if err != nil {
+		return err
+	}
+	for i := range manifest.Checksums {
+		chunkKey, err := chunkPrefix(ctx, Tuple{b.bucketName.Bytes(), manifest.ID, i})
+		if err != nil {
+			return err
+		}
+		if err := b.badgerTx.Delete(chunkKey); err != nil {
+			return errors.Wrapf(ctx, err, "delete chunk %d of key %s failed", i, key)
+		}
+	}
+	return nil
+}
+
+// writeChunks splits the content of r into chunks, calls fn with the key and a copy of
+// each chunk and returns the manifest.
+func writeChunks(
+	ctx context.Context,
+	name libkv.BucketName,
+	id []byte,
+	r io.Reader,
+	fn func(key, chunk []byte) error,
+) (*StreamManifest, error) {
+	manifest := &StreamManifest{
+		ID:        id,
+		ChunkSize: DefaultStreamChunkSize,
+		Checksums: []uint32{},
+	}
+	sum := sha256.New()
+	buf := make([]byte, DefaultStreamChunkSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx, ctx.Err(), "context cancelled")
+		default:
+		}
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			chunk := bytes.Clone(buf[:n])
+			chunkKey, err := chunkPrefix(ctx, Tuple{name.Bytes(), id, len(manifest.Checksums)})
+			if err != nil {
+				return nil, err
+			}
+			if err := fn(chunkKey, chunk); err != nil {
+				return nil, errors.Wrapf(ctx, err, "write chunk failed")
+			}
+			sum.Write(chunk)
+			manifest.Checksums = append(manifest.Checksums, crc32.Checksum(chunk, castagnoli))
+			manifest.Size += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(ctx, err, "read failed")
+		}
+	}
+	manifest.SHA256 = sum.Sum(nil)
+	return manifest, nil
+}
+
+// chunkReader reads the chunks of a stream in order and verifies their checksums.
+type chunkReader struct {
+	ctx      context.Context
+	txn      *badger.Txn
+	name     libkv.BucketName
+	key      []byte
+	manifest StreamManifest
+
+	index int
+	buf   []byte
+	hash  hash.Hash
+	size  int64
+	// done ends the read of a snapshot on Close.
+	done func()
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.index >= len(r.manifest.Checksums) {
+			return 0, r.verify()
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *chunkReader) Close() error {
+	r.done()
+	r.buf = nil
+	r.index = len(r.manifest.Checksums)
+	return nil
+}
+
+// next reads the next chunk into the buffer.
+func (r *chunkReader) next() error {
+	chunkKey, err := chunkPrefix(r.ctx, Tuple{r.name.Bytes(), r.manifest.ID, r.index})
+	if err != nil {
+		return err
+	}
+	item, err := r.txn.Get(chunkKey)
+	if err != nil {
+		return errors.Wrapf(r.ctx, err, "get chunk %d of key %s failed", r.index, r.key)
+	}
+	chunk, err := item.ValueCopy(nil)
+	if err != nil {
+		return errors.Wrapf(r.ctx, err, "copy chunk %d of key %s failed", r.index, r.key)
+	}
+	if crc32.Checksum(chunk, castagnoli) != r.manifest.Checksums[r.index] {
+		return errors.Wrapf(
+			r.ctx,
+			ErrChecksumMismatch,
+			"chunk %d of key %s in bucket %s",
+			r.index,
+			r.key,
+			r.name,
+		)
+	}
+	r.hash.Write(chunk)
+	r.size += int64(len(chunk))
+	r.buf = chunk
+	r.index++
+	return nil
+}
+
+// verify returns io.EOF if size and checksum of the content read match the manifest.
+func (r *chunkReader) verify() error {
+	if r.size != r.manifest.Size || !bytes.Equal(r.hash.Sum(nil), r.manifest.SHA256) {
+		return errors.Wrapf(
+			r.ctx,
+			ErrChecksumMismatch,
+			"content of key %s in bucket %s",
+			r.key,
+			r.name,
+		)
+	}
+	return io.EOF
+}
+
+// streamSize returns the content size of the stream whose manifest is item.
+func streamSize(item *badger.Item) (int64, error) {
+	var manifest StreamManifest
+	err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &manifest)
+	})
+	return manifest.Size, err
+}
+
+func parseStreamManifest(ctx context.Context, item *badger.Item) (*StreamManifest, error) {
+	var manifest StreamManifest
+	err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &manifest)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "parse manifest of %s failed", item.Key())
+	}
+	return &manifest, nil
+}
+
+func newStreamID(ctx context.Context) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errors.Wrapf(ctx, err, "create stream id failed")
+	}
+	return id, nil
+}
+
+// copyBucketChunks calls fn with copies of the chunks of src for the bucket dst.
+func copyBucketChunks(
+	ctx context.Context,
+	txn *badger.Txn,
+	src libkv.BucketName,
+	dst libkv.BucketName,
+	fn func(entry *badger.Entry) error,
+) error {
+	if err := copyBucketTuples(ctx, txn, chunkBucketName, src, dst, fn); err != nil {
+		return errors.Wrapf(ctx, err, "copy chunks failed")
+	}
+	return nil
+}
+
+// deleteBucketChunks deletes the chunks of all streams of the bucket.
+func deleteBucketChunks(ctx context.Context, txn *badger.Txn, name libkv.BucketName) error {
+	prefix, err := chunkPrefix(ctx, Tuple{name.Bytes()})
+	if err != nil {
+		return err
+	}
+	return forEachTupleKey(ctx, txn, prefix, func(item *badger.Item) error {
+		if err := txn.Delete(item.KeyCopy(nil)); err != nil {
+			return errors.Wrapf(ctx, err, "delete chunk failed")
+		}
+		return nil
+	})
+}
+
+// chunkPrefix returns the key of the packed tuple in the chunk bucket.
+func chunkPrefix(ctx context.Context, tuple Tuple) ([]byte, error) {
+	packed, err := tuple.Pack(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "pack chunk key failed")
+	}
+	return BucketAddKey(chunkBucketName, packed), nil
+}
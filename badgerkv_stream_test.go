@@ -0,0 +1,239 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Stream", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var files libkv.BucketName
+	var content []byte
+	var err error
+
+	withBucket := func(fn func(bucket libbadgerkv.Bucket) error) error {
+		return updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, files)
+			if err != nil {
+				return err
+			}
+			badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			return fn(badgerBucket)
+		})
+	}
+
+	putStream := func(key string, content []byte) error {
+		return withBucket(func(bucket libbadgerkv.Bucket) error {
+			return bucket.PutStream(ctx, []byte(key), bytes.NewReader(content))
+		})
+	}
+
+	getStream := func(name libkv.BucketName, key string) ([]byte, error) {
+		var result []byte
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.Bucket(ctx, name)
+			if err != nil {
+				return err
+			}
+			badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			r, err := badgerBucket.GetStream(ctx, []byte(key))
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			result, err = io.ReadAll(r)
+			return err
+		})
+		return result, err
+	}
+
+	// chunkKeys returns the keys of the internal chunk bucket
+	chunkKeys := func() [][]byte {
+		var result [][]byte
+		err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			badgerTx, ok := tx.(libbadgerkv.Tx)
+			Expect(ok).To(BeTrue())
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = []byte("__chunk_")
+			it := badgerTx.Tx().NewIterator(opts)
+			defer it.Close()
+			for it.Rewind(); it.Valid(); it.Next() {
+				result = append(result, it.Item().KeyCopy(nil))
+			}
+			return nil
+		})
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+		files = libkv.NewBucketName("files")
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			_, err := tx.CreateBucket(ctx, files)
+			return err
+		})).To(Succeed())
+		content = make([]byte, 2*libbadgerkv.DefaultStreamChunkSize+100)
+		_, err = rand.New(rand.NewSource(1)).Read(content)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("reads the content written", func() {
+		Expect(putStream("model.bin", content)).To(Succeed())
+		Expect(chunkKeys()).To(HaveLen(3))
+		result, err := getStream(files, "model.bin")
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(content))
+	})
+
+	It("reads empty content", func() {
+		Expect(putStream("empty", nil)).To(Succeed())
+		Expect(chunkKeys()).To(BeEmpty())
+		result, err := getStream(files, "empty")
+		Expect(err).To(BeNil())
+		Expect(result).To(BeEmpty())
+	})
+
+	It("returns the manifest with Get", func() {
+		Expect(putStream("model.bin", content)).To(Succeed())
+		Expect(withBucket(func(bucket libbadgerkv.Bucket) error {
+			item, err := bucket.Get(ctx, []byte("model.bin"))
+			if err != nil {
+				return err
+			}
+			return item.Value(func(val []byte) error {
+				var manifest libbadgerkv.StreamManifest
+				Expect(json.Unmarshal(val, &manifest)).To(Succeed())
+				Expect(manifest.Size).To(Equal(int64(len(content))))
+				Expect(manifest.Checksums).To(HaveLen(3))
+				return nil
+			})
+		})).To(Succeed())
+	})
+
+	It("returns plain values as stream", func() {
+		Expect(withBucket(func(bucket libbadgerkv.Bucket) error {
+			return bucket.Put(ctx, []byte("plain"), []byte("hello"))
+		})).To(Succeed())
+		result, err := getStream(files, "plain")
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal([]byte("hello")))
+	})
+
+	It("returns ErrKeyNotFound for missing keys", func() {
+		_, err = getStream(files, "missing")
+		Expect(errors.Is(err, libkv.ErrKeyNotFound)).To(BeTrue())
+	})
+
+	It("deletes chunks on Delete", func() {
+		Expect(putStream("model.bin", content)).To(Succeed())
+		Expect(withBucket(func(bucket libbadgerkv.Bucket) error {
+			return bucket.Delete(ctx, []byte("model.bin"))
+		})).To(Succeed())
+		Expect(chunkKeys()).To(BeEmpty())
+	})
+
+	It("deletes chunks on Put", func() {
+		Expect(putStream("model.bin", content)).To(Succeed())
+		Expect(withBucket(func(bucket libbadgerkv.Bucket) error {
+			return bucket.Put(ctx, []byte("model.bin"), []byte("small"))
+		})).To(Succeed())
+		Expect(chunkKeys()).To(BeEmpty())
+	})
+
+	It("deletes chunks of the replaced stream", func() {
+		Expect(putStream("model.bin", content)).To(Succeed())
+		Expect(putStream("model.bin", content[:10])).To(Succeed())
+		Expect(chunkKeys()).To(HaveLen(1))
+		result, err := getStream(files, "model.bin")
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(content[:10]))
+	})
+
+	It("deletes chunks with the bucket", func() {
+		Expect(putStream("model.bin", content)).To(Succeed())
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			return tx.DeleteBucket(ctx, files)
+		})).To(Succeed())
+		Expect(chunkKeys()).To(BeEmpty())
+	})
+
+	It("deletes chunks on truncate", func() {
+		Expect(putStream("model.bin", content)).To(Succeed())
+		Expect(db.TruncateBucket(ctx, files)).To(Succeed())
+		Expect(chunkKeys()).To(BeEmpty())
+	})
+
+	It("fails with ErrChecksumMismatch for corrupted chunks", func() {
+		Expect(putStream("model.bin", content)).To(Succeed())
+		chunkKey := chunkKeys()[1]
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			return tx.Tx().Set(chunkKey, []byte("corrupted"))
+		})).To(Succeed())
+		_, err = getStream(files, "model.bin")
+		Expect(errors.Is(err, libbadgerkv.ErrChecksumMismatch)).To(BeTrue())
+	})
+
+	It("keeps streams readable after rename", func() {
+		Expect(putStream("model.bin", content)).To(Succeed())
+		archive := libkv.NewBucketName("archive")
+		Expect(db.RenameBucket(ctx, files, archive)).To(Succeed())
+		Expect(chunkKeys()).To(HaveLen(3))
+		result, err := getStream(archive, "model.bin")
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(content))
+	})
+
+	It("copies streams with the bucket", func() {
+		Expect(putStream("model.bin", content)).To(Succeed())
+		filesCopy := libkv.NewBucketName("files_copy")
+		Expect(updateTx(ctx, db, func(tx libbadgerkv.Tx) error {
+			return tx.CopyBucket(ctx, files, filesCopy)
+		})).To(Succeed())
+		Expect(withBucket(func(bucket libbadgerkv.Bucket) error {
+			return bucket.Delete(ctx, []byte("model.bin"))
+		})).To(Succeed())
+		result, err := getStream(filesCopy, "model.bin")
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(content))
+	})
+
+	It("writes large content in batches with DB.PutStream", func() {
+		large := bytes.Repeat(content, 8)
+		Expect(db.PutStream(ctx, files, []byte("large.bin"), bytes.NewReader(large))).To(Succeed())
+		result, err := getStream(files, "large.bin")
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(large))
+	})
+
+	It("deletes chunks of a failed DB.PutStream", func() {
+		missing := libkv.NewBucketName("missing")
+		err = db.PutStream(ctx, missing, []byte("model.bin"), bytes.NewReader(content))
+		Expect(errors.Is(err, libkv.BucketNotFoundError)).To(BeTrue())
+		Expect(chunkKeys()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,258 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/bborbe/errors"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Type codes of tuple elements. Bytes, strings and integers follow the
+// FoundationDB tuple layer, timestamps use a code not defined there.
+const (
+	tupleBytesCode   = 0x01
+	tupleStringCode  = 0x02
+	tupleIntZeroCode = 0x14
+	tupleTimeCode    = 0x3F
+)
+
+// Tuple is a composite key of elements of type []byte, string, signed and unsigned
+// integers and time.Time. The packed form sorts like the tuple: element by element,
+// integers numerically, timestamps chronologically and strings and bytes bytewise,
+// even if they contain 0x00. A packed tuple is a prefix of all packed tuples
+// starting with its elements, so a partial tuple can be used for Seek and prefix scans.
+//
+// Elements of different types sort by type: bytes, strings, integers, timestamps.
+// Unpack returns integers as int64, or uint64 if larger than math.MaxInt64,
+// and timestamps in UTC.
+type Tuple []any
+
+// Pack returns the order preserving encoding of the tuple.
+func (t Tuple) Pack(ctx context.Context) ([]byte, error) {
+	var result []byte
+	for i, element := range t {
+		var err error
+		if result, err = appendTupleElement(ctx, result, element); err != nil {
+			return nil, errors.Wrapf(ctx, err, "pack element %d failed", i)
+		}
+	}
+	return result, nil
+}
+
+// PrefixRange returns the keys from begin (inclusive) to end (exclusive) containing
+// the tuple and all tuples starting with its elements.
+func (t Tuple) PrefixRange(ctx context.Context) ([]byte, []byte, error) {
+	begin, err := t.Pack(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrapf(ctx, err, "pack failed")
+	}
+	end := make([]byte, 0, len(begin)+1)
+	end = append(end, begin...)
+	return begin, append(end, 0xFF), nil
+}
+
+// forEachTupleKey calls fn for all keys starting with the packed tuple prefix, without
+// keys whose last element continues the last element of prefix with 0x00, for example
+// the keys of ("a\x00b") for the prefix ("a"). The item is only valid until fn returns.
+func forEachTupleKey(
+	ctx context.Context,
+	txn *badger.Txn,
+	prefix []byte,
+	fn func(item *badger.Item) error,
+) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx, ctx.Err(), "context cancelled")
+		default:
+		}
+
+		key := it.Item().Key()
+		if len(key) > len(prefix) && key[len(prefix)] == 0xFF {
+			continue
+		}
+		if err := fn(it.Item()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnpackTuple decodes a tuple packed with Tuple.Pack.
+func UnpackTuple(ctx context.Context, data []byte) (Tuple, error) {
+	var result Tuple
+	for len(data) > 0 {
+		element, n, err := readTupleElement(ctx, data)
+		if err != nil {
+			return nil, errors.Wrapf(ctx, err, "unpack element %d failed", len(result))
+		}
+		result = append(result, element)
+		data = data[n:]
+	}
+	return result, nil
+}
+
+// NewTupleCodec returns a key codec for tuples.
+func NewTupleCodec() Codec[Tuple] {
+	return tupleCodec{}
+}
+
+type tupleCodec struct{}
+
+func (tupleCodec) Encode(ctx context.Context, value Tuple) ([]byte, error) {
+	return value.Pack(ctx)
+}
+
+func (tupleCodec) Decode(ctx context.Context, data []byte) (Tuple, error) {
+	return UnpackTuple(ctx, data)
+}
+
+// PrefixRange bounds ForEachPrefix of typed buckets, the packed prefix alone would
+// match tuples whose last element continues with 0x00.
+func (tupleCodec) PrefixRange(ctx context.Context, prefix Tuple) ([]byte, []byte, error) {
+	return prefix.PrefixRange(ctx)
+}
+
+func appendTupleElement(ctx context.Context, buf []byte, element any) ([]byte, error) {
+	switch v := element.(type) {
+	case []byte:
+		return appendEscaped(append(buf, tupleBytesCode), v), nil
+	case string:
+		return appendEscaped(append(buf, tupleStringCode), []byte(v)), nil
+	case int:
+		return appendTupleInt(buf, int64(v)), nil
+	case int8:
+		return appendTupleInt(buf, int64(v)), nil
+	case int16:
+		return appendTupleInt(buf, int64(v)), nil
+	case int32:
+		return appendTupleInt(buf, int64(v)), nil
+	case int64:
+		return appendTupleInt(buf, v), nil
+	case uint:
+		return appendTupleUint(buf, uint64(v)), nil
+	case uint8:
+		return appendTupleUint(buf, uint64(v)), nil
+	case uint16:
+		return appendTupleUint(buf, uint64(v)), nil
+	case uint32:
+		return appendTupleUint(buf, uint64(v)), nil
+	case uint64:
+		return appendTupleUint(buf, v), nil
+	case time.Time:
+		return appendTupleTime(buf, v), nil
+	default:
+		return nil, errors.Wrapf(ctx, ErrInvalidEncoding, "unsupported tuple element %T", element)
+	}
+}
+
+// appendTupleTime writes the seconds with flipped sign bit, so negative seconds sort
+// before positive ones, followed by the nanoseconds.
+func appendTupleTime(buf []byte, v time.Time) []byte {
+	buf = append(buf, tupleTimeCode)
+	sec := uint64(v.Unix()) ^ (1 << 63) // #nosec G115 -- bit pattern
+	buf = binary.BigEndian.AppendUint64(buf, sec)
+	return binary.BigEndian.AppendUint32(buf, uint32(v.Nanosecond())) // #nosec G115 -- < 1e9
+}
+
+// appendTupleUint writes the type code 0x14 plus the number of bytes
+// followed by the big-endian bytes of v without leading zeros.
+func appendTupleUint(buf []byte, v uint64) []byte {
+	n := (bits.Len64(v) + 7) / 8
+	buf = append(buf, byte(tupleIntZeroCode+n))
+	return appendTupleIntBytes(buf, v, n)
+}
+
+// appendTupleInt writes negative values with the type code 0x14 minus the number of bytes
+// followed by the ones' complement of the absolute value, so larger absolute values sort first.
+func appendTupleInt(buf []byte, v int64) []byte {
+	if v >= 0 {
+		return appendTupleUint(buf, uint64(v))
+	}
+	abs := uint64(-(v + 1)) + 1 // #nosec G115 -- v+1 avoids overflow for math.MinInt64
+	n := (bits.Len64(abs) + 7) / 8
+	buf = append(buf, byte(tupleIntZeroCode-n))
+	return appendTupleIntBytes(buf, ^abs, n)
+}
+
+func appendTupleIntBytes(buf []byte, v uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*i)))
+	}
+	return buf
+}
+
+// readTupleElement decodes the first element of data and returns it with the bytes consumed.
+func readTupleElement(ctx context.Context, data []byte) (any, int, error) {
+	code := data[0]
+	switch {
+	case code == tupleBytesCode:
+		value, n, err := readEscaped(ctx, data[1:])
+		if err != nil {
+			return nil, 0, errors.Wrapf(ctx, err, "read bytes failed")
+		}
+		if value == nil {
+			value = []byte{}
+		}
+		return value, n + 1, nil
+	case code == tupleStringCode:
+		value, n, err := readEscaped(ctx, data[1:])
+		if err != nil {
+			return nil, 0, errors.Wrapf(ctx, err, "read string failed")
+		}
+		return string(value), n + 1, nil
+	case code >= tupleIntZeroCode-8 && code <= tupleIntZeroCode+8:
+		return readTupleInt(ctx, data)
+	case code == tupleTimeCode:
+		if len(data) < 13 {
+			return nil, 0, errors.Wrapf(ctx, ErrInvalidEncoding, "time needs 12 bytes")
+		}
+		sec := int64(binary.BigEndian.Uint64(data[1:9]) ^ (1 << 63)) // #nosec G115 -- bit pattern
+		nsec := int64(binary.BigEndian.Uint32(data[9:13]))
+		return time.Unix(sec, nsec).UTC(), 13, nil
+	default:
+		return nil, 0, errors.Wrapf(ctx, ErrInvalidEncoding, "unknown type code 0x%02x", code)
+	}
+}
+
+func readTupleInt(ctx context.Context, data []byte) (any, int, error) {
+	n := int(data[0]) - tupleIntZeroCode
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	if len(data) < n+1 {
+		return nil, 0, errors.Wrapf(ctx, ErrInvalidEncoding, "integer needs %d bytes", n)
+	}
+	var v uint64
+	for _, b := range data[1 : n+1] {
+		v = v<<8 | uint64(b)
+	}
+	if !negative {
+		if v > math.MaxInt64 {
+			return v, n + 1, nil
+		}
+		return int64(v), n + 1, nil // #nosec G115 -- checked above
+	}
+	abs := ^v
+	if n < 8 {
+		abs &= 1<<(8*n) - 1
+	}
+	if abs > 1<<63 {
+		return nil, 0, errors.Wrapf(ctx, ErrInvalidEncoding, "integer below math.MinInt64")
+	}
+	return -int64(abs-1) - 1, n + 1, nil // #nosec G115 -- abs <= 1<<63
+}
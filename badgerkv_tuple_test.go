@@ -0,0 +1,227 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Tuple", func() {
+	var ctx context.Context
+
+	pack := func(tuple libbadgerkv.Tuple) []byte {
+		data, err := tuple.Pack(ctx)
+		Expect(err).To(BeNil())
+		return data
+	}
+
+	// sortPacked packs the tuples, sorts them bytewise and returns them unpacked
+	sortPacked := func(tuples ...libbadgerkv.Tuple) []libbadgerkv.Tuple {
+		var packed [][]byte
+		for _, tuple := range tuples {
+			packed = append(packed, pack(tuple))
+		}
+		sort.Slice(packed, func(i, j int) bool {
+			return bytes.Compare(packed[i], packed[j]) < 0
+		})
+		var result []libbadgerkv.Tuple
+		for _, data := range packed {
+			tuple, err := libbadgerkv.UnpackTuple(ctx, data)
+			Expect(err).To(BeNil())
+			result = append(result, tuple)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	It("unpacks packed tuple", func() {
+		createdAt := time.Date(2026, 10, 18, 12, 30, 0, 123, time.UTC)
+		tuple := libbadgerkv.Tuple{
+			"user:1", []byte{0x00, 0x01}, int64(-5), uint64(math.MaxUint64), createdAt,
+		}
+		result, err := libbadgerkv.UnpackTuple(ctx, pack(tuple))
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(tuple))
+	})
+
+	It("returns integers as int64", func() {
+		result, err := libbadgerkv.UnpackTuple(ctx, pack(libbadgerkv.Tuple{7, uint8(8), int32(-9)}))
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(libbadgerkv.Tuple{int64(7), int64(8), int64(-9)}))
+	})
+
+	It("sorts integers numerically", func() {
+		values := []int64{
+			math.MaxInt64, 256, -1, 0, math.MinInt64, 255, -256, 1, -255, -257, 65536,
+		}
+		var tuples []libbadgerkv.Tuple
+		for _, value := range values {
+			tuples = append(tuples, libbadgerkv.Tuple{value})
+		}
+		var result []int64
+		for _, tuple := range sortPacked(tuples...) {
+			value, ok := tuple[0].(int64)
+			Expect(ok).To(BeTrue())
+			result = append(result, value)
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		Expect(result).To(Equal(values))
+	})
+
+	It("sorts strings containing delimiters and zero bytes", func() {
+		Expect(sortPacked(
+			libbadgerkv.Tuple{"a:b", int64(1)},
+			libbadgerkv.Tuple{"a", "c"},
+			libbadgerkv.Tuple{"a\x00", int64(1)},
+			libbadgerkv.Tuple{"a", int64(2)},
+		)).To(Equal([]libbadgerkv.Tuple{
+			{"a", "c"},
+			{"a", int64(2)},
+			{"a\x00", int64(1)},
+			{"a:b", int64(1)},
+		}))
+	})
+
+	It("sorts timestamps chronologically", func() {
+		first := time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+		second := time.Date(2026, 1, 1, 0, 0, 0, 1, time.UTC)
+		third := time.Date(2026, 1, 1, 0, 0, 1, 0, time.UTC)
+		Expect(sortPacked(
+			libbadgerkv.Tuple{third},
+			libbadgerkv.Tuple{first},
+			libbadgerkv.Tuple{second},
+		)).To(Equal([]libbadgerkv.Tuple{{first}, {second}, {third}}))
+	})
+
+	It("packs prefix of longer tuples", func() {
+		Expect(bytes.HasPrefix(
+			pack(libbadgerkv.Tuple{"orders", int64(1), "item"}),
+			pack(libbadgerkv.Tuple{"orders", int64(1)}),
+		)).To(BeTrue())
+	})
+
+	It("fails for unsupported element", func() {
+		_, err := libbadgerkv.Tuple{1.5}.Pack(ctx)
+		Expect(errors.Is(err, libbadgerkv.ErrInvalidEncoding)).To(BeTrue())
+	})
+
+	It("fails for truncated data", func() {
+		data := pack(libbadgerkv.Tuple{int64(1000)})
+		_, err := libbadgerkv.UnpackTuple(ctx, data[:len(data)-1])
+		Expect(errors.Is(err, libbadgerkv.ErrInvalidEncoding)).To(BeTrue())
+	})
+
+	Context("scans", func() {
+		var db libbadgerkv.DB
+		var name libkv.BucketName
+
+		BeforeEach(func() {
+			var err error
+			db, err = libbadgerkv.OpenMemory(ctx)
+			Expect(err).To(BeNil())
+			name = libkv.NewBucketName("orders")
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.CreateBucket(ctx, name)
+				if err != nil {
+					return err
+				}
+				for _, tuple := range []libbadgerkv.Tuple{
+					{"alice", int64(2)},
+					{"alice", int64(10)},
+					{"alice", int64(1)},
+					{"alice:x", int64(1)},
+					{"alice\x00x", int64(1)},
+					{"bob", int64(1)},
+				} {
+					if err := bucket.Put(ctx, pack(tuple), []byte("{}")); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			_ = db.Close()
+		})
+
+		It("iterates prefix with typed bucket", func() {
+			var result []libbadgerkv.Tuple
+			err := db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, name)
+				if err != nil {
+					return err
+				}
+				badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+				Expect(ok).To(BeTrue())
+				orders := libbadgerkv.NewTypedBucket(
+					badgerBucket,
+					libbadgerkv.NewTupleCodec(),
+					libbadgerkv.NewJSONCodec[map[string]any](),
+				)
+				return orders.ForEachPrefix(
+					ctx,
+					libbadgerkv.Tuple{"alice"},
+					func(key libbadgerkv.Tuple, value map[string]any) error {
+						result = append(result, key)
+						return nil
+					},
+				)
+			})
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal([]libbadgerkv.Tuple{
+				{"alice", int64(1)},
+				{"alice", int64(2)},
+				{"alice", int64(10)},
+			}))
+		})
+
+		It("iterates prefix range in reverse", func() {
+			begin, end, err := libbadgerkv.Tuple{"alice"}.PrefixRange(ctx)
+			Expect(err).To(BeNil())
+			var result []libbadgerkv.Tuple
+			err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, name)
+				if err != nil {
+					return err
+				}
+				it := bucket.IteratorReverse()
+				defer it.Close()
+				for it.Seek(end); it.Valid(); it.Next() {
+					if bytes.Compare(it.Item().Key(), begin) < 0 {
+						break
+					}
+					tuple, err := libbadgerkv.UnpackTuple(ctx, it.Item().Key())
+					if err != nil {
+						return err
+					}
+					result = append(result, tuple)
+				}
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal([]libbadgerkv.Tuple{
+				{"alice", int64(10)},
+				{"alice", int64(2)},
+				{"alice", int64(1)},
+			}))
+		})
+	})
+})
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"sync"
+	"time"
 
 	"github.com/bborbe/errors"
 	libkv "github.com/bborbe/kv"
@@ -18,12 +19,28 @@ import (
 type Tx interface {
 	libkv.Tx
 	Tx() *badger.Txn
+	// CopyBucket copies all keys of src into the new bucket dst.
+	CopyBucket(ctx context.Context, src libkv.BucketName, dst libkv.BucketName) error
+	// RenameBucket moves all keys of oldName into the new bucket newName.
+	RenameBucket(ctx context.Context, oldName libkv.BucketName, newName libkv.BucketName) error
+	// ClearBucket deletes all keys of the bucket but keeps it registered.
+	ClearBucket(ctx context.Context, name libkv.BucketName) error
+	// BucketInfo returns the metadata of the bucket.
+	BucketInfo(ctx context.Context, name libkv.BucketName) (*BucketInfo, error)
+	// SetBucketMeta replaces the metadata of the bucket.
+	SetBucketMeta(ctx context.Context, name libkv.BucketName, meta BucketMeta) error
 }
 
+var bucketRegistryName = libkv.NewBucketName("__bucket")
+
 func NewTx(badgerTx *badger.Txn) Tx {
+	return newTx(badgerTx)
+}
+
+func newTx(badgerTx *badger.Txn) *tx {
 	return &tx{
 		badgerTx:   badgerTx,
-		bucketName: libkv.NewBucketName("__bucket"),
+		bucketName: bucketRegistryName,
 
 		cache: make(map[string]libkv.Bucket),
 	}
@@ -35,13 +52,42 @@ type tx struct {
 
 	mux   sync.Mutex
 	cache map[string]libkv.Bucket
+
+	// indexes are the indexes maintained by buckets of the transaction, nil outside of a DB.
+	indexes *indexRegistry
+	// values compresses and verifies the values of buckets of the transaction, nil outside
+	// of a DB.
+	values *valueCodec
+	// reserved are the buckets DB level copies write to, nil outside of a DB.
+	reserved *bucketReservations
+	// guard marks reads of a snapshot as active and fails once it expired, nil otherwise.
+	guard func(ctx context.Context) (func(), error)
 }
 
+// acquire guards a read against the release of an expired snapshot. The returned func
+// ends the read.
+func (t *tx) acquire(ctx context.Context) (func(), error) {
+	if t.guard == nil {
+		return func() {}, nil
+	}
+	return t.guard(ctx)
+}
+
+// ListBucketNames returns the names of all top level buckets.
+// Sub-buckets are listed with Bucket.ListBucketNames of their parent.
 func (t *tx) ListBucketNames(ctx context.Context) (libkv.BucketNames, error) {
+	done, err := t.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 	result := libkv.BucketNames{}
 	bucket := NewBucket(t.badgerTx, t.bucketName)
-	err := libkv.ForEach(ctx, bucket, func(item libkv.Item) error {
-		result = append(result, item.Key())
+	err = libkv.ForEach(ctx, bucket, func(item libkv.Item) error {
+		if bytes.IndexByte(item.Key(), subBucketSeparator) >= 0 {
+			return nil
+		}
+		result = append(result, libkv.BucketName(bytes.Clone(item.Key())))
 		return nil
 	})
 	if err != nil {
@@ -50,11 +96,30 @@ func (t *tx) ListBucketNames(ctx context.Context) (libkv.BucketNames, error) {
 	return result, nil
 }
 
+// listBucketNamesWithPrefix returns the names of all registered buckets starting with
+// prefix, including sub-buckets.
+func (t *tx) listBucketNamesWithPrefix(
+	ctx context.Context,
+	prefix []byte,
+) ([]libkv.BucketName, error) {
+	done, err := t.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return listRegisteredBuckets(ctx, t.badgerTx, prefix)
+}
+
 func (t *tx) Tx() *badger.Txn {
 	return t.badgerTx
 }
 
 func (t *tx) Bucket(ctx context.Context, name libkv.BucketName) (libkv.Bucket, error) {
+	done, err := t.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 	t.mux.Lock()
 	defer t.mux.Unlock()
 
@@ -63,14 +128,14 @@ func (t *tx) Bucket(ctx context.Context, name libkv.BucketName) (libkv.Bucket, e
 		return bucket, nil
 	}
 
-	exists, err := t.existsBucket(ctx, name)
+	info, err := t.bucketInfo(ctx, name)
 	if err != nil {
-		return nil, errors.Wrapf(ctx, err, "check exists failed")
+		return nil, errors.Wrapf(ctx, err, "get info failed")
 	}
-	if !exists {
+	if info == nil {
 		return nil, errors.Wrapf(ctx, libkv.BucketNotFoundError, "bucket %s not found", name)
 	}
-	bucket = NewBucket(t.badgerTx, name)
+	bucket = t.newBucket(info)
 	t.cache[name.String()] = bucket
 	return bucket, nil
 }
@@ -91,10 +156,14 @@ func (t *tx) CreateBucket(ctx context.Context, name libkv.BucketName) (libkv.Buc
 			name,
 		)
 	}
-	if err := t.createBucket(ctx, name); err != nil {
+	if err := t.checkReserved(ctx, name); err != nil {
+		return nil, err
+	}
+	info, err := t.createBucket(ctx, name)
+	if err != nil {
 		return nil, errors.Wrapf(ctx, err, "create bucket failed")
 	}
-	bucket := NewBucket(t.badgerTx, name)
+	bucket := t.newBucket(info)
 	t.cache[name.String()] = bucket
 	return bucket, nil
 }
@@ -111,20 +180,25 @@ func (t *tx) CreateBucketIfNotExists(
 		return bucket, nil
 	}
 
-	exists, err := t.existsBucket(ctx, name)
+	info, err := t.bucketInfo(ctx, name)
 	if err != nil {
-		return nil, errors.Wrapf(ctx, err, "check exists failed")
+		return nil, errors.Wrapf(ctx, err, "get info failed")
 	}
-	if !exists {
-		if err := t.createBucket(ctx, name); err != nil {
+	if info == nil {
+		if err := t.checkReserved(ctx, name); err != nil {
+			return nil, err
+		}
+		info, err = t.createBucket(ctx, name)
+		if err != nil {
 			return nil, errors.Wrapf(ctx, err, "create bucket failed")
 		}
 	}
-	bucket = NewBucket(t.badgerTx, name)
+	bucket = t.newBucket(info)
 	t.cache[name.String()] = bucket
 	return bucket, nil
 }
 
+// DeleteBucket deletes the bucket with all of its keys and sub-buckets.
 func (t *tx) DeleteBucket(ctx context.Context, name libkv.BucketName) error {
 	t.mux.Lock()
 	defer t.mux.Unlock()
@@ -139,57 +213,80 @@ func (t *tx) DeleteBucket(ctx context.Context, name libkv.BucketName) error {
 	if err := t.deleteBucket(ctx, name); err != nil {
 		return errors.Wrapf(ctx, err, "delete bucket failed")
 	}
-	opts := badger.DefaultIteratorOptions
-	opts.PrefetchSize = 10
-	it := t.badgerTx.NewIterator(opts)
-	defer it.Close()
-	for it.Seek(name.Bytes()); it.Valid(); it.Next() {
-		select {
-		case <-ctx.Done():
-			return errors.Wrap(ctx, ctx.Err(), "context cancelled")
-		default:
-		}
-
-		key := it.Item().Key()
-		if !bytes.HasPrefix(key, name.Bytes()) {
-			glog.V(3).Infof("delete all key of bucket %s completed", name)
-			break
-		}
-		if err := t.badgerTx.Delete(key); err != nil {
-			return errors.Wrapf(ctx, err, "delete bucket failed")
-		}
+	err = forEachBucketKey(ctx, t.badgerTx, name, func(item *badger.Item) error {
+		return t.badgerTx.Delete(item.KeyCopy(nil))
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "delete bucket failed")
 	}
-
+	glog.V(3).Infof("delete all key of bucket %s completed", name)
 	delete(t.cache, name.String())
+
+	if err := t.deleteSubBuckets(ctx, name); err != nil {
+		return errors.Wrapf(ctx, err, "delete sub-buckets of %s failed", name)
+	}
 	return nil
 }
 
+// checkReserved returns BucketAlreadyExistsError if a DB level copy writes to name.
+func (t *tx) checkReserved(ctx context.Context, name libkv.BucketName) error {
+	if t.reserved == nil {
+		return nil
+	}
+	return t.reserved.Check(ctx, name)
+}
+
 func (t *tx) existsBucket(ctx context.Context, name libkv.BucketName) (bool, error) {
-	bucket := NewBucket(t.badgerTx, t.bucketName)
-	var exists bool
-	value, err := bucket.Get(ctx, name.Bytes())
+	info, err := t.bucketInfo(ctx, name)
 	if err != nil {
-		return false, errors.Wrapf(ctx, err, "get failed")
+		return false, errors.Wrapf(ctx, err, "get info failed")
 	}
-	err = value.Value(func(val []byte) error {
-		exists = bytes.Equal(val, []byte("true"))
-		return nil
-	})
-	if err != nil {
-		return false, errors.Wrapf(ctx, err, "value failed")
+	return info != nil, nil
+}
+
+func (t *tx) createBucket(ctx context.Context, name libkv.BucketName) (*BucketInfo, error) {
+	info := &BucketInfo{Name: name, CreatedAt: time.Now().UTC()}
+	if err := t.putBucketInfo(ctx, *info); err != nil {
+		return nil, errors.Wrapf(ctx, err, "put info failed")
 	}
-	return exists, nil
+	return info, nil
 }
 
-func (t *tx) createBucket(ctx context.Context, name libkv.BucketName) error {
-	bucket := NewBucket(t.badgerTx, t.bucketName)
-	if err := bucket.Put(ctx, name.Bytes(), []byte("true")); err != nil {
-		return errors.Wrapf(ctx, err, "put failed")
+func (t *tx) newBucket(info *BucketInfo) Bucket {
+	return &bucket{
+		bucketName: info.Name,
+		badgerTx:   t.badgerTx,
+		tx:         t,
+		trackStats: info.TrackStats,
+		streams:    info.Streams,
+		meta:       info.BucketMeta,
 	}
-	return nil
 }
 
+// deleteBucket removes the bucket from the registry and deletes its metadata, counters,
+// index entries and stream chunks.
 func (t *tx) deleteBucket(ctx context.Context, name libkv.BucketName) error {
-	bucket := NewBucket(t.badgerTx, t.bucketName)
-	return bucket.Delete(ctx, name.Bytes())
+	if err := t.deleteBucketInfo(ctx, name); err != nil {
+		return errors.Wrapf(ctx, err, "delete info failed")
+	}
+	if err := deleteBucketIndexEntries(ctx, t.badgerTx, name); err != nil {
+		return errors.Wrapf(ctx, err, "delete index entries failed")
+	}
+	if err := deleteBucketChunks(ctx, t.badgerTx, name); err != nil {
+		return errors.Wrapf(ctx, err, "delete chunks failed")
+	}
+	return deleteBucketCounters(ctx, t.badgerTx, name)
+}
+
+// bucket returns the bucket handle of name.
+func (t *tx) bucket(ctx context.Context, name libkv.BucketName) (*bucket, error) {
+	result, err := t.Bucket(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := result.(*bucket)
+	if !ok {
+		return nil, errors.Errorf(ctx, "unexpected bucket type %T", result)
+	}
+	return b, nil
 }
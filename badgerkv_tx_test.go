@@ -150,6 +150,28 @@ var _ = Describe("Transaction", func() {
 			Expect(err).To(BeNil())
 		})
 
+		It("keeps buckets whose name starts with the deleted name", func() {
+			otherName := libkv.NewBucketName("testbucket_v2")
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				_, err := tx.CreateBucket(ctx, bucketName)
+				Expect(err).To(BeNil())
+
+				bucket, err := tx.CreateBucket(ctx, otherName)
+				Expect(err).To(BeNil())
+				Expect(bucket.Put(ctx, []byte("key1"), []byte("value1"))).To(Succeed())
+
+				Expect(tx.DeleteBucket(ctx, bucketName)).To(Succeed())
+
+				bucket, err = tx.Bucket(ctx, otherName)
+				Expect(err).To(BeNil())
+				item, err := bucket.Get(ctx, []byte("key1"))
+				Expect(err).To(BeNil())
+				Expect(item.Exists()).To(BeTrue())
+				return nil
+			})
+			Expect(err).To(BeNil())
+		})
+
 		It("fails to delete non-existing bucket", func() {
 			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
 				err := tx.DeleteBucket(ctx, bucketName)
@@ -0,0 +1,243 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+)
+
+// TypedBucket stores values of type V at keys of type K in a Bucket.
+// Keys and values are converted with codecs, values are decoded directly from
+// Badger's value buffer without an extra copy. Streams written with PutStream are not
+// values of the TypedBucket, Get reports them as missing and iteration skips them. A
+// TypedBucket is only valid within the transaction of its bucket.
+type TypedBucket[K any, V any] interface {
+	// Bucket returns the underlying bucket.
+	Bucket() Bucket
+	// Get returns the value of key or an error wrapping libkv.ErrKeyNotFound.
+	Get(ctx context.Context, key K) (*V, error)
+	// Put stores value at key.
+	Put(ctx context.Context, key K, value V) error
+	// Delete removes key.
+	Delete(ctx context.Context, key K) error
+	// ForEach calls fn for all keys in the order of their encoding.
+	ForEach(ctx context.Context, fn func(key K, value V) error) error
+	// Range calls fn for all keys from from (inclusive) to to (exclusive)
+	// in the order of their encoding.
+	Range(ctx context.Context, from K, to K, fn func(key K, value V) error) error
+	// ForEachPrefix calls fn for all keys whose encoding starts with the encoding of prefix.
+	// For tuple keys these are all tuples starting with the elements of prefix, without
+	// tuples whose last element of prefix continues with 0x00.
+	ForEachPrefix(ctx context.Context, prefix K, fn func(key K, value V) error) error
+}
+
+// NewTypedBucket returns a TypedBucket for bucket using keyCodec and valueCodec.
+func NewTypedBucket[K any, V any](
+	bucket Bucket,
+	keyCodec Codec[K],
+	valueCodec Codec[V],
+) TypedBucket[K, V] {
+	return &typedBucket[K, V]{
+		bucket:     bucket,
+		keyCodec:   keyCodec,
+		valueCodec: valueCodec,
+	}
+}
+
+// prefixRanger is implemented by key codecs whose encoded prefix is also a byte prefix
+// of keys not starting with the prefix, like tuples whose elements are escaped.
+type prefixRanger[K any] interface {
+	PrefixRange(ctx context.Context, prefix K) ([]byte, []byte, error)
+}
+
+type typedBucket[K any, V any] struct {
+	bucket     Bucket
+	keyCodec   Codec[K]
+	valueCodec Codec[V]
+}
+
+func (t *typedBucket[K, V]) Bucket() Bucket {
+	return t.bucket
+}
+
+func (t *typedBucket[K, V]) Get(ctx context.Context, key K) (*V, error) {
+	encodedKey, err := t.keyCodec.Encode(ctx, key)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "encode key failed")
+	}
+	item, err := t.bucket.Get(ctx, encodedKey)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "get %v failed", key)
+	}
+	if !item.Exists() {
+		return nil, errors.Wrapf(ctx, libkv.ErrKeyNotFound, "key %v not found", key)
+	}
+	if isStreamItem(item) {
+		return nil, errors.Wrapf(ctx, libkv.ErrKeyNotFound, "key %v is a stream", key)
+	}
+	value, err := t.decodeValue(ctx, item)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "decode value of %v failed", key)
+	}
+	return &value, nil
+}
+
+func (t *typedBucket[K, V]) Put(ctx context.Context, key K, value V) error {
+	encodedKey, err := t.keyCodec.Encode(ctx, key)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "encode key failed")
+	}
+	encodedValue, err := t.valueCodec.Encode(ctx, value)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "encode value of %v failed", key)
+	}
+	if err := t.bucket.Put(ctx, encodedKey, encodedValue); err != nil {
+		return errors.Wrapf(ctx, err, "put %v failed", key)
+	}
+	return nil
+}
+
+func (t *typedBucket[K, V]) Delete(ctx context.Context, key K) error {
+	encodedKey, err := t.keyCodec.Encode(ctx, key)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "encode key failed")
+	}
+	if err := t.bucket.Delete(ctx, encodedKey); err != nil {
+		return errors.Wrapf(ctx, err, "delete %v failed", key)
+	}
+	return nil
+}
+
+func (t *typedBucket[K, V]) ForEach(ctx context.Context, fn func(key K, value V) error) error {
+	return t.iterate(ctx, nil, nil, fn)
+}
+
+func (t *typedBucket[K, V]) ForEachPrefix(
+	ctx context.Context,
+	prefix K,
+	fn func(key K, value V) error,
+) error {
+	if ranger, ok := t.keyCodec.(prefixRanger[K]); ok {
+		begin, end, err := ranger.PrefixRange(ctx, prefix)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "get prefix range failed")
+		}
+		return t.iterate(ctx, begin, func(key []byte) bool {
+			return bytes.Compare(key, end) < 0
+		}, fn)
+	}
+	encodedPrefix, err := t.keyCodec.Encode(ctx, prefix)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "encode prefix failed")
+	}
+	return t.iterate(ctx, encodedPrefix, func(key []byte) bool {
+		return bytes.HasPrefix(key, encodedPrefix)
+	}, fn)
+}
+
+func (t *typedBucket[K, V]) Range(
+	ctx context.Context,
+	from K,
+	to K,
+	fn func(key K, value V) error,
+) error {
+	encodedFrom, err := t.keyCodec.Encode(ctx, from)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "encode from failed")
+	}
+	encodedTo, err := t.keyCodec.Encode(ctx, to)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "encode to failed")
+	}
+	return t.iterate(ctx, encodedFrom, func(key []byte) bool {
+		return bytes.Compare(key, encodedTo) < 0
+	}, fn)
+}
+
+// iterate calls fn for the keys from from as long as inRange returns true,
+// nil means from the first key and to the last key.
+// Keys of other buckets sharing the prefix of the bucket are skipped.
+func (t *typedBucket[K, V]) iterate(
+	ctx context.Context,
+	from []byte,
+	inRange func(key []byte) bool,
+	fn func(key K, value V) error,
+) error {
+	nestedPrefixes, err := t.nestedKeyPrefixes(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "get nested key prefixes failed")
+	}
+	it := t.bucket.Iterator()
+	defer it.Close()
+	if from == nil {
+		it.Rewind()
+	} else {
+		it.Seek(from)
+	}
+	for ; it.Valid(); it.Next() {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx, ctx.Err(), "context cancelled")
+		default:
+		}
+
+		item := it.Item()
+		if inRange != nil && !inRange(item.Key()) {
+			return nil
+		}
+		if hasAnyPrefix(item.Key(), nestedPrefixes) || isStreamItem(item) {
+			continue
+		}
+		key, err := t.keyCodec.Decode(ctx, item.Key())
+		if err != nil {
+			return errors.Wrapf(ctx, err, "decode key failed")
+		}
+		value, err := t.decodeValue(ctx, item)
+		if err != nil {
+			return errors.Wrapf(ctx, err, "decode value of %v failed", key)
+		}
+		if err := fn(key, value); err != nil {
+			return errors.Wrapf(ctx, err, "fn failed")
+		}
+	}
+	return nil
+}
+
+// decodeValue decodes the value inside the callback of item.Value,
+// the buffer is owned by Badger and not copied.
+func (t *typedBucket[K, V]) decodeValue(ctx context.Context, item libkv.Item) (V, error) {
+	var result V
+	err := item.Value(func(val []byte) error {
+		var err error
+		result, err = t.valueCodec.Decode(ctx, val)
+		return err
+	})
+	return result, err
+}
+
+// isStreamItem returns true if item is the manifest of a stream written with PutStream.
+func isStreamItem(item libkv.Item) bool {
+	badgerItem, ok := item.(Item)
+	return ok && badgerItem.Item().UserMeta()&streamManifestMeta != 0
+}
+
+// nestedKeyPrefixes returns the prefixes of keys, relative to the bucket,
+// that belong to other buckets sharing the prefix of the bucket.
+func (t *typedBucket[K, V]) nestedKeyPrefixes(ctx context.Context) ([][]byte, error) {
+	name := t.bucket.BucketName()
+	prefixes, err := nestedBucketPrefixes(ctx, t.bucket.Tx(), name)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "get nested bucket prefixes of %s failed", name)
+	}
+	bucketPrefix := BucketToPrefix(name)
+	for i, prefix := range prefixes {
+		prefixes[i] = prefix[len(bucketPrefix):]
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("TypedBucket", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var err error
+
+	type entry struct {
+		key  uint64
+		name string
+	}
+
+	update := func(fn func(users libbadgerkv.TypedBucket[uint64, codecUser]) error) error {
+		return db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, libkv.NewBucketName("users"))
+			if err != nil {
+				return err
+			}
+			badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			return fn(libbadgerkv.NewTypedBucket(
+				badgerBucket,
+				libbadgerkv.NewUint64Codec(),
+				libbadgerkv.NewJSONCodec[codecUser](),
+			))
+		})
+	}
+
+	collect := func(result *[]entry) func(key uint64, value codecUser) error {
+		return func(key uint64, value codecUser) error {
+			*result = append(*result, entry{key: key, name: value.Name})
+			return nil
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+
+		Expect(update(func(users libbadgerkv.TypedBucket[uint64, codecUser]) error {
+			Expect(users.Put(ctx, 256, codecUser{Name: "carol"})).To(Succeed())
+			Expect(users.Put(ctx, 1, codecUser{Name: "alice"})).To(Succeed())
+			return users.Put(ctx, 2, codecUser{Name: "bob"})
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("returns decoded value", func() {
+		Expect(update(func(users libbadgerkv.TypedBucket[uint64, codecUser]) error {
+			user, err := users.Get(ctx, 2)
+			Expect(err).To(BeNil())
+			Expect(user.Name).To(Equal("bob"))
+			return nil
+		})).To(Succeed())
+	})
+
+	It("returns key not found for missing key", func() {
+		Expect(update(func(users libbadgerkv.TypedBucket[uint64, codecUser]) error {
+			_, err := users.Get(ctx, 3)
+			Expect(errors.Is(err, libkv.ErrKeyNotFound)).To(BeTrue())
+			return nil
+		})).To(Succeed())
+	})
+
+	It("deletes key", func() {
+		Expect(update(func(users libbadgerkv.TypedBucket[uint64, codecUser]) error {
+			Expect(users.Delete(ctx, 2)).To(Succeed())
+			_, err := users.Get(ctx, 2)
+			Expect(errors.Is(err, libkv.ErrKeyNotFound)).To(BeTrue())
+			return nil
+		})).To(Succeed())
+	})
+
+	It("iterates in key order", func() {
+		var result []entry
+		Expect(update(func(users libbadgerkv.TypedBucket[uint64, codecUser]) error {
+			return users.ForEach(ctx, collect(&result))
+		})).To(Succeed())
+		Expect(result).To(Equal([]entry{{1, "alice"}, {2, "bob"}, {256, "carol"}}))
+	})
+
+	It("iterates range with exclusive end", func() {
+		var result []entry
+		Expect(update(func(users libbadgerkv.TypedBucket[uint64, codecUser]) error {
+			return users.Range(ctx, 2, 256, collect(&result))
+		})).To(Succeed())
+		Expect(result).To(Equal([]entry{{2, "bob"}}))
+	})
+
+	It("skips keys of buckets sharing the prefix", func() {
+		err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucket(ctx, libkv.NewBucketName("users_v2"))
+			if err != nil {
+				return err
+			}
+			return bucket.Put(ctx, []byte("x"), []byte("not json"))
+		})
+		Expect(err).To(BeNil())
+
+		var result []entry
+		Expect(update(func(users libbadgerkv.TypedBucket[uint64, codecUser]) error {
+			return users.ForEach(ctx, collect(&result))
+		})).To(Succeed())
+		Expect(result).To(HaveLen(3))
+	})
+
+	It("skips streams", func() {
+		key, err := libbadgerkv.NewUint64Codec().Encode(ctx, 3)
+		Expect(err).To(BeNil())
+		var result []entry
+		Expect(update(func(users libbadgerkv.TypedBucket[uint64, codecUser]) error {
+			content := strings.NewReader("not json")
+			Expect(users.Bucket().PutStream(ctx, key, content)).To(Succeed())
+			_, err := users.Get(ctx, 3)
+			Expect(errors.Is(err, libkv.ErrKeyNotFound)).To(BeTrue())
+			return users.ForEach(ctx, collect(&result))
+		})).To(Succeed())
+		Expect(result).To(Equal([]entry{{1, "alice"}, {2, "bob"}, {256, "carol"}}))
+	})
+})
@@ -0,0 +1,430 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/golang/glog"
+)
+
+// DefaultVerifySamples is the max number of keys per problem listed in a VerifyReport.
+const DefaultVerifySamples = 10
+
+// internalBucketNames are the buckets used by badgerkv itself. They are not registered.
+var internalBucketNames = []libkv.BucketName{
+	bucketRegistryName,
+	bucketMetaName,
+	sequenceBucketName,
+	bucketStatsName,
+	bucketStatShardsName,
+	indexBucketName,
+	chunkBucketName,
+}
+
+// VerifyReport is the result of Verify. Empty buckets are valid and only listed for
+// information, all other findings are problems.
+type VerifyReport struct {
+	// Buckets is the number of registered buckets.
+	Buckets int `json:"buckets"`
+	// Keys is the number of keys scanned, including internal keys.
+	Keys int64 `json:"keys"`
+	// EmptyBuckets are registered buckets without keys.
+	EmptyBuckets []libkv.BucketName `json:"empty_buckets,omitempty"`
+	// InvalidRegistryEntries are registered buckets whose registry entry or metadata can
+	// not be parsed.
+	InvalidRegistryEntries []libkv.BucketName `json:"invalid_registry_entries,omitempty"`
+	// OrphanedKeys is the number of keys whose bucket prefix is not registered.
+	OrphanedKeys int64 `json:"orphaned_keys"`
+	// MalformedKeys is the number of keys without bucket separator.
+	MalformedKeys int64 `json:"malformed_keys"`
+	// OrphanedCounters are maintained counters of buckets not registered.
+	OrphanedCounters []libkv.BucketName `json:"orphaned_counters,omitempty"`
+	// OrphanedMeta are metadata entries of buckets not registered, for example left by
+	// DeleteBucket of older versions.
+	OrphanedMeta []libkv.BucketName `json:"orphaned_meta,omitempty"`
+	// OrphanedIndexEntries is the number of index entries of buckets not registered.
+	OrphanedIndexEntries int64 `json:"orphaned_index_entries"`
+	// OrphanedChunks is the number of stream chunks of buckets not registered or not
+	// referenced by a stream manifest of their bucket. Chunks of streams written with
+	// DB.PutStream are orphaned until their manifest is written.
+	OrphanedChunks int64 `json:"orphaned_chunks"`
+	// Samples lists up to DefaultVerifySamples orphaned, malformed and corrupted keys.
+	Samples []string `json:"samples,omitempty"`
+	// ChecksumMismatches is the number of values stored with checksum that do not match it.
+	ChecksumMismatches int64 `json:"checksum_mismatches"`
+	// ChecksumError is the result of Badger's table checksum verification.
+	ChecksumError string `json:"checksum_error,omitempty"`
+}
+
+// OK returns true if no problem was found.
+func (r VerifyReport) OK() bool {
+	return len(r.problems()) == 0
+}
+
+// Err returns an error wrapping ErrVerifyFailed that describes the problems found,
+// or nil if there are none.
+func (r VerifyReport) Err(ctx context.Context) error {
+	problems := r.problems()
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.Wrapf(ctx, ErrVerifyFailed, "%s", strings.Join(problems, ", "))
+}
+
+func (r VerifyReport) problems() []string {
+	var result []string
+	if len(r.InvalidRegistryEntries) > 0 {
+		result = append(
+			result,
+			fmt.Sprintf("%d invalid registry entries", len(r.InvalidRegistryEntries)),
+		)
+	}
+	if r.OrphanedKeys > 0 {
+		result = append(result, fmt.Sprintf("%d orphaned keys", r.OrphanedKeys))
+	}
+	if r.MalformedKeys > 0 {
+		result = append(result, fmt.Sprintf("%d malformed keys", r.MalformedKeys))
+	}
+	if len(r.OrphanedCounters) > 0 {
+		result = append(result, fmt.Sprintf("%d orphaned counters", len(r.OrphanedCounters)))
+	}
+	if len(r.OrphanedMeta) > 0 {
+		result = append(result, fmt.Sprintf("%d orphaned meta entries", len(r.OrphanedMeta)))
+	}
+	if r.OrphanedIndexEntries > 0 {
+		result = append(
+			result,
+			fmt.Sprintf("%d orphaned index entries", r.OrphanedIndexEntries),
+		)
+	}
+	if r.OrphanedChunks > 0 {
+		result = append(result, fmt.Sprintf("%d orphaned chunks", r.OrphanedChunks))
+	}
+	if r.ChecksumMismatches > 0 {
+		result = append(result, fmt.Sprintf("%d checksum mismatches", r.ChecksumMismatches))
+	}
+	if r.ChecksumError != "" {
+		result = append(result, "checksum: "+r.ChecksumError)
+	}
+	return result
+}
+
+// Verify checks the consistency of the store: every registry entry must parse, every key
+// must belong to a registered or internal bucket, every stream chunk to a stream manifest,
+// values stored with checksum and Badger's table checksums must match. It scans all keys
+// and reads only the stream manifests and the values stored with checksum, so it is meant
+// for startup after an unclean shutdown or for maintenance, not for frequent polling. Use
+// Ready for readiness probes.
+// The returned error is only set if the check itself failed; problems are in the report.
+func (b *badgerdb) Verify(ctx context.Context) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	err := b.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+		badgerTx, ok := tx.(Tx)
+		if !ok {
+			return errors.Errorf(ctx, "unexpected tx type %T", tx)
+		}
+		return verifyKeys(ctx, badgerTx.Tx(), report, nil)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "verify keys failed")
+	}
+	if err := b.db.VerifyChecksum(); err != nil {
+		report.ChecksumError = err.Error()
+	}
+	glog.V(2).Infof("verify completed: %+v", report)
+	return report, nil
+}
+
+// Ready returns nil if the store is open and the bucket registry can be read. It is
+// cheap enough for readiness probes, which should also require a successful Verify at
+// startup.
+func (b *badgerdb) Ready(ctx context.Context) error {
+	if b.db.IsClosed() {
+		return errors.Errorf(ctx, "db closed")
+	}
+	err := b.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+		_, err := tx.ListBucketNames(ctx)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "read bucket registry failed")
+	}
+	return nil
+}
+
+// orphanKind is the kind of orphaned key passed to an orphanHandler.
+type orphanKind int
+
+const (
+	// orphanedKey is a key of a bucket not registered.
+	orphanedKey orphanKind = iota
+	// orphanedCounter is a maintained counter or metadata entry of a bucket not registered.
+	orphanedCounter
+	// orphanedIndexEntry is an index entry of a bucket not registered.
+	orphanedIndexEntry
+	// orphanedChunk is a stream chunk not referenced by a stream manifest of its bucket.
+	orphanedChunk
+)
+
+// orphanHandler is called by verifyKeys for every orphaned key. name is the bucket of
+// internal entries and nil for orphaned keys, whose bucket is unknown.
+type orphanHandler func(item *badger.Item, kind orphanKind, name []byte) error
+
+// verifyKeys fills report from a scan of all keys and calls onOrphan, if not nil, for
+// every orphaned key.
+func verifyKeys(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	report *VerifyReport,
+	onOrphan orphanHandler,
+) error {
+	if onOrphan == nil {
+		onOrphan = func(item *badger.Item, kind orphanKind, name []byte) error { return nil }
+	}
+	registered, err := verifyRegistry(ctx, badgerTx, report)
+	if err != nil {
+		return err
+	}
+	keyCounts := make(map[string]int64, len(registered))
+	internal := make(map[string]bool, len(internalBucketNames))
+	for _, name := range internalBucketNames {
+		internal[name.String()] = true
+	}
+	// manifests are the chunk counts of the stream manifests by packed bucket and stream id
+	manifests := make(map[string]int)
+	addSample := func(key []byte) {
+		if len(report.Samples) < DefaultVerifySamples {
+			report.Samples = append(report.Samples, fmt.Sprintf("%q", key))
+		}
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := badgerTx.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx, ctx.Err(), "context cancelled")
+		default:
+		}
+
+		item := it.Item()
+		key := item.Key()
+		report.Keys++
+		name, ok := keyBucketName(key, func(name []byte) bool {
+			return registered[string(name)] || internal[string(name)]
+		})
+		switch {
+		case ok && bytes.Equal(name, indexBucketName):
+			indexed := tupleBucketName(ctx, BucketRemoveKey(name, key))
+			if !registered[string(indexed)] {
+				report.OrphanedIndexEntries++
+				addSample(key)
+				if err := onOrphan(item, orphanedIndexEntry, indexed); err != nil {
+					return errors.Wrapf(ctx, err, "handle orphaned index entry failed")
+				}
+			}
+		case ok && bytes.Equal(name, chunkBucketName):
+			// checked against the stream manifests after the scan
+		case ok && internal[string(name)]:
+			if entryName, ok := verifyInternalEntry(ctx, name, key, registered, report); !ok {
+				if err := onOrphan(item, orphanedCounter, entryName); err != nil {
+					return errors.Wrapf(ctx, err, "handle orphaned entry failed")
+				}
+			}
+		case ok:
+			keyCounts[string(name)]++
+			if err := verifyValueChecksum(ctx, item, name); err != nil {
+				if !errors.Is(err, ErrChecksumMismatch) {
+					return err
+				}
+				report.ChecksumMismatches++
+				addSample(key)
+			}
+			if err := addStreamManifest(ctx, manifests, item, name); err != nil {
+				return err
+			}
+		case bytes.IndexByte(key, bucketKeySeperator) < 0:
+			report.MalformedKeys++
+			addSample(key)
+		default:
+			report.OrphanedKeys++
+			addSample(key)
+			if err := onOrphan(item, orphanedKey, nil); err != nil {
+				return errors.Wrapf(ctx, err, "handle orphaned key failed")
+			}
+		}
+	}
+	for name := range registered {
+		if keyCounts[name] == 0 {
+			report.EmptyBuckets = append(report.EmptyBuckets, libkv.BucketName(name))
+		}
+	}
+	return forEachBucketKey(ctx, badgerTx, chunkBucketName, func(item *badger.Item) error {
+		name, ok := verifyChunk(ctx, item.Key(), registered, manifests)
+		if ok {
+			return nil
+		}
+		report.OrphanedChunks++
+		addSample(item.Key())
+		if err := onOrphan(item, orphanedChunk, name); err != nil {
+			return errors.Wrapf(ctx, err, "handle orphaned chunk failed")
+		}
+		return nil
+	})
+}
+
+// addStreamManifest adds the chunk count of item to manifests if item is a stream
+// manifest of the bucket name.
+func addStreamManifest(
+	ctx context.Context,
+	manifests map[string]int,
+	item *badger.Item,
+	name []byte,
+) error {
+	if item.UserMeta()&streamManifestMeta == 0 {
+		return nil
+	}
+	manifest, err := parseStreamManifest(ctx, item)
+	if err != nil {
+		return err
+	}
+	stream, err := Tuple{name, manifest.ID}.Pack(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "pack stream of %s failed", item.Key())
+	}
+	manifests[string(stream)] = len(manifest.Checksums)
+	return nil
+}
+
+// verifyChunk returns the bucket of the chunk key and true if the bucket is registered
+// and a stream manifest of the bucket references the chunk.
+func verifyChunk(
+	ctx context.Context,
+	key []byte,
+	registered map[string]bool,
+	manifests map[string]int,
+) ([]byte, bool) {
+	name, stream, index, ok := parseChunkKey(ctx, key)
+	if !ok || !registered[string(name)] {
+		return name, false
+	}
+	count, ok := manifests[string(stream)]
+	return name, ok && index >= 0 && index < int64(count)
+}
+
+// parseChunkKey returns the bucket, the packed bucket and stream id and the index of the
+// chunk key. Returns false if key is no chunk key.
+func parseChunkKey(ctx context.Context, key []byte) ([]byte, []byte, int64, bool) {
+	tuple, err := UnpackTuple(ctx, BucketRemoveKey(chunkBucketName, key))
+	if err != nil || len(tuple) != 3 {
+		return nil, nil, 0, false
+	}
+	name, _ := tuple[0].([]byte)
+	index, ok := tuple[2].(int64)
+	if !ok {
+		return name, nil, 0, false
+	}
+	stream, err := Tuple{name, tuple[1]}.Pack(ctx)
+	if err != nil {
+		return name, nil, 0, false
+	}
+	return name, stream, index, true
+}
+
+// verifyValueChecksum returns an error wrapping ErrChecksumMismatch if the value of
+// item is stored with checksum and does not match it.
+func verifyValueChecksum(ctx context.Context, item *badger.Item, name []byte) error {
+	if item.UserMeta()&checksumValueMeta == 0 {
+		return nil
+	}
+	return item.Value(func(val []byte) error {
+		_, err := verifyChecksum(ctx, name, BucketRemoveKey(name, item.Key()), val)
+		return err
+	})
+}
+
+// verifyRegistry parses all registry entries and their metadata and returns the
+// registered bucket names.
+func verifyRegistry(
+	ctx context.Context,
+	badgerTx *badger.Txn,
+	report *VerifyReport,
+) (map[string]bool, error) {
+	registered := make(map[string]bool)
+	t := newTx(badgerTx)
+	err := forEachBucketKey(ctx, badgerTx, bucketRegistryName, func(item *badger.Item) error {
+		name := libkv.BucketName(BucketRemoveKey(bucketRegistryName, item.KeyCopy(nil)))
+		if _, err := t.bucketInfo(ctx, name); err != nil {
+			report.InvalidRegistryEntries = append(report.InvalidRegistryEntries, name)
+		}
+		registered[name.String()] = true
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "read registry failed")
+	}
+	report.Buckets = len(registered)
+	return registered, nil
+}
+
+// verifyInternalEntry returns the bucket of the entry and false and records the entry in
+// report if key is a counter or metadata entry of a bucket not registered.
+func verifyInternalEntry(
+	ctx context.Context,
+	internalName []byte,
+	key []byte,
+	registered map[string]bool,
+	report *VerifyReport,
+) ([]byte, bool) {
+	var orphans *[]libkv.BucketName
+	name := BucketRemoveKey(internalName, key)
+	switch {
+	case bytes.Equal(internalName, bucketStatsName):
+		orphans = &report.OrphanedCounters
+	case bytes.Equal(internalName, bucketStatShardsName):
+		orphans = &report.OrphanedCounters
+		name = tupleBucketName(ctx, name)
+	case bytes.Equal(internalName, bucketMetaName):
+		orphans = &report.OrphanedMeta
+	default:
+		return name, true
+	}
+	if registered[string(name)] {
+		return name, true
+	}
+	*orphans = append(*orphans, bytes.Clone(name))
+	return name, false
+}
+
+// tupleBucketName returns the bucket name packed as first element of the tuple data, or
+// nil if data is no such tuple.
+func tupleBucketName(ctx context.Context, data []byte) []byte {
+	tuple, err := UnpackTuple(ctx, data)
+	if err != nil || len(tuple) == 0 {
+		return nil
+	}
+	name, _ := tuple[0].([]byte)
+	return name
+}
+
+// keyBucketName returns the longest prefix of key that is followed by the bucket key
+// separator and accepted by known.
+func keyBucketName(key []byte, known func(name []byte) bool) ([]byte, bool) {
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i] == bucketKeySeperator && known(key[:i]) {
+			return key[:i], true
+		}
+	}
+	return nil, false
+}
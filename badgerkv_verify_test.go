@@ -0,0 +1,158 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("Verify", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var err error
+
+	setRaw := func(key string, value string) {
+		err := db.DB().Update(func(txn *badger.Txn) error {
+			return txn.Set([]byte(key), []byte(value))
+		})
+		Expect(err).To(BeNil())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemory(ctx)
+		Expect(err).To(BeNil())
+
+		err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			users, err := tx.CreateBucket(ctx, libkv.NewBucketName("users"))
+			Expect(err).To(BeNil())
+			Expect(users.Put(ctx, []byte("alice"), []byte("admin"))).To(Succeed())
+			usersV2, err := tx.CreateBucket(ctx, libkv.NewBucketName("users_v2"))
+			Expect(err).To(BeNil())
+			Expect(usersV2.Put(ctx, []byte("bob"), []byte("user"))).To(Succeed())
+			tenant, ok := usersV2.(libbadgerkv.Bucket)
+			Expect(ok).To(BeTrue())
+			sub, err := tenant.CreateSubBucket(ctx, libkv.NewBucketName("archive"))
+			Expect(err).To(BeNil())
+			Expect(sub.Put(ctx, []byte("carol"), []byte("user"))).To(Succeed())
+			_, err = tx.CreateBucket(ctx, libkv.NewBucketName("empty"))
+			return err
+		})
+		Expect(err).To(BeNil())
+		Expect(db.EnableBucketStats(ctx, libkv.NewBucketName("users"))).To(Succeed())
+		seq, err := db.Sequence(ctx, []byte("ids"), 10)
+		Expect(err).To(BeNil())
+		_, err = seq.Next()
+		Expect(err).To(BeNil())
+		Expect(seq.Release()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	It("reports a consistent store", func() {
+		report, err := db.Verify(ctx)
+		Expect(err).To(BeNil())
+		Expect(report.OK()).To(BeTrue())
+		Expect(report.Err(ctx)).To(BeNil())
+		Expect(report.Buckets).To(Equal(4))
+		Expect(report.EmptyBuckets).To(Equal([]libkv.BucketName{libkv.NewBucketName("empty")}))
+		Expect(db.Ready(ctx)).To(Succeed())
+	})
+
+	It("finds orphaned and malformed keys", func() {
+		setRaw("orders_1", "value")
+		setRaw("nokey", "value")
+		setRaw("__stats_orders", "0000000000000000")
+		setRaw("__bucket_broken", "{")
+		setRaw("__meta_orders", "{}")
+
+		report, err := db.Verify(ctx)
+		Expect(err).To(BeNil())
+		Expect(report.OK()).To(BeFalse())
+		Expect(report.OrphanedKeys).To(Equal(int64(1)))
+		Expect(report.MalformedKeys).To(Equal(int64(1)))
+		Expect(report.OrphanedCounters).To(HaveLen(1))
+		Expect(report.OrphanedMeta).To(HaveLen(1))
+		Expect(report.InvalidRegistryEntries).To(HaveLen(1))
+		Expect(report.Samples).To(HaveLen(2))
+		Expect(errors.Is(report.Err(ctx), libbadgerkv.ErrVerifyFailed)).To(BeTrue())
+	})
+
+	Context("with index and stream", func() {
+		users := libkv.NewBucketName("users")
+
+		setTuple := func(internalName string, tuple libbadgerkv.Tuple) {
+			packed, err := tuple.Pack(ctx)
+			Expect(err).To(BeNil())
+			setRaw(internalName+"_"+string(packed), "value")
+		}
+
+		BeforeEach(func() {
+			err = db.RegisterIndex(ctx, users, libbadgerkv.Index{
+				Name: "role",
+				Func: func(ctx context.Context, value []byte) ([][]byte, error) {
+					return [][]byte{value}, nil
+				},
+			})
+			Expect(err).To(BeNil())
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				bucket, err := tx.Bucket(ctx, users)
+				Expect(err).To(BeNil())
+				Expect(bucket.Put(ctx, []byte("dave"), []byte("user"))).To(Succeed())
+				badgerBucket, ok := bucket.(libbadgerkv.Bucket)
+				Expect(ok).To(BeTrue())
+				content := bytes.NewReader(make([]byte, 2*libbadgerkv.DefaultStreamChunkSize))
+				return badgerBucket.PutStream(ctx, []byte("avatar"), content)
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("accepts index entries and chunks of registered buckets", func() {
+			report, err := db.Verify(ctx)
+			Expect(err).To(BeNil())
+			Expect(report.OK()).To(BeTrue())
+		})
+
+		It("finds orphaned index entries", func() {
+			setTuple(
+				"__index",
+				libbadgerkv.Tuple{[]byte("orders"), "role", []byte("x"), []byte("1")},
+			)
+
+			report, err := db.Verify(ctx)
+			Expect(err).To(BeNil())
+			Expect(report.OrphanedIndexEntries).To(Equal(int64(1)))
+			Expect(report.OrphanedChunks).To(BeZero())
+			Expect(report.OK()).To(BeFalse())
+		})
+
+		It("finds orphaned chunks", func() {
+			setTuple("__chunk", libbadgerkv.Tuple{[]byte("orders"), []byte("id"), 0})
+			setTuple("__chunk", libbadgerkv.Tuple{users.Bytes(), []byte("unknown"), 0})
+
+			report, err := db.Verify(ctx)
+			Expect(err).To(BeNil())
+			Expect(report.OrphanedChunks).To(Equal(int64(2)))
+			Expect(report.OrphanedIndexEntries).To(BeZero())
+			Expect(report.OK()).To(BeFalse())
+		})
+	})
+
+	It("is not ready after close", func() {
+		Expect(db.Close()).To(Succeed())
+		Expect(db.Ready(ctx)).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,187 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// DefaultVersionRetention is how long a managed DB keeps old versions readable via ViewAt.
+const DefaultVersionRetention = 10 * time.Minute
+
+// CurrentVersion returns the timestamp of the latest committed write transaction.
+// Pass it to ViewAt to read the database as of this moment, even from several
+// transactions or goroutines.
+func (b *badgerdb) CurrentVersion() uint64 {
+	if b.versions == nil {
+		return b.db.MaxVersion()
+	}
+	return b.versions.Current()
+}
+
+// ViewAt opens a read only transaction that sees the database as of readTs.
+// It requires a managed DB (see OpenPathManaged) and a readTs obtained from
+// CurrentVersion that is younger than the version retention.
+func (b *badgerdb) ViewAt(
+	ctx context.Context,
+	readTs uint64,
+	fn func(ctx context.Context, tx libkv.Tx) error,
+) error {
+	if b.versions == nil {
+		return errors.Wrapf(ctx, ErrManagedModeRequired, "view at %d failed", readTs)
+	}
+	release, err := b.versions.Acquire(ctx, readTs)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "acquire version %d failed", readTs)
+	}
+	defer release()
+	return b.runTx(ctx, "view at", func(fn func(*badger.Txn) error) error {
+		txn := b.db.NewTransactionAt(readTs, false)
+		defer txn.Discard()
+		return fn(txn)
+	}, fn)
+}
+
+func (b *badgerdb) updateManaged(fn func(*badger.Txn) error) error {
+	release, readTs := b.versions.AcquireCurrent()
+	defer release()
+	txn := b.db.NewTransactionAt(readTs, true)
+	defer txn.Discard()
+	if err := fn(txn); err != nil {
+		return err
+	}
+	return b.versions.Commit(func(commitTs uint64) error {
+		return txn.CommitAt(commitTs, nil)
+	})
+}
+
+func newVersionTracker(
+	current uint64,
+	retention time.Duration,
+	setDiscardTs func(ts uint64),
+) *versionTracker {
+	return &versionTracker{
+		current:      current,
+		retention:    retention,
+		setDiscardTs: setDiscardTs,
+		active:       make(map[uint64]int),
+	}
+}
+
+type versionCommit struct {
+	ts uint64
+	at time.Time
+}
+
+// versionTracker allocates commit timestamps for managed transactions and decides
+// up to which timestamp Badger may discard old versions. The discard timestamp
+// never passes a version still in use or committed within the retention.
+type versionTracker struct {
+	commitMux    sync.Mutex
+	setDiscardTs func(ts uint64)
+	retention    time.Duration
+
+	mux       sync.Mutex
+	current   uint64
+	discardTs uint64
+	commits   []versionCommit
+	active    map[uint64]int
+}
+
+func (v *versionTracker) Current() uint64 {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	return v.current
+}
+
+// Acquire marks readTs as in use until the returned release func is called.
+func (v *versionTracker) Acquire(ctx context.Context, readTs uint64) (func(), error) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	if readTs > v.current {
+		return nil, errors.Wrapf(
+			ctx,
+			ErrVersionNotCommitted,
+			"version %d is newer than current version %d",
+			readTs,
+			v.current,
+		)
+	}
+	if readTs < v.discardTs {
+		return nil, errors.Wrapf(
+			ctx,
+			ErrVersionDiscarded,
+			"version %d is older than discard version %d",
+			readTs,
+			v.discardTs,
+		)
+	}
+	return v.acquire(readTs), nil
+}
+
+// AcquireCurrent marks the current version as in use and returns it.
+func (v *versionTracker) AcquireCurrent() (func(), uint64) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	return v.acquire(v.current), v.current
+}
+
+func (v *versionTracker) acquire(readTs uint64) func() {
+	v.active[readTs]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			v.mux.Lock()
+			defer v.mux.Unlock()
+			v.active[readTs]--
+			if v.active[readTs] == 0 {
+				delete(v.active, readTs)
+			}
+		})
+	}
+}
+
+// Commit calls commitFn with the next commit timestamp. Commits are serialized so
+// a version returned by Current is never followed by a commit with a lower timestamp.
+func (v *versionTracker) Commit(commitFn func(commitTs uint64) error) error {
+	v.commitMux.Lock()
+	defer v.commitMux.Unlock()
+
+	commitTs := v.Current() + 1
+	if err := commitFn(commitTs); err != nil {
+		return err
+	}
+
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	v.current = commitTs
+	v.commits = append(v.commits, versionCommit{ts: commitTs, at: time.Now()})
+	if discardTs := v.nextDiscardTs(); discardTs > v.discardTs {
+		v.discardTs = discardTs
+		v.setDiscardTs(discardTs)
+	}
+	return nil
+}
+
+func (v *versionTracker) nextDiscardTs() uint64 {
+	deadline := time.Now().Add(-v.retention)
+	discardTs := v.discardTs
+	for len(v.commits) > 1 && v.commits[1].at.Before(deadline) {
+		discardTs = v.commits[1].ts
+		v.commits = v.commits[1:]
+	}
+	for readTs := range v.active {
+		if readTs < discardTs {
+			discardTs = readTs
+		}
+	}
+	return discardTs
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package badgerkv_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	libbadgerkv "github.com/bborbe/badgerkv"
+)
+
+var _ = Describe("BadgerKV Managed", func() {
+	var ctx context.Context
+	var db libkv.DB
+	var err error
+	var provider libkv.ProviderFunc = func(ctx context.Context) (libkv.DB, error) {
+		return db, nil
+	}
+	BeforeEach(func() {
+		ctx = context.Background()
+		db, err = libbadgerkv.OpenMemoryManaged(ctx)
+		Expect(err).To(BeNil())
+	})
+	AfterEach(func() {
+		_ = db.Close()
+	})
+	libkv.BucketTestSuite(provider)
+	libkv.BasicTestSuite(provider)
+	libkv.IteratorTestSuite(provider)
+})
+
+var _ = Describe("Version", func() {
+	var ctx context.Context
+	var db libbadgerkv.DB
+	var bucketName libkv.BucketName
+	var err error
+
+	put := func(value string) {
+		err := db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(ctx, bucketName)
+			Expect(err).To(BeNil())
+			return bucket.Put(ctx, []byte("key"), []byte(value))
+		})
+		Expect(err).To(BeNil())
+	}
+
+	get := func(tx libkv.Tx) string {
+		bucket, err := tx.Bucket(ctx, bucketName)
+		Expect(err).To(BeNil())
+		item, err := bucket.Get(ctx, []byte("key"))
+		Expect(err).To(BeNil())
+		var result string
+		Expect(item.Value(func(val []byte) error {
+			result = string(val)
+			return nil
+		})).To(Succeed())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		bucketName = libkv.NewBucketName("reports")
+	})
+
+	AfterEach(func() {
+		_ = db.Close()
+	})
+
+	Context("managed", func() {
+		BeforeEach(func() {
+			db, err = libbadgerkv.OpenMemoryManaged(ctx)
+			Expect(err).To(BeNil())
+		})
+
+		It("increases version with each update", func() {
+			before := db.CurrentVersion()
+			put("v1")
+			Expect(db.CurrentVersion()).To(BeNumerically(">", before))
+		})
+
+		It("reads the snapshot of the given version", func() {
+			put("v1")
+			version := db.CurrentVersion()
+			put("v2")
+
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					err := db.ViewAt(ctx, version, func(ctx context.Context, tx libkv.Tx) error {
+						Expect(get(tx)).To(Equal("v1"))
+						return nil
+					})
+					Expect(err).To(BeNil())
+				}()
+			}
+			wg.Wait()
+
+			err = db.View(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				Expect(get(tx)).To(Equal("v2"))
+				return nil
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("rejects versions not committed yet", func() {
+			err = db.ViewAt(
+				ctx,
+				db.CurrentVersion()+1,
+				func(ctx context.Context, tx libkv.Tx) error {
+					return nil
+				},
+			)
+			Expect(errors.Is(err, libbadgerkv.ErrVersionNotCommitted)).To(BeTrue())
+		})
+
+		It("prevents nested transactions", func() {
+			err = db.Update(ctx, func(ctx context.Context, tx libkv.Tx) error {
+				return db.ViewAt(
+					ctx,
+					db.CurrentVersion(),
+					func(ctx context.Context, tx libkv.Tx) error {
+						return nil
+					},
+				)
+			})
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("transaction already open"))
+		})
+	})
+
+	Context("not managed", func() {
+		BeforeEach(func() {
+			db, err = libbadgerkv.OpenMemory(ctx)
+			Expect(err).To(BeNil())
+		})
+
+		It("returns ErrManagedModeRequired", func() {
+			put("v1")
+			err = db.ViewAt(
+				ctx,
+				db.CurrentVersion(),
+				func(ctx context.Context, tx libkv.Tx) error {
+					return nil
+				},
+			)
+			Expect(errors.Is(err, libbadgerkv.ErrManagedModeRequired)).To(BeTrue())
+		})
+	})
+})
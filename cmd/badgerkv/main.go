@@ -0,0 +1,326 @@
+// Copyright (c) 2026 Benjamin Borbe All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command badgerkv provides maintenance operations for badgerkv databases.
+//
+// Usage:
+//
+//	badgerkv rotate-key -dir /path/to/db -old-key-path old.key -new-key-path new.key
+//	badgerkv rebuild-stats -dir /path/to/db -bucket orders
+//	badgerkv verify-stats -dir /path/to/db -bucket orders
+//	badgerkv compact -dir /path/to/db -flatten
+//	badgerkv verify -dir /path/to/db
+//	badgerkv repair -dir /path/to/db -reregister -dry-run
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/bborbe/errors"
+	libkv "github.com/bborbe/kv"
+
+	"github.com/bborbe/badgerkv"
+)
+
+type command struct {
+	description string
+	run         func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"compact": {
+		description: "flatten the LSM tree and run value log GC to reclaim disk space",
+		run:         compact,
+	},
+	"repair": {
+		description: "register or drop keys of unregistered buckets",
+		run:         repair,
+	},
+	"rotate-key": {
+		description: "re-encrypt the key registry with a new encryption key",
+		run:         rotateKey,
+	},
+	"rebuild-stats": {
+		description: "count a bucket and maintain its key and byte counters",
+		run:         rebuildStats,
+	},
+	"verify": {
+		description: "check registry, key encoding, value and table checksums",
+		run:         verify,
+	},
+	"verify-stats": {
+		description: "compare the maintained counters of a bucket with a full scan",
+		run:         verifyStats,
+	},
+}
+
+func main() {
+	ctx := context.Background()
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(ctx, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: badgerkv <command> [flags]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].description)
+	}
+}
+
+func rotateKey(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	dir := fs.String("dir", "", "database directory")
+	oldKeyPath := fs.String(
+		"old-key-path",
+		"",
+		"file containing the current key, empty if unencrypted",
+	)
+	newKeyPath := fs.String(
+		"new-key-path",
+		"",
+		"file containing the new key, empty to disable encryption",
+	)
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrapf(ctx, err, "parse args failed")
+	}
+	if *dir == "" {
+		return errors.Errorf(ctx, "parameter dir missing")
+	}
+	oldKey, err := readKey(ctx, *oldKeyPath)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "read old key failed")
+	}
+	newKey, err := readKey(ctx, *newKeyPath)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "read new key failed")
+	}
+	return badgerkv.RotateEncryptionKey(ctx, *dir, oldKey, newKey)
+}
+
+// readKey returns the key stored in the file at path, or an empty key if path is empty.
+// Surrounding whitespace like the trailing newline of echo is not part of the key.
+func readKey(ctx context.Context, path string) ([]byte, error) {
+	if path == "" {
+		return []byte{}, nil
+	}
+	content, err := os.ReadFile(path) // #nosec G304 -- path is given by the operator
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "read file %s failed", path)
+	}
+	key := bytes.TrimSpace(content)
+	if len(key) == 0 {
+		return nil, errors.Wrapf(
+			ctx,
+			badgerkv.ErrInvalidEncryptionKey,
+			"key file %s is empty",
+			path,
+		)
+	}
+	if err := badgerkv.ValidateEncryptionKey(ctx, key); err != nil {
+		return nil, errors.Wrapf(ctx, err, "key file %s invalid", path)
+	}
+	return key, nil
+}
+
+func rebuildStats(ctx context.Context, args []string) (err error) {
+	fs := flag.NewFlagSet("rebuild-stats", flag.ExitOnError)
+	dir := fs.String("dir", "", "database directory")
+	keyPath := fs.String("key-path", "", "file containing the key, empty if unencrypted")
+	bucket := fs.String("bucket", "", "bucket name")
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrapf(ctx, err, "parse args failed")
+	}
+	if *bucket == "" {
+		return errors.Errorf(ctx, "parameter bucket missing")
+	}
+	db, err := openDB(ctx, *dir, *keyPath)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "open db failed")
+	}
+	defer closeDB(ctx, db, &err)
+	return db.EnableBucketStats(ctx, libkv.NewBucketName(*bucket))
+}
+
+func verifyStats(ctx context.Context, args []string) (err error) {
+	fs := flag.NewFlagSet("verify-stats", flag.ExitOnError)
+	dir := fs.String("dir", "", "database directory")
+	keyPath := fs.String("key-path", "", "file containing the key, empty if unencrypted")
+	bucket := fs.String("bucket", "", "bucket name")
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrapf(ctx, err, "parse args failed")
+	}
+	if *bucket == "" {
+		return errors.Errorf(ctx, "parameter bucket missing")
+	}
+	db, err := openDB(ctx, *dir, *keyPath)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "open db failed")
+	}
+	defer closeDB(ctx, db, &err)
+	check, err := db.VerifyBucketStats(ctx, libkv.NewBucketName(*bucket))
+	if err != nil {
+		return errors.Wrapf(ctx, err, "verify stats failed")
+	}
+	if err := printJSON(ctx, check); err != nil {
+		return err
+	}
+	if !check.OK() {
+		return errors.Errorf(ctx, "counters of bucket %s differ, run rebuild-stats", *bucket)
+	}
+	return nil
+}
+
+func compact(ctx context.Context, args []string) (err error) {
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	dir := fs.String("dir", "", "database directory")
+	keyPath := fs.String("key-path", "", "file containing the key, empty if unencrypted")
+	flatten := fs.Bool("flatten", false, "compact all tables into one level")
+	workers := fs.Int("workers", badgerkv.DefaultCompactWorkers, "flatten workers")
+	discardRatio := fs.Float64(
+		"discard-ratio",
+		badgerkv.DefaultDiscardRatio,
+		"rewrite value log files with at least this share of garbage",
+	)
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrapf(ctx, err, "parse args failed")
+	}
+	db, err := openDB(ctx, *dir, *keyPath)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "open db failed")
+	}
+	defer closeDB(ctx, db, &err)
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	return db.Compact(ctx, badgerkv.CompactOptions{
+		Workers:      *workers,
+		Flatten:      *flatten,
+		DiscardRatio: *discardRatio,
+		Progress: func(progress badgerkv.CompactProgress) {
+			fmt.Fprintf(
+				os.Stderr,
+				"%s: rewrites=%d lsm=%d vlog=%d\n",
+				progress.Stage,
+				progress.Rewrites,
+				progress.LSMBytes,
+				progress.VlogBytes,
+			)
+		},
+	})
+}
+
+func verify(ctx context.Context, args []string) (err error) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	dir := fs.String("dir", "", "database directory")
+	keyPath := fs.String("key-path", "", "file containing the key, empty if unencrypted")
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrapf(ctx, err, "parse args failed")
+	}
+	db, err := openDB(ctx, *dir, *keyPath)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "open db failed")
+	}
+	defer closeDB(ctx, db, &err)
+	report, err := db.Verify(ctx)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "verify failed")
+	}
+	if err := printJSON(ctx, report); err != nil {
+		return err
+	}
+	return report.Err(ctx)
+}
+
+func repair(ctx context.Context, args []string) (err error) {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	dir := fs.String("dir", "", "database directory")
+	keyPath := fs.String("key-path", "", "file containing the key, empty if unencrypted")
+	dryRun := fs.Bool("dry-run", false, "only report what would be repaired")
+	reregister := fs.Bool("reregister", false, "register the buckets of orphaned keys")
+	buckets := fs.String("buckets", "", "comma separated bucket names to reregister keys under")
+	dropOrphans := fs.Bool("drop-orphans", false, "delete orphaned keys")
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrapf(ctx, err, "parse args failed")
+	}
+	db, err := openDB(ctx, *dir, *keyPath)
+	if err != nil {
+		return errors.Wrapf(ctx, err, "open db failed")
+	}
+	defer closeDB(ctx, db, &err)
+	report, err := db.Repair(ctx, badgerkv.RepairOptions{
+		DryRun:      *dryRun,
+		Reregister:  *reregister,
+		Buckets:     parseBucketNames(*buckets),
+		DropOrphans: *dropOrphans,
+	})
+	if err != nil {
+		return errors.Wrapf(ctx, err, "repair failed")
+	}
+	return printJSON(ctx, report)
+}
+
+func parseBucketNames(value string) []libkv.BucketName {
+	if value == "" {
+		return nil
+	}
+	var result []libkv.BucketName
+	for _, name := range strings.Split(value, ",") {
+		result = append(result, libkv.NewBucketName(name))
+	}
+	return result
+}
+
+func openDB(ctx context.Context, dir string, keyPath string) (badgerkv.DB, error) {
+	if dir == "" {
+		return nil, errors.Errorf(ctx, "parameter dir missing")
+	}
+	key, err := readKey(ctx, keyPath)
+	if err != nil {
+		return nil, errors.Wrapf(ctx, err, "read key failed")
+	}
+	if len(key) == 0 {
+		return badgerkv.OpenPath(ctx, dir)
+	}
+	return badgerkv.OpenPath(ctx, dir, badgerkv.WithEncryptionKey(key, 0))
+}
+
+// closeDB closes db and returns the close error through err unless err is already set.
+func closeDB(ctx context.Context, db badgerkv.DB, err *error) {
+	if closeErr := db.Close(); closeErr != nil && *err == nil {
+		*err = errors.Wrapf(ctx, closeErr, "close db failed")
+	}
+}
+
+func printJSON(ctx context.Context, value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return errors.Wrapf(ctx, err, "encode json failed")
+	}
+	return nil
+}